@@ -42,3 +42,19 @@ extensions/v1beta1
 apps/v1
 {{- end -}}
 {{- end -}}
+
+{{/*
+The namespaces the webhooks check, all of them unless the chart only watches some
+*/}}
+{{- define "fluentd-router.webhookNamespaceSelector" -}}
+{{- if .Values.namespaces }}
+    namespaceSelector:
+      matchExpressions:
+        - key: kubernetes.io/metadata.name
+          operator: In
+          values:
+          {{- range .Values.namespaces }}
+            - {{ . | quote }}
+          {{- end }}
+{{- end }}
+{{- end -}}
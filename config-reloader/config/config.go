@@ -27,6 +27,7 @@ type Config struct {
 	Master                 string
 	KubeConfig             string
 	FluentdRPCPort         int
+	FluentdMonitorPort     int
 	TemplatesDir           string
 	OutputDir              string
 	LogLevel               string
@@ -40,6 +41,8 @@ type Config struct {
 	CRDMigrationMode       bool
 	FsDatasourceDir        string
 	AllowFile              bool
+	AllowSecrets           bool
+	AllowEmbeddedRuby      bool
 	ID                     string
 	FluentdValidateCommand string
 	MetaKey                string
@@ -54,18 +57,57 @@ type Config struct {
 	AdminNamespace         string
 	AllowLabel             string
 	AllowLabelAnnotation   string
+	LookupKinds            []string
+	RecordAnnotations      []string
 	// parsed or processed/cached fields
 	level               logrus.Level
 	ParsedMetaValues    map[string]string
 	ParsedLabelSelector labels.Set
 	ExecTimeoutSeconds  int
 	ReadBytesLimit      int
+	ValidationWorkers   int
+	UpdateDebounce      int
+	UpdateMaxLatency    int
+	WebhookPort         int
+	WebhookCertFile     string
+	WebhookKeyFile      string
+	WebhookValidate     bool
+	WebhookTimeout      int
+	Command             string
+	RenderDir           string
+	RenderManifests     []string
+	RenderOutputDir     string
+	FmtPaths            []string
+	FmtWrite            bool
+	FmtList             bool
+	PreserveFormatting  bool
+	PluginSchema        bool
+	PluginSchemaFile    string
+	ExplainNamespaces   []string
+	ExplainOutputDir    string
+	LeaderElect         bool
+	LeaderElectNS       string
+	ReloadVerifyTimeout int
+	StartupTimeout      int
+	FluentdPolicies     bool
+	ClusterConfigs      bool
 }
 
+// Commands of the config-reloader
+const (
+	// CommandRun keeps the fluentd config up to date, this is the default
+	CommandRun = "run"
+	// CommandRender generates the fluentd config of a directory once and exits
+	CommandRender = "render"
+	// CommandFmt normalizes the layout of fluentd config files and exits
+	CommandFmt = "fmt"
+)
+
 var defaultConfig = &Config{
 	Master:               "",
 	KubeConfig:           "",
 	FluentdRPCPort:       24444,
+	FluentdMonitorPort:   0,
 	TemplatesDir:         "/templates",
 	OutputDir:            "/fluentd/etc",
 	Datasource:           "default",
@@ -83,6 +125,13 @@ var defaultConfig = &Config{
 	AdminNamespace:       "kube-system",
 	ExecTimeoutSeconds:   30,
 	ReadBytesLimit:       51200,
+	ValidationWorkers:    1,
+	UpdateDebounce:       0,
+	UpdateMaxLatency:     30,
+	WebhookPort:          0,
+	WebhookTimeout:       8,
+	ReloadVerifyTimeout:  30,
+	StartupTimeout:       120,
 }
 
 var reValidID = regexp.MustCompile("([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]")
@@ -104,6 +153,30 @@ func (cfg *Config) Validate() error {
 		cfg.ExecTimeoutSeconds = 30
 	}
 
+	if cfg.ValidationWorkers < 1 {
+		cfg.ValidationWorkers = 1
+	}
+
+	if cfg.ReloadVerifyTimeout < 1 {
+		cfg.ReloadVerifyTimeout = 30
+	}
+
+	if cfg.WebhookTimeout < 1 {
+		cfg.WebhookTimeout = 8
+	}
+
+	if cfg.StartupTimeout < 0 {
+		cfg.StartupTimeout = 120
+	}
+
+	if cfg.UpdateDebounce < 0 {
+		cfg.UpdateDebounce = 0
+	}
+
+	if cfg.UpdateMaxLatency < cfg.UpdateDebounce {
+		return fmt.Errorf("--update-max-latency (%d) cannot be lower than --update-debounce (%d)", cfg.UpdateMaxLatency, cfg.UpdateDebounce)
+	}
+
 	ll, err := logrus.ParseLevel(cfg.LogLevel)
 	if err != nil {
 		return fmt.Errorf("failed to parse log level: %+v", err)
@@ -137,6 +210,14 @@ func (cfg *Config) Validate() error {
 		return errors.New("using --datasource=fs requires --fs-dir too")
 	}
 
+	if cfg.LeaderElect && cfg.LeaderElectNS == "" {
+		cfg.LeaderElectNS = cfg.AdminNamespace
+	}
+
+	if cfg.WebhookPort > 0 && (cfg.WebhookCertFile == "" || cfg.WebhookKeyFile == "") {
+		return errors.New("using --webhook-port requires --webhook-cert-file and --webhook-key-file too")
+	}
+
 	if cfg.MetaKey != "" && cfg.MetaValues == "" {
 		return errors.New("using --meta-key requires --meta-values too")
 	}
@@ -221,11 +302,19 @@ func (cfg *Config) ParseFlags(args []string) error {
 
 	app.Flag("interval", "Run every x seconds").Default(strconv.Itoa(defaultConfig.IntervalSeconds)).IntVar(&cfg.IntervalSeconds)
 
+	app.Flag("update-debounce", "Wait until no change is detected for x seconds before running, 0 disables debouncing (not used with --datasource=fake|fs)").Default(strconv.Itoa(defaultConfig.UpdateDebounce)).IntVar(&cfg.UpdateDebounce)
+	app.Flag("update-max-latency", "Run at most x seconds after the first change is detected, even if changes keep coming (used only with --update-debounce)").Default(strconv.Itoa(defaultConfig.UpdateMaxLatency)).IntVar(&cfg.UpdateMaxLatency)
+
 	app.Flag("allow-file", "Allow @type file for namespace configuration").BoolVar(&cfg.AllowFile)
+	app.Flag("allow-secrets", "Allow $secret(name, key) in namespace configs, the Secrets they refer to are read and watched (default: false)").BoolVar(&cfg.AllowSecrets)
+	app.Flag("allow-embedded-ruby", "Allow \"#{...}\" Ruby code in all namespace configs, without it only a FluentdPolicy can allow it (default: false)").BoolVar(&cfg.AllowEmbeddedRuby)
 
 	app.Flag("id", "The id of this deployment. It is used internally so that two deployments don't overwrite each other's data").Default(defaultConfig.ID).StringVar(&cfg.ID)
 
 	app.Flag("fluentd-rpc-port", "RPC port of Fluentd").Default(strconv.Itoa(defaultConfig.FluentdRPCPort)).IntVar(&cfg.FluentdRPCPort)
+	app.Flag("fluentd-monitor-port", "Local port of the monitor_agent used to verify that fluentd loaded the generated config after a reload, 0 disables the verification and the rollback").Default(strconv.Itoa(defaultConfig.FluentdMonitorPort)).IntVar(&cfg.FluentdMonitorPort)
+	app.Flag("reload-verify-timeout", "How many seconds fluentd has to load the generated config after a reload before rolling back to the last known good config").Default(strconv.Itoa(defaultConfig.ReloadVerifyTimeout)).IntVar(&cfg.ReloadVerifyTimeout)
+	app.Flag("fluentd-startup-timeout", "Wait at most x seconds for the RPC endpoint of fluentd to answer before the first run, 0 does not wait").Default(strconv.Itoa(defaultConfig.StartupTimeout)).IntVar(&cfg.StartupTimeout)
 	app.Flag("log-level", "Control verbosity of log level for reloader").Default(defaultConfig.LogLevel).StringVar(&cfg.LogLevel)
 	app.Flag("fluentd-loglevel", "Control verbosity of log level for fluentd").Default(defaultConfig.FluentdLogLevel).StringVar(&cfg.FluentdLogLevel)
 
@@ -237,9 +326,11 @@ func (cfg *Config) ParseFlags(args []string) error {
 
 	app.Flag("allow-label", "When set only objects with this label can be fetched using go templating").Default(defaultConfig.AllowLabel).StringVar(&cfg.AllowLabel)
 	app.Flag("allow-label-annotation", "Which annotation on the namespace stores the allow label?").Default(defaultConfig.AllowLabelAnnotation).StringVar(&cfg.AllowLabelAnnotation)
+	app.Flag("record-annotations", "Pod annotations added to the records, $labels can select on them with _annotation.<name>, can be repeated").StringsVar(&cfg.RecordAnnotations)
+	app.Flag("lookup-kinds", "Kinds the templates of the namespaces can read with k8sLookup, can be repeated, * allows any kind. The admin namespace can read any kind").Default("ConfigMap").StringsVar(&cfg.LookupKinds)
 
 	app.Flag("prometheus-enabled", "Prometheus metrics enabled (default: false)").BoolVar(&cfg.PrometheusEnabled)
-	app.Flag("metrics-port", "Expose prometheus metrics on this port (also needs --prometheus-enabled)").Default(strconv.Itoa(defaultConfig.MetricsPort)).IntVar(&cfg.MetricsPort)
+	app.Flag("metrics-port", "Serve /healthz and /readyz on this port, and the prometheus metrics with --prometheus-enabled").Default(strconv.Itoa(defaultConfig.MetricsPort)).IntVar(&cfg.MetricsPort)
 
 	app.Flag("kubelet-root", "Kubelet root dir, configured using --root-dir on the kubelet service").Default(defaultConfig.KubeletRoot).StringVar(&cfg.KubeletRoot)
 	app.Flag("namespaces", "List of namespaces to process. If empty, processes all namespaces").StringsVar(&cfg.Namespaces)
@@ -252,24 +343,61 @@ func (cfg *Config) ParseFlags(args []string) error {
 
 	app.Flag("fluentd-binary", "Path to fluentd binary used to validate configuration").StringVar(&cfg.FluentdValidateCommand)
 
+	app.Flag("fluentd-policies", "Restrict the namespace configs with the cluster-scoped FluentdPolicies (not used with --datasource=fake|fs)").BoolVar(&cfg.FluentdPolicies)
+
 	app.Flag("label-selector", "Label selector in the k=v,k2=v2 format (used only with --datasource=multimap)").StringVar(&cfg.LabelSelector)
 
 	app.Flag("allow-tag-expansion", "Allow specifying tags in the format 'k.{a,b}.** k.c.**' (default: false)").BoolVar(&cfg.AllowTagExpansion)
 
+	app.Flag("cluster-fluentd-configs", "Merge the cluster-scoped ClusterFluentdConfigs into the config of the admin namespace (not used with --datasource=fake|fs)").BoolVar(&cfg.ClusterConfigs)
+
 	app.Flag("admin-namespace", "Configurations defined in this namespace are copied as is, without further processing. Virtual plugins can also be defined in this namespace").Default(defaultConfig.AdminNamespace).StringVar(&cfg.AdminNamespace)
 
 	app.Flag("exec-timeout", "Timeout duration (in seconds) for exec command during validation").Default(strconv.Itoa(defaultConfig.ExecTimeoutSeconds)).IntVar(&cfg.ExecTimeoutSeconds)
 
+	app.Flag("validation-workers", "How many namespaces to validate in parallel with the fluentd binary").Default(strconv.Itoa(defaultConfig.ValidationWorkers)).IntVar(&cfg.ValidationWorkers)
+
+	app.Flag("preserve-formatting", "Keep the comments and the order of the params of the namespace configs in the generated files (default: false)").BoolVar(&cfg.PreserveFormatting)
+
+	app.Flag("plugin-schema-validation", "Reject the unknown params and the invalid values of the plugins described by the plugin schema (default: false)").BoolVar(&cfg.PluginSchema)
+	app.Flag("plugin-schema-file", "JSON plugin schema generated with 'make plugin-schema', replaces the built-in one (used only with --plugin-schema-validation)").ExistingFileVar(&cfg.PluginSchemaFile)
+
+	app.Flag("webhook-port", "Serve the validating admission webhook for fluentd configs on this port, 0 disables the webhook").Default(strconv.Itoa(defaultConfig.WebhookPort)).IntVar(&cfg.WebhookPort)
+	app.Flag("webhook-cert-file", "TLS certificate of the admission webhook (used only with --webhook-port)").StringVar(&cfg.WebhookCertFile)
+	app.Flag("webhook-key-file", "TLS private key of the admission webhook (used only with --webhook-port)").StringVar(&cfg.WebhookKeyFile)
+	app.Flag("webhook-validate", "Also validate configs with the fluentd binary in the admission webhook (needs --fluentd-binary)").BoolVar(&cfg.WebhookValidate)
+	app.Flag("webhook-timeout", "Timeout (in seconds) of the fluentd binary in the admission webhook, keep it below the timeoutSeconds of the webhook configuration").Default(strconv.Itoa(defaultConfig.WebhookTimeout)).IntVar(&cfg.WebhookTimeout)
+
+	app.Flag("leader-elect", "Elect a leader among the replicas sharing the same --id, only the leader writes statuses and records events. Without it no events are recorded (default: false)").BoolVar(&cfg.LeaderElect)
+	app.Flag("leader-elect-namespace", "Namespace of the Lease used for the leader election (default: the admin namespace)").StringVar(&cfg.LeaderElectNS)
+
+	app.Flag("explain-namespace", "Record how every processor transforms the config of this namespace, can be repeated").StringsVar(&cfg.ExplainNamespaces)
+	app.Flag("explain-output-dir", "Write the explanations to <namespace>.explain files in this dir instead of the log. They are also served on /debug/explain on the --metrics-port").StringVar(&cfg.ExplainOutputDir)
+
 	app.Flag("container-bytes-limit", "read_bytes_limit_per_second parameter for tail plugin per container file. Default 2MB/min").Default(strconv.Itoa(defaultConfig.ReadBytesLimit)).IntVar(&cfg.ReadBytesLimit)
 
 	app.Flag("namespace-selector", "Namespace selector in the k=v,k2=v2 format to select namespaces to process based on their labels.").StringVar(&cfg.NamespaceSelector)
 
-	_, err := app.Parse(args)
+	app.Command(CommandRun, "Keep the fluentd config up to date with the datasource (default)").Default()
+
+	render := app.Command(CommandRender, "Print or write the fluentd config generated from a directory of <namespace>.conf files and exit")
+	render.Arg("dir", "The dir hosting the <namespace>.conf files").Required().ExistingDirVar(&cfg.RenderDir)
+	render.Flag("manifests", "YAML files or dirs with the Namespaces, Pods and Secrets to render the config for, can be repeated").StringsVar(&cfg.RenderManifests)
+	render.Flag("out", "Write the generated files to this dir instead of printing them").StringVar(&cfg.RenderOutputDir)
+
+	format := app.Command(CommandFmt, "Normalize the layout of fluentd config files keeping their comments and the order of the params, print the result and exit")
+	format.Arg("paths", "The files, or dirs of *.conf files, to format").Required().ExistingFilesOrDirsVar(&cfg.FmtPaths)
+	format.Flag("write", "Rewrite the files in place instead of printing them").Short('w').BoolVar(&cfg.FmtWrite)
+	format.Flag("list", "Only list the files whose layout differs").Short('l').BoolVar(&cfg.FmtList)
+
+	cmd, err := app.Parse(args)
 
 	if err != nil {
 		return err
 	}
 
+	cfg.Command = cmd
+
 	return nil
 }
 
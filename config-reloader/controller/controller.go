@@ -5,19 +5,27 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
 
 	"github.com/vmware/kube-fluentd-operator/config-reloader/config"
 	"github.com/vmware/kube-fluentd-operator/config-reloader/datasource"
 	"github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
 	"github.com/vmware/kube-fluentd-operator/config-reloader/generator"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/metrics"
 
 	"github.com/sirupsen/logrus"
+	core "k8s.io/api/core/v1"
 )
 
 type Controller interface {
 	Run(ctx context.Context, stop <-chan struct{})
 	RunOnce(ctx context.Context) error
 	GetTotalConfigNS() int
+	WaitForFluentd(ctx context.Context, timeout time.Duration) error
+	Ready() error
 }
 
 type controllerInstance struct {
@@ -27,6 +35,10 @@ type controllerInstance struct {
 	Generator        generator.Generator
 	outputDir        string
 	numTotalConfigNS int
+	// reload even if no config changed, set after configs were rejected
+	forceReload bool
+	// set once RunOnce succeeded, read by the readiness check
+	ranOnce atomic.Bool
 }
 
 var _ Controller = &controllerInstance{}
@@ -34,14 +46,17 @@ var _ Controller = &controllerInstance{}
 // New creates new controller
 func New(ctx context.Context, cfg *config.Config, ds datasource.Datasource, up Updater) (Controller, error) {
 	var reloader *fluentd.Reloader
-	gen := generator.New(ctx, cfg)
+	gen, err := generator.New(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
 	gen.SetStatusUpdater(ctx, ds)
 
 	switch cfg.Datasource {
 	case "fake", "fs":
 		logrus.Infof("Setting reloader to null because is running locally")
 	default:
-		reloader = fluentd.NewReloader(ctx, cfg.FluentdRPCPort)
+		reloader = fluentd.NewReloader(ctx, cfg.FluentdRPCPort, cfg.FluentdMonitorPort, time.Second*time.Duration(cfg.ReloadVerifyTimeout))
 	}
 
 	return &controllerInstance{
@@ -55,6 +70,10 @@ func New(ctx context.Context, cfg *config.Config, ds datasource.Datasource, up U
 
 func (c *controllerInstance) RunOnce(ctx context.Context) error {
 	logrus.Infof("Running main control loop")
+	start := time.Now()
+	defer func() {
+		metrics.ObserveRunOnceDuration(time.Since(start))
+	}()
 
 	allConfigNamespaces, err := c.Datasource.GetNamespaces(ctx)
 	if err != nil {
@@ -64,10 +83,11 @@ func (c *controllerInstance) RunOnce(ctx context.Context) error {
 	c.Generator.SetModel(allConfigNamespaces)
 	configHashes, err := c.Generator.RenderToDisk(ctx, c.outputDir)
 	if err != nil {
-		return nil
+		return err
 	}
 
 	needsReload := false
+	var updatedNamespaces []string
 
 	logrus.Infof("Config hashes returned in RunOnce loop: %v", configHashes)
 
@@ -83,6 +103,7 @@ func (c *controllerInstance) RunOnce(ctx context.Context) error {
 		if newHash != nsConfig.PreviousConfigHash {
 			logrus.Infof("Detecting updates for namespace %s", nsConfig.Name)
 			needsReload = true
+			updatedNamespaces = append(updatedNamespaces, nsConfig.Name)
 			c.Datasource.WriteCurrentConfigHash(nsConfig.Name, newHash)
 		}
 	}
@@ -95,15 +116,82 @@ func (c *controllerInstance) RunOnce(ctx context.Context) error {
 		c.numTotalConfigNS = len(allConfigNamespaces)
 	}
 
-	if needsReload {
-		c.Reloader.ReloadConfiguration()
+	if needsReload || c.forceReload {
+		c.forceReload = false
+		c.reload(ctx, updatedNamespaces, configHashes)
 	}
 
 	c.Generator.CleanupUnusedFiles(c.outputDir, configHashes)
 
+	c.ranOnce.Store(true)
 	return nil
 }
 
+// reload makes fluentd load the generated config. If fluentd does not load it, the last
+// known good config is restored and the updated namespaces are marked as failed
+func (c *controllerInstance) reload(ctx context.Context, updatedNamespaces []string, configHashes map[string]string) {
+	err := c.reloadAndVerify(c.Generator.Generation())
+	if err == nil {
+		c.recordReloadEvents(ctx, updatedNamespaces, nil)
+		if c.Reloader.CanVerify() {
+			if err := c.Generator.SaveSnapshot(c.outputDir); err != nil {
+				logrus.Warnf("Cannot keep a copy of the loaded config: %+v", err)
+			}
+		}
+		return
+	}
+
+	logrus.Errorf("Fluentd did not load the generated config: %+v", err)
+	if !c.Reloader.CanVerify() {
+		c.recordReloadEvents(ctx, updatedNamespaces, err)
+		return
+	}
+
+	generation, rerr := c.Generator.RestoreSnapshot(c.outputDir)
+	if rerr == nil {
+		logrus.Warnf("Rolling back to the last known good config %s", generation)
+		rerr = c.reloadAndVerify(generation)
+	}
+	if rerr != nil {
+		// the new config cannot be blamed if fluentd does not run the previous one either
+		logrus.Errorf("Cannot roll back to the last known good config: %+v", rerr)
+		c.recordReloadEvents(ctx, updatedNamespaces, err)
+		return
+	}
+
+	rejected := map[string]string{}
+	for _, ns := range updatedNamespaces {
+		rejected[ns] = configHashes[ns]
+	}
+	c.Generator.RejectConfigs(ctx, rejected, err)
+	// the next run generates the config without the rejected namespaces
+	c.forceReload = len(rejected) > 0
+}
+
+func (c *controllerInstance) reloadAndVerify(generation string) error {
+	if err := c.Reloader.ReloadConfiguration(); err != nil {
+		return err
+	}
+
+	return c.Reloader.VerifyConfiguration(generation)
+}
+
+// recordReloadEvents tells the namespaces whose config changed that fluentd was reloaded
+func (c *controllerInstance) recordReloadEvents(ctx context.Context, namespaces []string, reloadErr error) {
+	er, ok := c.Datasource.(datasource.EventRecorder)
+	if !ok || c.Reloader == nil {
+		return
+	}
+
+	for _, ns := range namespaces {
+		if reloadErr != nil {
+			er.RecordEvent(ctx, ns, core.EventTypeWarning, datasource.ReasonReloadFailed, fmt.Sprintf("Cannot reload fluentd: %s", reloadErr.Error()))
+			continue
+		}
+		er.RecordEvent(ctx, ns, core.EventTypeNormal, datasource.ReasonReloaded, "Reloaded fluentd with the updated config")
+	}
+}
+
 func (c *controllerInstance) Run(ctx context.Context, stop <-chan struct{}) {
 	for {
 		err := c.RunOnce(ctx)
@@ -123,3 +211,17 @@ func (c *controllerInstance) Run(ctx context.Context, stop <-chan struct{}) {
 func (c *controllerInstance) GetTotalConfigNS() int {
 	return c.numTotalConfigNS
 }
+
+// WaitForFluentd blocks until fluentd can be reloaded, at most timeout
+func (c *controllerInstance) WaitForFluentd(ctx context.Context, timeout time.Duration) error {
+	return c.Reloader.WaitUntilReachable(ctx, timeout)
+}
+
+// Ready tells if the control loop completed a run and fluentd can be reloaded
+func (c *controllerInstance) Ready() error {
+	if !c.ranOnce.Load() {
+		return errors.New("the control loop did not complete a run yet")
+	}
+
+	return c.Reloader.Ping()
+}
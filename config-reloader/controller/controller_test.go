@@ -2,13 +2,24 @@ package controller
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/vmware/kube-fluentd-operator/config-reloader/config"
 	"github.com/vmware/kube-fluentd-operator/config-reloader/datasource"
+	fluentdpkg "github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/generator"
 )
 
 // UnitTest for RunOnce
@@ -39,12 +50,15 @@ func TestRunOnceController(t *testing.T) {
 		logrus.Fatalf("Unable to create new controller: %+v", err.Error())
 	}
 
+	assert.NotNil(ctrl.Ready())
+
 	// 2. RunOnce controller
 	err = ctrl.RunOnce(ctx)
 	if err != nil {
 		logrus.Fatalf("Unable to trigger RunOnce: %+v",err.Error())
 	}
 	assert.Equal(expectedResult, ctrl.GetTotalConfigNS())
+	assert.Nil(ctrl.Ready())
 
 	// 3. Create a new namespace in the folder
 	newNamespaceFile := config.FsDatasourceDir + "/new-namespace.conf"
@@ -62,3 +76,83 @@ func TestRunOnceController(t *testing.T) {
 	}
 	assert.Equal(expectedResult+1, ctrl.GetTotalConfigNS())
 }
+
+// fakeFluentd loads the generated config on reload unless it includes ns-bad.conf
+type fakeFluentd struct {
+	outputDir string
+	loadedID  string
+}
+
+var reMonitorAgentID = regexp.MustCompile(`@id (config_reloader_\w+)`)
+
+func (f *fakeFluentd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/api/config.gracefulReload":
+		main, _ := os.ReadFile(filepath.Join(f.outputDir, "fluent.conf"))
+		if !strings.Contains(string(main), "@include ns-bad.conf") {
+			f.loadedID = reMonitorAgentID.FindStringSubmatch(string(main))[1]
+		}
+		fmt.Fprint(w, `{"ok":true}`)
+	case "/api/plugins.json":
+		fmt.Fprintf(w, `{"plugins":[{"plugin_id":"%s","type":"monitor_agent"}]}`, f.loadedID)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestRunOnceRollsBackRejectedConfig(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	configDir := t.TempDir()
+	outputDir := t.TempDir()
+	assert.Nil(os.WriteFile(filepath.Join(configDir, "good.conf"), []byte("<match **>\n  @type null\n</match>\n"), 0o644))
+
+	fluentd := &fakeFluentd{outputDir: outputDir}
+	server := httptest.NewServer(fluentd)
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	cfg := &config.Config{
+		Datasource:         "fs",
+		FsDatasourceDir:    configDir,
+		TemplatesDir:       "../templates",
+		ID:                 "default",
+		OutputDir:          outputDir,
+		FluentdMonitorPort: port,
+	}
+	ds := datasource.NewFileSystemDatasource(ctx, configDir, outputDir)
+	gen, err := generator.New(ctx, cfg)
+	assert.Nil(err)
+	gen.SetStatusUpdater(ctx, ds)
+	ctrl := &controllerInstance{
+		Reloader:   fluentdpkg.NewReloader(ctx, port, port, 200*time.Millisecond),
+		Datasource: ds,
+		Generator:  gen,
+		outputDir:  outputDir,
+	}
+
+	assert.Nil(ctrl.RunOnce(ctx))
+	good := gen.Generation()
+	assert.Equal(fluentdpkg.MonitorAgentID(good), fluentd.loadedID)
+
+	// fluentd does not load the new config, the previous one is restored
+	assert.Nil(os.WriteFile(filepath.Join(configDir, "bad.conf"), []byte("<match **>\n  @type null\n</match>\n"), 0o644))
+	assert.Nil(ctrl.RunOnce(ctx))
+	assert.Equal(fluentdpkg.MonitorAgentID(good), fluentd.loadedID)
+	main, err := os.ReadFile(filepath.Join(outputDir, "fluent.conf"))
+	assert.Nil(err)
+	assert.NotContains(string(main), "ns-bad.conf")
+	status, err := os.ReadFile(filepath.Join(outputDir, "ns-bad.status"))
+	assert.Nil(err)
+	assert.Contains(string(status), "fluentd did not load the config")
+
+	// the next run reloads without the rejected namespace
+	assert.Nil(ctrl.RunOnce(ctx))
+	assert.NotEqual(good, gen.Generation())
+	assert.Equal(fluentdpkg.MonitorAgentID(gen.Generation()), fluentd.loadedID)
+	main, err = os.ReadFile(filepath.Join(outputDir, "fluent.conf"))
+	assert.Nil(err)
+	assert.Contains(string(main), "@include ns-good.conf")
+	assert.NotContains(string(main), "ns-bad.conf")
+}
@@ -25,17 +25,74 @@ func (f *FixedTimeUpdater) GetUpdateChannel() <-chan time.Time {
 	return time.After(f.interval)
 }
 
-// OnDemandUpdater is an Updater that delivers notifications on demand through a shared channel
+// OnDemandUpdater is an Updater that delivers notifications on demand through a shared channel.
+// When a debounce window is set, bursts of notifications are coalesced into a single one
+// that is delivered once no notification arrived for the whole window, but never later
+// than maxLatency after the first notification of the burst.
 type OnDemandUpdater struct {
-	channel chan time.Time
+	channel    chan time.Time
+	output     chan time.Time
+	debounce   time.Duration
+	maxLatency time.Duration
 }
 
 var _ Updater = &OnDemandUpdater{}
 
-func NewOnDemandUpdater(ctx context.Context, channel chan time.Time) *OnDemandUpdater {
-	return &OnDemandUpdater{channel: channel}
+func NewOnDemandUpdater(ctx context.Context, channel chan time.Time, debounce time.Duration, maxLatency time.Duration) *OnDemandUpdater {
+	o := &OnDemandUpdater{
+		channel:    channel,
+		output:     channel,
+		debounce:   debounce,
+		maxLatency: maxLatency,
+	}
+
+	if debounce > 0 {
+		o.output = make(chan time.Time, 1)
+		go o.coalesce(ctx)
+	}
+
+	return o
 }
 
 func (o *OnDemandUpdater) GetUpdateChannel() <-chan time.Time {
-	return o.channel
+	return o.output
+}
+
+func (o *OnDemandUpdater) coalesce(ctx context.Context) {
+	var first time.Time
+	var timer *time.Timer
+	var fire <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-o.channel:
+			if first.IsZero() {
+				first = t
+			}
+
+			wait := o.debounce
+			if o.maxLatency > 0 {
+				if remaining := o.maxLatency - time.Since(first); remaining < wait {
+					wait = remaining
+				}
+			}
+
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.NewTimer(wait)
+			fire = timer.C
+		case <-fire:
+			select {
+			case o.output <- first:
+			default:
+				// a notification is still pending, it covers this burst too
+			}
+			first = time.Time{}
+			timer = nil
+			fire = nil
+		}
+	}
 }
@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func drain(ch <-chan time.Time, wait time.Duration) int {
+	count := 0
+	deadline := time.After(wait)
+	for {
+		select {
+		case <-ch:
+			count++
+		case <-deadline:
+			return count
+		}
+	}
+}
+
+func notify(ch chan time.Time) {
+	select {
+	case ch <- time.Now():
+	default:
+	}
+}
+
+func TestOnDemandUpdaterWithoutDebounce(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updateChan := make(chan time.Time, 1)
+	up := NewOnDemandUpdater(ctx, updateChan, 0, 0)
+
+	notify(updateChan)
+	assert.Equal(t, 1, drain(up.GetUpdateChannel(), 50*time.Millisecond))
+}
+
+func TestOnDemandUpdaterCoalescesBursts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updateChan := make(chan time.Time, 1)
+	up := NewOnDemandUpdater(ctx, updateChan, 100*time.Millisecond, time.Second)
+
+	for i := 0; i < 10; i++ {
+		notify(updateChan)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// nothing is delivered while the burst is still settling
+	assert.Equal(t, 0, drain(up.GetUpdateChannel(), 20*time.Millisecond))
+	assert.Equal(t, 1, drain(up.GetUpdateChannel(), 300*time.Millisecond))
+}
+
+func TestOnDemandUpdaterHonorsMaxLatency(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updateChan := make(chan time.Time, 1)
+	up := NewOnDemandUpdater(ctx, updateChan, 100*time.Millisecond, 200*time.Millisecond)
+
+	start := time.Now()
+	received := make(chan time.Time, 1)
+	go func() {
+		received <- <-up.GetUpdateChannel()
+	}()
+
+	// keep notifying more often than the debounce window
+	for time.Since(start) < 500*time.Millisecond {
+		notify(updateChan)
+		time.Sleep(20 * time.Millisecond)
+		if len(received) > 0 {
+			break
+		}
+	}
+
+	select {
+	case <-received:
+		assert.Less(t, time.Since(start), 400*time.Millisecond)
+	default:
+		t.Fatalf("no update delivered within max latency")
+	}
+}
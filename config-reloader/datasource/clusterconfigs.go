@@ -0,0 +1,94 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package datasource
+
+import (
+	"context"
+	"strings"
+
+	"github.com/vmware/kube-fluentd-operator/config-reloader/template"
+
+	"github.com/sirupsen/logrus"
+)
+
+var _ ClusterConfigSource = &kubeInformerConnection{}
+var _ ClusterConfigStatusUpdater = &kubeInformerConnection{}
+
+// GetClusterConfigs returns the ClusterFluentdConfigs in the order they are merged, the
+// templates are rendered in the context of the admin namespace
+func (d *kubeInformerConnection) GetClusterConfigs(ctx context.Context) ([]*ClusterConfig, error) {
+	if d.clusterds == nil {
+		return nil, nil
+	}
+
+	configs, err := d.clusterds.GetClusterFluentdConfigs()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*ClusterConfig, 0, len(configs))
+	for _, cc := range configs {
+		buf := new(strings.Builder)
+		err := template.Render(buf, cc.Spec.FluentConf, map[string]string{
+			"Namespace": d.cfg.AdminNamespace,
+		}, d.TemplateContext(ctx, d.cfg.AdminNamespace))
+		if err != nil {
+			logrus.Errorf("failed to render clusterfluentdconfig %s: %v", cc.Name, err)
+		}
+
+		res = append(res, &ClusterConfig{
+			Name:          cc.Name,
+			FluentdConfig: buf.String(),
+			RenderError:   err,
+		})
+	}
+
+	return res, nil
+}
+
+// addClusterConfigs attaches the ClusterFluentdConfigs to the admin namespace, which is
+// added to the namespaces if it has no config of its own
+func (d *kubeInformerConnection) addClusterConfigs(ctx context.Context, nsconfigs []*NamespaceConfig) ([]*NamespaceConfig, error) {
+	clusterConfigs, err := d.GetClusterConfigs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(clusterConfigs) == 0 {
+		return nsconfigs, nil
+	}
+
+	for _, ns := range nsconfigs {
+		if ns.Name == d.cfg.AdminNamespace {
+			ns.ClusterConfigs = clusterConfigs
+			return nsconfigs, nil
+		}
+	}
+
+	return append(nsconfigs, &NamespaceConfig{
+		Name:               d.cfg.AdminNamespace,
+		PreviousConfigHash: d.confHashes[d.cfg.AdminNamespace],
+		ClusterConfigs:     clusterConfigs,
+	}), nil
+}
+
+// UpdateClusterConfigStatus writes the status subresource of the ClusterFluentdConfig
+func (d *kubeInformerConnection) UpdateClusterConfigStatus(ctx context.Context, name string, status *ConfigStatus) {
+	if d.clusterds == nil {
+		return
+	}
+
+	d.statusLock.Lock()
+	if d.clusterStatuses == nil {
+		d.clusterStatuses = map[string]*ConfigStatus{}
+	}
+	d.clusterStatuses[name] = status
+	d.statusLock.Unlock()
+
+	if !d.leader.IsLeader() {
+		logrus.Debugf("Not the leader, skipping status update of clusterfluentdconfig %s", name)
+		return
+	}
+
+	d.clusterds.WriteConfigStatus(ctx, name, status.FailedCondition, status.Message, status.ConfigHash)
+}
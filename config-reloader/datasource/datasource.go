@@ -5,11 +5,33 @@ package datasource
 
 import (
 	"context"
+	"fmt"
 	"sort"
 
+	kfo "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/apis/logs.vdp.vmware.com/v1beta1"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/template"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/util"
 	core "k8s.io/api/core/v1"
 )
 
+// The conditions a namespace config goes through, in order
+const (
+	ConditionParsed    = kfo.ConditionParsed
+	ConditionProcessed = kfo.ConditionProcessed
+	ConditionValidated = kfo.ConditionValidated
+	ConditionApplied   = kfo.ConditionApplied
+)
+
+// The reasons of the Events recorded against the objects holding the config of a namespace
+const (
+	ReasonProcessingFailed = "ProcessingFailed"
+	ReasonValidationFailed = "ValidationFailed"
+	ReasonConfigValid      = "ConfigValid"
+	ReasonReloaded         = "Reloaded"
+	ReasonReloadFailed     = "ReloadFailed"
+)
+
 type Mount struct {
 	Path       string
 	VolumeName string
@@ -27,10 +49,15 @@ type MiniContainer struct {
 
 	// pod labels
 	Labels map[string]string
+	// pod annotations
+	Annotations map[string]string
+	// the controller of the pod, a Deployment rather than its ReplicaSet
+	OwnerKind string
+	OwnerName string
 
 	// container name
 	Name string
-	// only the emptyDir mounts, never empty, sorted by len(Path), descending
+	// only the emptyDir mounts sorted by len(Path), descending
 	HostMounts []*Mount
 
 	NodeName string
@@ -43,6 +70,60 @@ type NamespaceConfig struct {
 	PreviousConfigHash string
 	MiniContainers     []*MiniContainer
 	Labels             map[string]string
+	// Policies are the FluentdPolicies that apply to the namespace, sorted by name
+	Policies []*kfo.FluentdPolicy
+	// ClusterConfigs are merged after FluentdConfig, only the admin namespace has them
+	ClusterConfigs []*ClusterConfig
+	// Secrets holds the data of the Secrets of the namespace the config refers to
+	// with $secret(name, key), a missing Secret is left out
+	Secrets map[string]map[string][]byte
+	// IncludeFiles are the files FluentdConfig can @include, by name
+	IncludeFiles map[string]string
+}
+
+// ClusterConfig is the config of a ClusterFluentdConfig
+type ClusterConfig struct {
+	Name          string
+	FluentdConfig string
+	// RenderError is set when the template of the config cannot be rendered, the config
+	// must not be merged then
+	RenderError error
+}
+
+// ClusterConfigSource is implemented by the datasources that know the ClusterFluentdConfigs
+type ClusterConfigSource interface {
+	// GetClusterConfigs returns the configs in the order they are merged
+	GetClusterConfigs(ctx context.Context) ([]*ClusterConfig, error)
+}
+
+// IncludeSource is implemented by the datasources whose configs can @include other files
+type IncludeSource interface {
+	// GetIncludeFiles returns the files the config of the namespace can @include, by name
+	GetIncludeFiles(ctx context.Context, namespace string) (map[string]string, error)
+}
+
+// SecretSource is implemented by the datasources that can read Secrets
+type SecretSource interface {
+	// GetSecrets returns the data of the named Secrets of the namespace
+	GetSecrets(ctx context.Context, namespace string, names []string) map[string]map[string][]byte
+}
+
+// NamespaceSource is implemented by the datasources that know the Namespace objects
+type NamespaceSource interface {
+	// GetNamespaceAnnotations returns the annotations of the namespace
+	GetNamespaceAnnotations(ctx context.Context, namespace string) (map[string]string, error)
+}
+
+// TemplateContextSource is implemented by the datasources whose templates can use k8sLookup
+type TemplateContextSource interface {
+	// TemplateContext returns what k8sLookup can read in the templates of the namespace
+	TemplateContext(ctx context.Context, namespace string) *template.Context
+}
+
+// ClusterConfigStatusUpdater is implemented by the datasources that report
+// the status of the ClusterFluentdConfigs
+type ClusterConfigStatusUpdater interface {
+	UpdateClusterConfigStatus(ctx context.Context, name string, status *ConfigStatus)
 }
 
 // StatusUpdater sets an error description on the namespace
@@ -51,6 +132,29 @@ type StatusUpdater interface {
 	UpdateStatus(ctx context.Context, namespace string, status string)
 }
 
+// ConfigStatus is a detailed outcome of the generation of a namespace config
+type ConfigStatus struct {
+	// FailedCondition is the first of the Parsed, Processed, Validated, Applied
+	// conditions that did not hold, empty if the config was applied
+	FailedCondition string
+	// Message is the error description, empty if the config was applied
+	Message string
+	// ConfigHash identifies the generated config
+	ConfigHash string
+}
+
+// ConfigStatusUpdater is implemented by the datasources that can make use of
+// a ConfigStatus rather than just the error description
+type ConfigStatusUpdater interface {
+	UpdateConfigStatus(ctx context.Context, namespace string, status *ConfigStatus)
+}
+
+// EventRecorder is implemented by the datasources that can record Kubernetes Events
+// against the objects the config of a namespace was read from
+type EventRecorder interface {
+	RecordEvent(ctx context.Context, namespace string, eventtype string, reason string, message string)
+}
+
 // Datasource reads data from k8s
 type Datasource interface {
 	StatusUpdater
@@ -72,6 +176,16 @@ func (s byLength) Less(i, j int) bool {
 	return len(s[i].Path) > len(s[j].Path)
 }
 
+func matchAny(selectors []util.Selector, target *util.Target) bool {
+	for _, selector := range selectors {
+		if util.Match(selector, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func findContainerStatus(statuses []core.ContainerStatus, name string) *core.ContainerStatus {
 	for _, st := range statuses {
 		if st.Name == name {
@@ -81,21 +195,71 @@ func findContainerStatus(statuses []core.ContainerStatus, name string) *core.Con
 	return nil
 }
 
+// Target is what the selectors of mounted-file match
+func (mc *MiniContainer) Target() *util.Target {
+	return &util.Target{
+		Labels:      mc.Labels,
+		Annotations: mc.Annotations,
+		Container:   mc.Name,
+		Image:       mc.Image,
+		OwnerKind:   mc.OwnerKind,
+		OwnerName:   mc.OwnerName,
+	}
+}
+
+// podTarget is the Target of a container of a pod
+func podTarget(pod *core.Pod, cont *core.Container) *util.Target {
+	kind, name := util.PodOwner(pod.Labels, pod.Name)
+	return &util.Target{
+		Labels:      pod.Labels,
+		Annotations: pod.Annotations,
+		Container:   cont.Name,
+		Image:       cont.Image,
+		OwnerKind:   kind,
+		OwnerName:   name,
+	}
+}
+
+// podSelectors returns the labels of the mounted-file sources of a namespace config, the
+// pods they match are read into the MiniContainers
+func podSelectors(fragment fluentd.Fragment) ([]util.Selector, error) {
+	var res []util.Selector
+	for _, frag := range fragment {
+		if frag.Name == "source" && frag.Type() == "mounted-file" {
+			paramLabels := util.TrimTrailingComment(frag.Param("labels"))
+			selector, err := util.ParseTagToLabels(fmt.Sprintf("$labels(%s)", paramLabels))
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, selector)
+		}
+	}
+
+	return res, nil
+}
+
+// convertPodToMinis keeps the containers with emptyDir mounts, for mounted-file
 func convertPodToMinis(resp *core.PodList) []*MiniContainer {
 	var res []*MiniContainer
 
-	for _, pod := range resp.Items {
-		for _, cont := range pod.Spec.Containers {
+	for i := range resp.Items {
+		pod := &resp.Items[i]
+		for j := range pod.Spec.Containers {
+			cont := &pod.Spec.Containers[j]
 			contStatus := findContainerStatus(pod.Status.ContainerStatuses, cont.Name)
 			cid := ""
 			if contStatus != nil {
 				cid = contStatus.ContainerID
 			}
 
+			kind, name := util.PodOwner(pod.Labels, pod.Name)
 			mini := &MiniContainer{
 				PodID:       string(pod.UID),
 				PodName:     pod.Name,
 				Labels:      pod.Labels,
+				Annotations: pod.Annotations,
+				OwnerKind:   kind,
+				OwnerName:   name,
 				Name:        cont.Name,
 				NodeName:    pod.Spec.NodeName,
 				Image:       cont.Image,
@@ -0,0 +1,71 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package datasource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func makeOwnedPod(name string, kind string, owner string, labels map[string]string) corev1.Pod {
+	controller := true
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: kind, Name: owner, Controller: &controller},
+			},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "main", Image: "nginx:1.25"}},
+		},
+	}
+}
+
+func TestConvertPodToMinisKeepsMountedFiles(t *testing.T) {
+	s := `
+<source>
+  @type mounted-file
+  path /var/log/app.log
+  labels app=web
+</source>
+
+<label @APPS>
+  <match $labels(_owner_kind=StatefulSet)>
+    @type null
+  </match>
+</label>
+
+<match $labels(app=web)>
+  @type null
+</match>
+`
+	fragment, err := fluentd.ParseString(s)
+	assert.Nil(t, err)
+
+	// $labels is matched on the records, only mounted-file depends on the pods
+	selectors, err := podSelectors(fragment)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(selectors))
+
+	web := makeOwnedPod("web-6d4cf56db6-x2v9k", "ReplicaSet", "web-6d4cf56db6", map[string]string{"pod-template-hash": "6d4cf56db6"})
+	web.Spec.Volumes = []corev1.Volume{{Name: "logs", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}}
+	web.Spec.Containers[0].VolumeMounts = []corev1.VolumeMount{{Name: "logs", MountPath: "/var/log"}}
+	pods := &corev1.PodList{Items: []corev1.Pod{
+		web,
+		makeOwnedPod("db-0", "StatefulSet", "db", nil),
+	}}
+
+	minis := convertPodToMinis(pods)
+	assert.Equal(t, 1, len(minis))
+	assert.Equal(t, "web-6d4cf56db6-x2v9k", minis[0].PodName)
+	assert.Equal(t, "Deployment", minis[0].OwnerKind)
+	assert.Equal(t, "web", minis[0].OwnerName)
+	assert.Equal(t, "/var/log", minis[0].HostMounts[0].Path)
+}
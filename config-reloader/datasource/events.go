@@ -0,0 +1,86 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package datasource
+
+import (
+	"context"
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource"
+	kfoScheme "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/client/clientset/versioned/scheme"
+
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const eventComponent = "config-reloader"
+
+// newEventRecorder records events with the core API, it knows about the FluentdConfig kind
+func newEventRecorder(client kubernetes.Interface) record.EventRecorder {
+	// the DaemonSet passes the node name, any replica of a Deployment has its own hostname
+	host := os.Getenv("K8S_NODE_NAME")
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = kfoScheme.AddToScheme(scheme)
+
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{
+		Interface: client.CoreV1().Events(""),
+	})
+
+	return broadcaster.NewRecorder(scheme, core.EventSource{Component: eventComponent, Host: host})
+}
+
+// RecordEvent records an event against the ConfigMaps or FluentdConfigs the config of the
+// namespace was read from, or against the namespace if there are none. Events are recorded
+// only with --leader-elect and by the leader, otherwise every replica would record the same event.
+func (d *kubeInformerConnection) RecordEvent(ctx context.Context, namespace string, eventtype string, reason string, message string) {
+	if d.recorder == nil || !d.leader.IsLeader() {
+		return
+	}
+
+	for _, obj := range d.eventObjects(ctx, namespace) {
+		d.recorder.Event(obj, eventtype, reason, message)
+	}
+}
+
+func (d *kubeInformerConnection) eventObjects(ctx context.Context, namespace string) []runtime.Object {
+	if col, ok := d.kubeds.(kubedatasource.ConfigObjectLister); ok {
+		if objects := col.ListConfigObjects(ctx, namespace); len(objects) > 0 {
+			return objects
+		}
+	}
+
+	ns, err := d.nslist.Get(namespace)
+	if err != nil {
+		logrus.Debugf("Cannot find namespace to record an event for: %v", namespace)
+		return nil
+	}
+
+	return []runtime.Object{ns}
+}
+
+// recordStatusEvent records the failures and the recovery of the config of a namespace.
+// previous is the last known status, nil if unknown.
+func (d *kubeInformerConnection) recordStatusEvent(ctx context.Context, namespace string, previous *ConfigStatus, status *ConfigStatus) {
+	switch {
+	case status.FailedCondition == ConditionApplied:
+		d.RecordEvent(ctx, namespace, core.EventTypeWarning, ReasonReloadFailed, status.Message)
+	case status.FailedCondition == ConditionValidated:
+		d.RecordEvent(ctx, namespace, core.EventTypeWarning, ReasonValidationFailed, status.Message)
+	case status.FailedCondition != "":
+		d.RecordEvent(ctx, namespace, core.EventTypeWarning, ReasonProcessingFailed, status.Message)
+	case previous != nil && previous.FailedCondition != "":
+		d.RecordEvent(ctx, namespace, core.EventTypeNormal, ReasonConfigValid, "The fluentd config is valid again")
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package datasource
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/config"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource"
+	kfo "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/apis/logs.vdp.vmware.com/v1beta1"
+	kfoFake "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/client/clientset/versioned/fake"
+	kfoInformers "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/client/informers/externalversions"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func drainEvents(recorder *record.FakeRecorder) []string {
+	var events []string
+	for {
+		select {
+		case e := <-recorder.Events:
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+func TestRecordStatusEvents(t *testing.T) {
+	assert := assert.New(t)
+	namespace := "test-namespace"
+	testCfg := &config.Config{
+		Datasource:  "crd",
+		AnnotStatus: "logging.csp.vmware.com/fluentd-status",
+		ID:          "default",
+	}
+	ctx := context.Background()
+	clientset := testclient.NewSimpleClientset(
+		&corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: namespace,
+			},
+		},
+	)
+	fc := &kfo.FluentdConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fd-config",
+			Namespace: namespace,
+		},
+	}
+	kfoClientset := kfoFake.NewSimpleClientset(fc)
+	kfoFactory := kfoInformers.NewSharedInformerFactory(kfoClientset, 0)
+	kfoFactory.Logs().V1beta1().FluentdConfigs().Informer().GetIndexer().Add(fc)
+
+	recorder := record.NewFakeRecorder(10)
+	var ds = &kubeInformerConnection{
+		client: clientset,
+		cfg:    testCfg,
+		kubeds: &kubedatasource.FluentdConfigDS{
+			Cfg:      testCfg,
+			Fdclient: kfoClientset,
+			Fdlist:   kfoFactory.Logs().V1beta1().FluentdConfigs().Lister(),
+		},
+		recorder: recorder,
+	}
+
+	ds.UpdateConfigStatus(ctx, namespace, &ConfigStatus{
+		FailedCondition: ConditionProcessed,
+		Message:         "cannot use <source> directive",
+	})
+	ds.UpdateConfigStatus(ctx, namespace, &ConfigStatus{
+		FailedCondition: ConditionValidated,
+		Message:         "unknown output plugin 'nope'",
+	})
+	ds.UpdateConfigStatus(ctx, namespace, &ConfigStatus{})
+	// still valid, nothing to tell
+	ds.UpdateConfigStatus(ctx, namespace, &ConfigStatus{})
+
+	assert.Equal([]string{
+		"Warning ProcessingFailed cannot use <source> directive",
+		"Warning ValidationFailed unknown output plugin 'nope'",
+		"Normal ConfigValid The fluentd config is valid again",
+	}, drainEvents(recorder))
+}
+
+func TestRecordEventOnNamespaceWithoutConfigObjects(t *testing.T) {
+	assert := assert.New(t)
+	namespace := "test-namespace"
+	testCfg := &config.Config{
+		Datasource:           "default",
+		DefaultConfigmapName: "fluentd-config",
+		ID:                   "default",
+	}
+	ctx := context.Background()
+	clientset := testclient.NewSimpleClientset()
+	factory := informers.NewSharedInformerFactory(clientset, 0)
+	importK8sObjects(factory, []testNamespace{{name: namespace}}, nil, nil)
+
+	kubeds, err := kubedatasource.NewConfigMapDS(ctx, testCfg, factory, make(chan time.Time, 1))
+	assert.Nil(err)
+
+	recorder := record.NewFakeRecorder(10)
+	var ds = &kubeInformerConnection{
+		client:   clientset,
+		cfg:      testCfg,
+		kubeds:   kubeds,
+		nslist:   factory.Core().V1().Namespaces().Lister(),
+		recorder: recorder,
+		leader:   &leaderElection{},
+	}
+
+	objects := ds.eventObjects(ctx, namespace)
+	assert.Equal(1, len(objects))
+	assert.Equal(namespace, objects[0].(*corev1.Namespace).Name)
+
+	// only the leader records events
+	ds.RecordEvent(ctx, namespace, corev1.EventTypeNormal, ReasonReloaded, "Reloaded fluentd with the updated config")
+	assert.Empty(drainEvents(recorder))
+
+	ds.leader.leading.Store(true)
+	ds.RecordEvent(ctx, namespace, corev1.EventTypeNormal, ReasonReloaded, "Reloaded fluentd with the updated config")
+	assert.Equal([]string{"Normal Reloaded Reloaded fluentd with the updated config"}, drainEvents(recorder))
+}
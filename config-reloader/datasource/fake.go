@@ -8,13 +8,16 @@ import (
 	"strings"
 	"time"
 
+	"github.com/vmware/kube-fluentd-operator/config-reloader/template"
+
 	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 var logzTemplate = `
 <match **>
   @type logzio_buffered
-  endpoint_url https://listener.logz.io:8071?token=secret
+  endpoint_url https://listener.logz.io:8071?token={{ (k8sLookup "ConfigMap.v1" .Values.Namespace "logzio").data.token }}
   output_include_time true
   output_include_tags true
   buffer_type    file
@@ -24,32 +27,57 @@ var logzTemplate = `
 </match>
 `
 
+var fakeNamespaces = []string{"kube-system", "monitoring", "csp-main"}
+
 type fakeDatasource struct {
-	hashes map[string]string
+	hashes  map[string]string
+	lookups *template.FakeBackend
 }
 
 // NewFakeDatasource returns a predefined set of namespaces + configs
 func NewFakeDatasource(ctx context.Context) Datasource {
+	lookups := template.NewFakeBackend()
+	for _, ns := range fakeNamespaces {
+		cm := &unstructured.Unstructured{}
+		cm.SetAPIVersion("v1")
+		cm.SetKind("ConfigMap")
+		cm.SetNamespace(ns)
+		cm.SetName("logzio")
+		_ = unstructured.SetNestedField(cm.Object, "secret", "data", "token")
+		lookups.Add(cm)
+	}
+
 	return &fakeDatasource{
-		hashes: make(map[string]string),
+		hashes:  make(map[string]string),
+		lookups: lookups,
 	}
 }
 
-func makeFakeConfig(namespace string) string {
+func (d *fakeDatasource) makeFakeConfig(namespace string) string {
 	contents := logzTemplate
 	contents = strings.ReplaceAll(contents, "$ns$", namespace)
 	contents = strings.ReplaceAll(contents, "$ts$", time.Now().String())
 
-	return contents
+	buf := new(strings.Builder)
+	if err := template.Render(buf, contents, map[string]string{
+		"Namespace": namespace,
+	}, &template.Context{
+		Backend:   d.lookups,
+		Namespace: namespace,
+	}); err != nil {
+		logrus.Errorf("failed to render config in namespace: %v", namespace)
+	}
+
+	return buf.String()
 }
 
 func (d *fakeDatasource) GetNamespaces(ctx context.Context) ([]*NamespaceConfig, error) {
 	res := []*NamespaceConfig{}
 
-	for _, ns := range []string{"kube-system", "monitoring", "csp-main"} {
+	for _, ns := range fakeNamespaces {
 		res = append(res, &NamespaceConfig{
 			Name:          ns,
-			FluentdConfig: makeFakeConfig(ns),
+			FluentdConfig: d.makeFakeConfig(ns),
 		})
 	}
 
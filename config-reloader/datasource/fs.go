@@ -4,20 +4,36 @@
 package datasource
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/vmware/kube-fluentd-operator/config-reloader/template"
 	"github.com/vmware/kube-fluentd-operator/config-reloader/util"
 
 	"github.com/sirupsen/logrus"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
 )
 
 type fsDatasource struct {
 	hashes          map[string]string
 	rootDir         string
 	statusOutputDir string
+	nsLabels        map[string]map[string]string
+	pods            map[string][]core.Pod
+	secrets         map[string]map[string]map[string][]byte
+	lookups         *template.FakeBackend
 }
 
 // NewFileSystemDatasource turns all files matching *.conf patter in the given dir into namespace configs
@@ -26,9 +42,158 @@ func NewFileSystemDatasource(ctx context.Context, rootDir string, statusOutputDi
 		hashes:          make(map[string]string),
 		rootDir:         rootDir,
 		statusOutputDir: statusOutputDir,
+		nsLabels:        make(map[string]map[string]string),
+		pods:            make(map[string][]core.Pod),
+		secrets:         make(map[string]map[string]map[string][]byte),
+		lookups:         template.NewFakeBackend(),
 	}
 }
 
+// NewFileSystemDatasourceWithManifests works like NewFileSystemDatasource and also reads the
+// Namespaces, Pods and Secrets found in the given YAML files or dirs to fill the namespace labels,
+// containers and secrets, as the kubernetes datasource would do. All the objects can be read
+// with k8sLookup.
+func NewFileSystemDatasourceWithManifests(ctx context.Context, rootDir string, statusOutputDir string, manifests []string) (Datasource, error) {
+	d := NewFileSystemDatasource(ctx, rootDir, statusOutputDir).(*fsDatasource)
+
+	for _, m := range manifests {
+		files, err := manifestFiles(m)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range files {
+			if err := d.loadManifest(f); err != nil {
+				return nil, fmt.Errorf("cannot load manifest %s: %+v", f, err)
+			}
+		}
+	}
+
+	return d, nil
+}
+
+func manifestFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	res := []string{}
+	for _, pattern := range []string{"*.yaml", "*.yml", "*.json"} {
+		files, err := filepath.Glob(filepath.Join(path, pattern))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, files...)
+	}
+	sort.Strings(res)
+
+	return res, nil
+}
+
+func (d *fsDatasource) loadManifest(fname string) error {
+	f, err := os.Open(fname)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := k8syaml.NewYAMLReader(bufio.NewReader(f))
+	for {
+		doc, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+
+		data, err := yaml.YAMLToJSON(doc)
+		if err != nil {
+			return err
+		}
+
+		if err := d.loadObject(data); err != nil {
+			return err
+		}
+	}
+}
+
+// loadObject keeps the Namespaces, Pods and Secrets, including the ones in a List, and makes
+// every object available to k8sLookup
+func (d *fsDatasource) loadObject(data []byte) error {
+	obj := struct {
+		Kind  string            `json:"kind"`
+		Items []json.RawMessage `json:"items"`
+	}{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+
+	if obj.Kind != "" && !strings.HasSuffix(obj.Kind, "List") {
+		u := &unstructured.Unstructured{}
+		if err := u.UnmarshalJSON(data); err != nil {
+			return err
+		}
+		d.lookups.Add(u)
+	}
+
+	switch obj.Kind {
+	case "Namespace":
+		ns := &core.Namespace{}
+		if err := json.Unmarshal(data, ns); err != nil {
+			return err
+		}
+		d.nsLabels[ns.Name] = ns.Labels
+	case "Pod":
+		pod := &core.Pod{}
+		if err := json.Unmarshal(data, pod); err != nil {
+			return err
+		}
+		if pod.Namespace == "" {
+			pod.Namespace = "default"
+		}
+		d.pods[pod.Namespace] = append(d.pods[pod.Namespace], *pod)
+	case "Secret":
+		secret := &core.Secret{}
+		if err := json.Unmarshal(data, secret); err != nil {
+			return err
+		}
+		if secret.Namespace == "" {
+			secret.Namespace = "default"
+		}
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		for k, v := range secret.StringData {
+			secret.Data[k] = []byte(v)
+		}
+		if d.secrets[secret.Namespace] == nil {
+			d.secrets[secret.Namespace] = map[string]map[string][]byte{}
+		}
+		d.secrets[secret.Namespace][secret.Name] = secret.Data
+	default:
+		if !strings.HasSuffix(obj.Kind, "List") {
+			return nil
+		}
+		for _, item := range obj.Items {
+			if err := d.loadObject(item); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
 func (d *fsDatasource) GetNamespaces(ctx context.Context) ([]*NamespaceConfig, error) {
 	res := []*NamespaceConfig{}
 
@@ -46,10 +211,22 @@ func (d *fsDatasource) GetNamespaces(ctx context.Context) ([]*NamespaceConfig, e
 			continue
 		}
 
+		includes, err := d.GetIncludeFiles(ctx, ns)
+		if err != nil {
+			logrus.Infof("Cannot read the files of namespace %s: %+v", ns, err)
+			continue
+		}
+
+		configdata := d.render(ns, string(contents))
+
 		cfg := &NamespaceConfig{
 			Name:               ns,
-			FluentdConfig:      string(contents),
+			FluentdConfig:      configdata,
+			IncludeFiles:       includes,
 			PreviousConfigHash: d.hashes[ns],
+			Labels:             d.nsLabels[ns],
+			MiniContainers:     convertPodToMinis(&core.PodList{Items: d.pods[ns]}),
+			Secrets:            d.secrets[ns],
 		}
 
 		logrus.Infof("Loading namespace %s from file %s", ns, f)
@@ -59,6 +236,50 @@ func (d *fsDatasource) GetNamespaces(ctx context.Context) ([]*NamespaceConfig, e
 	return res, nil
 }
 
+// GetIncludeFiles returns the files in the <namespace> dir next to <namespace>.conf, they
+// play the role of the other entries of the ConfigMap
+func (d *fsDatasource) GetIncludeFiles(ctx context.Context, namespace string) (map[string]string, error) {
+	entries, err := os.ReadDir(filepath.Join(d.rootDir, namespace))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string]string{}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+
+		contents, err := os.ReadFile(filepath.Join(d.rootDir, namespace, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		files[e.Name()] = d.render(namespace, string(contents))
+	}
+
+	return files, nil
+}
+
+// render expands the templates of a config of the namespace
+func (d *fsDatasource) render(namespace string, contents string) string {
+	buf := new(strings.Builder)
+	// there are no FluentdPolicies without a cluster, every manifest can be looked up
+	if err := template.Render(buf, contents, map[string]string{
+		"Namespace": namespace,
+	}, &template.Context{
+		Backend:     d.lookups,
+		Namespace:   namespace,
+		AllowLookup: template.AllowAll,
+	}); err != nil {
+		logrus.Errorf("failed to render config in namespace: %v", namespace)
+	}
+
+	return buf.String()
+}
+
 func (d *fsDatasource) WriteCurrentConfigHash(namespace string, hash string) {
 	d.hashes[namespace] = hash
 }
@@ -4,11 +4,14 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"reflect"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/metrics"
 	"github.com/vmware/kube-fluentd-operator/config-reloader/template"
 	"github.com/vmware/kube-fluentd-operator/config-reloader/util"
 
@@ -22,26 +25,48 @@ import (
 	kfoListersV1beta1 "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/client/listers/logs.vdp.vmware.com/v1beta1"
 	core "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	listerv1 "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
 )
 
 type kubeInformerConnection struct {
-	client        kubernetes.Interface
-	confHashes    map[string]string
-	mountedLabels map[string][]map[string]string
-	cfg           *config.Config
-	kubeds        kubedatasource.KubeDS
-	nslist        listerv1.NamespaceLister
-	podlist       listerv1.PodLister
-	cmlist        listerv1.ConfigMapLister
-	fdlist        kfoListersV1beta1.FluentdConfigLister
-	updateChan    chan time.Time
+	client     kubernetes.Interface
+	confHashes map[string]string
+	cfg        *config.Config
+	kubeds     kubedatasource.KubeDS
+	nslist     listerv1.NamespaceLister
+	podlist    listerv1.PodLister
+	cmlist     listerv1.ConfigMapLister
+	fdlist     kfoListersV1beta1.FluentdConfigLister
+	policylist kfoListersV1beta1.FluentdPolicyLister
+	clusterds  *kubedatasource.ClusterFluentdConfigDS
+	lookups    template.Backend
+	updateChan chan time.Time
+	leader     *leaderElection
+	recorder   record.EventRecorder
+	// the selectors of mounted-file by namespace, the pods they match trigger an update. They
+	// are written by the control loop and read by the pod informer
+	selectors     map[string][]util.Selector
+	selectorsLock sync.RWMutex
+	// the watches of the Secrets referenced with $secret, by namespace/name
+	secretWatches map[string]chan struct{}
+	// the last status of every namespace, written again when this replica becomes the leader
+	statusLock sync.Mutex
+	statuses   map[string]*ConfigStatus
+	// the last status of every ClusterFluentdConfig
+	clusterStatuses map[string]*ConfigStatus
 }
 
+var _ ConfigStatusUpdater = &kubeInformerConnection{}
+var _ EventRecorder = &kubeInformerConnection{}
+var _ TemplateContextSource = &kubeInformerConnection{}
+var _ NamespaceSource = &kubeInformerConnection{}
+
 // NewKubernetesInformerDatasource builds a new Datasource from the provided config.
 // The returned Datasource uses Informers to efficiently track objects in the kubernetes
 // API by watching for updates to a known state.
@@ -102,27 +127,68 @@ func NewKubernetesInformerDatasource(ctx context.Context, cfg *config.Config, up
 		}
 	}
 
-	factory.Start(nil)
-	if !cache.WaitForCacheSync(nil,
+	synced := []cache.InformerSynced{
 		factory.Core().V1().Namespaces().Informer().HasSynced,
 		factory.Core().V1().Pods().Informer().HasSynced,
 		factory.Core().V1().ConfigMaps().Informer().HasSynced,
-		kubeds.IsReady) {
+		kubeds.IsReady,
+	}
+
+	var policyLister kfoListersV1beta1.FluentdPolicyLister
+	if cfg.FluentdPolicies {
+		var policiesSynced cache.InformerSynced
+		policyLister, policiesSynced, err = newPolicyLister(ctx, kubeCfg, updateChan)
+		if err != nil {
+			return nil, err
+		}
+		synced = append(synced, policiesSynced)
+	}
+
+	var clusterds *kubedatasource.ClusterFluentdConfigDS
+	if cfg.ClusterConfigs {
+		clusterds, err = kubedatasource.NewClusterFluentdConfigDS(ctx, kubeCfg, updateChan)
+		if err != nil {
+			return nil, err
+		}
+		synced = append(synced, clusterds.IsReady)
+	}
+
+	dyn, err := dynamic.NewForConfig(kubeCfg)
+	if err != nil {
+		return nil, err
+	}
+	lookups := newInformerLookups(ctx, dyn, client.Discovery())
+
+	factory.Start(nil)
+	if !cache.WaitForCacheSync(nil, synced...) {
 		return nil, fmt.Errorf("failed to sync local informer with upstream Kubernetes API")
 	}
 	logrus.Infof("Synced local informer with upstream Kubernetes API")
 
 	kubeInfoCx := &kubeInformerConnection{
-		client:        client,
-		confHashes:    make(map[string]string),
-		mountedLabels: make(map[string][]map[string]string),
-		cfg:           cfg,
-		kubeds:        kubeds,
-		nslist:        namespaceLister,
-		podlist:       podLister,
-		cmlist:        cmLister,
-		fdlist:        fluentdconfigDSLister.Fdlist,
-		updateChan:    updateChan,
+		client:     client,
+		confHashes: make(map[string]string),
+		selectors:  make(map[string][]util.Selector),
+		cfg:        cfg,
+		kubeds:     kubeds,
+		nslist:     namespaceLister,
+		podlist:    podLister,
+		cmlist:     cmLister,
+		fdlist:     fluentdconfigDSLister.Fdlist,
+		policylist: policyLister,
+		clusterds:  clusterds,
+		lookups:    lookups,
+		updateChan: updateChan,
+	}
+
+	if cfg.LeaderElect {
+		kubeInfoCx.recorder = newEventRecorder(client)
+		kubeInfoCx.leader, err = startLeaderElection(ctx, cfg, client, kubeInfoCx.writeAllConfigStatuses)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		logrus.Infof("Events are not recorded without --leader-elect")
 	}
 
 	factory.Core().V1().Pods().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
@@ -130,6 +196,7 @@ func NewKubernetesInformerDatasource(ctx context.Context, cfg *config.Config, up
 			kubeInfoCx.handlePodChange(ctx, obj)
 		},
 		UpdateFunc: func(old, obj interface{}) {
+			kubeInfoCx.handlePodUpdate(ctx, old, obj)
 		},
 		DeleteFunc: func(obj interface{}) {
 			kubeInfoCx.handlePodChange(ctx, obj)
@@ -150,6 +217,7 @@ func (d *kubeInformerConnection) GetNamespaces(ctx context.Context) ([]*Namespac
 	}
 
 	nsconfigs := make([]*NamespaceConfig, 0)
+	referencedSecrets := map[string]bool{}
 	for _, ns := range nses {
 		// Get the Namespace object associated with a particular name
 		nsobj, err := d.nslist.Get(ns)
@@ -157,15 +225,6 @@ func (d *kubeInformerConnection) GetNamespaces(ctx context.Context) ([]*Namespac
 			return nil, err
 		}
 
-		if d.cfg.AllowLabel != "" {
-			template.SetAllowLabel(d.cfg.AllowLabel)
-		}
-		if d.cfg.AllowLabelAnnotation != "" {
-			if label := nsobj.GetAnnotations()[d.cfg.AllowLabelAnnotation]; label != "" {
-				template.SetAllowLabel(label)
-			}
-		}
-
 		configdata, err := d.kubeds.GetFluentdConfig(ctx, ns)
 		if err != nil {
 			return nil, err
@@ -173,7 +232,7 @@ func (d *kubeInformerConnection) GetNamespaces(ctx context.Context) ([]*Namespac
 		buf := new(strings.Builder)
 		if err := template.Render(buf, configdata, map[string]string{
 			"Namespace": ns,
-		}); err != nil {
+		}, d.TemplateContext(ctx, ns)); err != nil {
 			logrus.Errorf("failed to render config in namespace: %v", ns)
 		}
 		configdata = buf.String()
@@ -181,26 +240,22 @@ func (d *kubeInformerConnection) GetNamespaces(ctx context.Context) ([]*Namespac
 			logrus.Infof("Skipping namespace: %v because is empty", ns)
 			continue
 		}
-		fragment, err := fluentd.ParseString(configdata)
+		includes, err := d.GetIncludeFiles(ctx, ns)
+		if err != nil {
+			return nil, err
+		}
+		fragment, err := fluentd.ParseWithIncludes("", configdata, includes)
 		if err != nil {
 			logrus.Errorf("Error parsing config for ns %s: %v", ns, err)
 			continue
 		}
 
-		var mountedLabels []map[string]string
-		for _, frag := range fragment {
-			if frag.Name == "source" && frag.Type() == "mounted-file" {
-				paramLabels := frag.Param("labels")
-				paramLabels = util.TrimTrailingComment(paramLabels)
-				currLabels, err := util.ParseTagToLabels(fmt.Sprintf("$labels(%s)", paramLabels))
-				if err != nil {
-					return nil, err
-				}
-				mountedLabels = append(mountedLabels, currLabels)
-			}
+		selectors, err := podSelectors(fragment)
+		if err != nil {
+			return nil, err
 		}
 
-		d.updateMountedLabels(ns, mountedLabels)
+		d.updateSelectors(ns, selectors)
 
 		// Create a compact representation of the pods running in the namespace
 		// under consideration
@@ -218,6 +273,14 @@ func (d *kubeInformerConnection) GetNamespaces(ctx context.Context) ([]*Namespac
 		}
 		minis := convertPodToMinis(podList)
 
+		var secrets []string
+		if d.cfg.AllowSecrets {
+			secrets = ReferencedSecrets(fragment)
+			for _, name := range secrets {
+				referencedSecrets[ns+"/"+name] = true
+			}
+		}
+
 		// Create a new NamespaceConfig from the data we've processed up to now
 		nsconfigs = append(nsconfigs, &NamespaceConfig{
 			Name:               ns,
@@ -225,10 +288,24 @@ func (d *kubeInformerConnection) GetNamespaces(ctx context.Context) ([]*Namespac
 			PreviousConfigHash: d.confHashes[ns],
 			Labels:             nsobj.Labels,
 			MiniContainers:     minis,
+			Policies:           d.GetPolicies(ctx, ns),
+			Secrets:            d.GetSecrets(ctx, ns, secrets),
+			IncludeFiles:       includes,
 		})
 	}
 
-	return nsconfigs, nil
+	if d.cfg.AllowSecrets {
+		d.watchSecrets(referencedSecrets)
+	}
+
+	nsconfigs, err = d.addClusterConfigs(ctx, nsconfigs)
+
+	// every template was rendered, the informers no lookup needed are stopped
+	if pruner, ok := d.lookups.(interface{ Prune() }); ok {
+		pruner.Prune()
+	}
+
+	return nsconfigs, err
 }
 
 // WriteCurrentConfigHash is a setter for the hashtable maintained by this Datasource
@@ -236,16 +313,29 @@ func (d *kubeInformerConnection) WriteCurrentConfigHash(namespace string, hash s
 	d.confHashes[namespace] = hash
 }
 
-func (d *kubeInformerConnection) updateMountedLabels(namespace string, labels []map[string]string) {
-	d.mountedLabels[namespace] = labels
+func (d *kubeInformerConnection) updateSelectors(namespace string, selectors []util.Selector) {
+	d.selectorsLock.Lock()
+	defer d.selectorsLock.Unlock()
+
+	d.selectors[namespace] = selectors
 }
 
 // UpdateStatus updates a namespace's status annotation with the latest result
 // from the config generator.
 func (d *kubeInformerConnection) UpdateStatus(ctx context.Context, namespace string, status string) {
+	if !d.leader.IsLeader() {
+		logrus.Debugf("Not the leader, skipping status update of namespace %s", namespace)
+		return
+	}
+
+	d.writeStatusAnnotation(ctx, namespace, status)
+}
+
+func (d *kubeInformerConnection) writeStatusAnnotation(ctx context.Context, namespace string, status string) {
 	ns, err := d.client.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
 	if err != nil {
 		logrus.Infof("Cannot find namespace to update status for: %v", namespace)
+		return
 	}
 
 	// update annotations
@@ -283,6 +373,139 @@ func (d *kubeInformerConnection) UpdateStatus(ctx context.Context, namespace str
 	}
 }
 
+// UpdateConfigStatus updates the status annotation of the namespace and, when
+// FluentdConfigs are used, the status subresource of the FluentdConfigs in it. An event
+// is recorded when the config fails or becomes valid again.
+func (d *kubeInformerConnection) UpdateConfigStatus(ctx context.Context, namespace string, status *ConfigStatus) {
+	d.statusLock.Lock()
+	if d.statuses == nil {
+		d.statuses = map[string]*ConfigStatus{}
+	}
+	previous := d.statuses[namespace]
+	d.statuses[namespace] = status
+	d.statusLock.Unlock()
+
+	if !d.leader.IsLeader() {
+		logrus.Debugf("Not the leader, skipping status update of namespace %s", namespace)
+		return
+	}
+
+	d.writeConfigStatus(ctx, namespace, status)
+	d.recordStatusEvent(ctx, namespace, previous, status)
+}
+
+func (d *kubeInformerConnection) writeConfigStatus(ctx context.Context, namespace string, status *ConfigStatus) {
+	d.writeStatusAnnotation(ctx, namespace, status.Message)
+
+	if sw, ok := d.kubeds.(kubedatasource.StatusWriter); ok {
+		sw.WriteConfigStatus(ctx, namespace, status.FailedCondition, status.Message, status.ConfigHash)
+	}
+}
+
+// writeAllConfigStatuses writes the last known status of every namespace. The previous
+// leader may have stopped before writing them.
+func (d *kubeInformerConnection) writeAllConfigStatuses(ctx context.Context) {
+	d.statusLock.Lock()
+	statuses := make(map[string]*ConfigStatus, len(d.statuses))
+	for ns, status := range d.statuses {
+		statuses[ns] = status
+	}
+	clusterStatuses := make(map[string]*ConfigStatus, len(d.clusterStatuses))
+	for name, status := range d.clusterStatuses {
+		clusterStatuses[name] = status
+	}
+	d.statusLock.Unlock()
+
+	for ns, status := range statuses {
+		d.writeConfigStatus(ctx, ns, status)
+	}
+	for name, status := range clusterStatuses {
+		d.clusterds.WriteConfigStatus(ctx, name, status.FailedCondition, status.Message, status.ConfigHash)
+	}
+}
+
+// GetFluentdConfig returns the current fluentd config of the namespace as read by
+// GetNamespaces, before any processing
+func (d *kubeInformerConnection) GetFluentdConfig(ctx context.Context, namespace string) (string, error) {
+	configdata, err := d.kubeds.GetFluentdConfig(ctx, namespace)
+	if err != nil {
+		return "", err
+	}
+
+	buf := new(strings.Builder)
+	if err := template.Render(buf, configdata, map[string]string{
+		"Namespace": namespace,
+	}, d.TemplateContext(ctx, namespace)); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// GetIncludeFiles returns the other files of the Kubernetes Resources holding the config of
+// the namespace, rendered like the config
+func (d *kubeInformerConnection) GetIncludeFiles(ctx context.Context, namespace string) (map[string]string, error) {
+	is, ok := d.kubeds.(kubedatasource.IncludeSource)
+	if !ok {
+		return nil, nil
+	}
+
+	files, err := is.GetIncludeFiles(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, contents := range files {
+		buf := new(strings.Builder)
+		if err := template.Render(buf, contents, map[string]string{
+			"Namespace": namespace,
+		}, d.TemplateContext(ctx, namespace)); err != nil {
+			logrus.Errorf("failed to render file %s in namespace: %v", name, namespace)
+		}
+		files[name] = buf.String()
+	}
+
+	return files, nil
+}
+
+// GetNamespaceAnnotations returns the annotations of the namespace from the informer cache
+func (d *kubeInformerConnection) GetNamespaceAnnotations(ctx context.Context, namespace string) (map[string]string, error) {
+	nsobj, err := d.nslist.Get(namespace)
+	if err != nil {
+		return nil, err
+	}
+	return nsobj.GetAnnotations(), nil
+}
+
+// TemplateContext limits k8sLookup to the namespace and to what its FluentdPolicies allow,
+// the admin namespace can read everything. The allow label of the namespace annotation
+// takes precedence over --allow-label.
+func (d *kubeInformerConnection) TemplateContext(ctx context.Context, namespace string) *template.Context {
+	tctx := &template.Context{
+		Backend:    d.lookups,
+		Namespace:  namespace,
+		AllowLabel: d.cfg.AllowLabel,
+	}
+
+	if d.cfg.AllowLabelAnnotation != "" {
+		if nsobj, err := d.nslist.Get(namespace); err == nil {
+			if label := nsobj.GetAnnotations()[d.cfg.AllowLabelAnnotation]; label != "" {
+				tctx.AllowLabel = label
+			}
+		}
+	}
+
+	if namespace == d.cfg.AdminNamespace {
+		tctx.AllowLookup = template.AllowAll
+	} else {
+		// the informers of the kinds a namespace can read are started on every node
+		tctx.Kinds = d.cfg.LookupKinds
+		tctx.AllowLookup = AllowLookup(d.GetPolicies(ctx, namespace))
+	}
+
+	return tctx
+}
+
 // discoverNamespaces constructs a list of namespaces to inspect for fluentd
 // configuration, using the configured list if provided, or find namespaces based on labels if provided in --namespace-selector flag, otherwise find only
 // namespaces that have fluentd configmaps based on default name, and if that fails
@@ -371,43 +594,50 @@ func (d *kubeInformerConnection) discoverNamespaces(ctx context.Context) ([]stri
 func (d *kubeInformerConnection) handlePodChange(ctx context.Context, obj interface{}) {
 	mObj := obj.(*core.Pod)
 	logrus.Tracef("Detected pod change %s in namespace: %s", mObj.GetName(), mObj.GetNamespace())
-	configdata, err := d.kubeds.GetFluentdConfig(ctx, mObj.GetNamespace())
-	buf := new(strings.Builder)
-	if err := template.Render(buf, configdata, map[string]string{
-		"Namespace": mObj.GetNamespace(),
-	}); err != nil {
-		logrus.Errorf("failed to render config in namespace: %v", mObj.GetNamespace())
-	}
-	configdata = buf.String()
-	nsConfigStr := fmt.Sprintf("%#v", configdata)
-
-	if err == nil {
-		if strings.Contains(nsConfigStr, "mounted-file") {
-			podLabels := mObj.GetLabels()
-			mountedLabel := d.mountedLabels[mObj.GetNamespace()]
-			for _, container := range mObj.Spec.Containers {
-				if matchAny(podLabels, mountedLabel, container.Name) {
-					logrus.Infof("Detected mounted-file pod change %s in namespace: %s", mObj.GetName(), mObj.GetNamespace())
-					select {
-					case d.updateChan <- time.Now():
-					default:
-					}
-				}
-			}
-		}
+
+	if d.podSelected(mObj) {
+		d.notifyPodChange(mObj)
 	}
 }
 
-func matchAny(contLabels map[string]string, mountedLabelsInNs []map[string]string, name string) bool {
-	for _, mountedLabels := range mountedLabelsInNs {
-		if util.Match(mountedLabels, contLabels, name) {
+// handlePodUpdate only reacts to the changes the selectors can see
+func (d *kubeInformerConnection) handlePodUpdate(ctx context.Context, old interface{}, obj interface{}) {
+	oldPod, mObj := old.(*core.Pod), obj.(*core.Pod)
+	if reflect.DeepEqual(oldPod.Labels, mObj.Labels) && reflect.DeepEqual(oldPod.Annotations, mObj.Annotations) {
+		return
+	}
+
+	if d.podSelected(oldPod) || d.podSelected(mObj) {
+		d.notifyPodChange(mObj)
+	}
+}
+
+// podSelected tells if a container of the pod is matched by a mounted-file
+func (d *kubeInformerConnection) podSelected(pod *core.Pod) bool {
+	d.selectorsLock.RLock()
+	selectors := d.selectors[pod.GetNamespace()]
+	d.selectorsLock.RUnlock()
+	if len(selectors) == 0 {
+		return false
+	}
+
+	for i := range pod.Spec.Containers {
+		if matchAny(selectors, podTarget(pod, &pod.Spec.Containers[i])) {
 			return true
 		}
 	}
-
 	return false
 }
 
+func (d *kubeInformerConnection) notifyPodChange(pod *core.Pod) {
+	logrus.Infof("Detected selected pod change %s in namespace: %s", pod.GetName(), pod.GetNamespace())
+	metrics.IncInformerUpdates("pod")
+	select {
+	case d.updateChan <- time.Now():
+	default:
+	}
+}
+
 func (d *kubeInformerConnection) discoverFluentdConfigNamespaces() ([]string, error) {
 	if d.fdlist == nil {
 		return nil, fmt.Errorf("failed to initialize the fluentdconfig crd client, d.fclient = nil")
@@ -9,7 +9,12 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/vmware/kube-fluentd-operator/config-reloader/config"
 	"github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource"
+	kfo "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/apis/logs.vdp.vmware.com/v1beta1"
+	kfoFake "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/client/clientset/versioned/fake"
+	kfoInformers "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/client/informers/externalversions"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/util"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/informers"
@@ -159,13 +164,13 @@ func TestGetNamespaces(t *testing.T) {
 		factory := informers.NewSharedInformerFactory(clientset, 0)
 		kubeds, _ := kubedatasource.NewConfigMapDS(ctx, &config.config, factory, make(chan time.Time, 1))
 		var ds = &kubeInformerConnection{
-			client:        clientset,
-			cfg:           &config.config,
-			nslist:        factory.Core().V1().Namespaces().Lister(),
-			cmlist:        factory.Core().V1().ConfigMaps().Lister(),
-			podlist:       factory.Core().V1().Pods().Lister(),
-			kubeds:        kubeds,
-			mountedLabels: make(map[string][]map[string]string),
+			client:    clientset,
+			cfg:       &config.config,
+			nslist:    factory.Core().V1().Namespaces().Lister(),
+			cmlist:    factory.Core().V1().ConfigMaps().Lister(),
+			podlist:   factory.Core().V1().Pods().Lister(),
+			kubeds:    kubeds,
+			selectors: make(map[string][]util.Selector),
 		}
 		importK8sObjects(factory, config.namespaces, config.configmap, config.configmapData)
 		// Run Test GetNamespace
@@ -350,3 +355,112 @@ func TestUpdateStatus(t *testing.T) {
 	}
 	assert.Equal(ns.Annotations[testCfg.AnnotStatus], annotationValue)
 }
+
+func TestUpdateConfigStatus(t *testing.T) {
+	assert := assert.New(t)
+	namespace := "test-namespace"
+	testCfg := &config.Config{
+		Datasource:  "crd",
+		AnnotStatus: "logging.csp.vmware.com/fluentd-status",
+		ID:          "default",
+	}
+	ctx := context.Background()
+	clientset := testclient.NewSimpleClientset(
+		&corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: namespace,
+			},
+		},
+	)
+	fc := &kfo.FluentdConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "fd-config",
+			Namespace:  namespace,
+			Generation: 3,
+		},
+	}
+	kfoClientset := kfoFake.NewSimpleClientset(fc)
+	kfoFactory := kfoInformers.NewSharedInformerFactory(kfoClientset, 0)
+	kfoFactory.Logs().V1beta1().FluentdConfigs().Informer().GetIndexer().Add(fc)
+
+	var ds = &kubeInformerConnection{
+		client: clientset,
+		cfg:    testCfg,
+		kubeds: &kubedatasource.FluentdConfigDS{
+			Cfg:      testCfg,
+			Fdclient: kfoClientset,
+			Fdlist:   kfoFactory.Logs().V1beta1().FluentdConfigs().Lister(),
+		},
+	}
+
+	ds.UpdateConfigStatus(ctx, namespace, &ConfigStatus{
+		FailedCondition: ConditionProcessed,
+		Message:         "cannot use <source> directive",
+		ConfigHash:      "abc",
+	})
+
+	ns, err := clientset.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	assert.Nil(err)
+	assert.Equal("cannot use <source> directive", ns.Annotations[testCfg.AnnotStatus])
+
+	updated, err := kfoClientset.LogsV1beta1().FluentdConfigs(namespace).Get(ctx, "fd-config", metav1.GetOptions{})
+	assert.Nil(err)
+	assert.Equal(int64(3), updated.Status.ObservedGeneration)
+	assert.True(meta.IsStatusConditionTrue(updated.Status.Conditions, ConditionParsed))
+	assert.True(meta.IsStatusConditionFalse(updated.Status.Conditions, ConditionProcessed))
+	assert.Equal("cannot use <source> directive", meta.FindStatusCondition(updated.Status.Conditions, ConditionProcessed).Message)
+	assert.Equal(metav1.ConditionUnknown, meta.FindStatusCondition(updated.Status.Conditions, ConditionApplied).Status)
+	assert.Nil(updated.Status.LastAppliedTime)
+
+	ds.UpdateConfigStatus(ctx, namespace, &ConfigStatus{
+		ConfigHash: "def",
+	})
+
+	updated, err = kfoClientset.LogsV1beta1().FluentdConfigs(namespace).Get(ctx, "fd-config", metav1.GetOptions{})
+	assert.Nil(err)
+	assert.True(meta.IsStatusConditionTrue(updated.Status.Conditions, ConditionApplied))
+	assert.Equal("def", updated.Status.ConfigHash)
+	assert.NotNil(updated.Status.LastAppliedTime)
+}
+
+func TestOnlyLeaderWritesStatus(t *testing.T) {
+	assert := assert.New(t)
+	namespace := "test-namespace"
+	testCfg := &config.Config{
+		Datasource:  "default",
+		AnnotStatus: "logging.csp.vmware.com/fluentd-status",
+		ID:          "default",
+	}
+	ctx := context.Background()
+	clientset := testclient.NewSimpleClientset(
+		&corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: namespace,
+			},
+		},
+	)
+
+	var ds = &kubeInformerConnection{
+		client: clientset,
+		cfg:    testCfg,
+		kubeds: &kubedatasource.ConfigMapDS{},
+		leader: &leaderElection{},
+	}
+
+	ds.UpdateConfigStatus(ctx, namespace, &ConfigStatus{
+		FailedCondition: ConditionProcessed,
+		Message:         "cannot use <source> directive",
+	})
+
+	ns, err := clientset.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	assert.Nil(err)
+	assert.Empty(ns.Annotations[testCfg.AnnotStatus])
+
+	// the last status is written once this replica leads
+	ds.leader.leading.Store(true)
+	ds.writeAllConfigStatuses(ctx)
+
+	ns, err = clientset.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	assert.Nil(err)
+	assert.Equal("cannot use <source> directive", ns.Annotations[testCfg.AnnotStatus])
+}
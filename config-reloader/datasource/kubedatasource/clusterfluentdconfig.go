@@ -0,0 +1,128 @@
+package kubedatasource
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	kfo "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/apis/logs.vdp.vmware.com/v1beta1"
+	kfoClient "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/client/clientset/versioned"
+	kfoInformers "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/client/informers/externalversions"
+	kfoListersV1beta1 "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/client/listers/logs.vdp.vmware.com/v1beta1"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/crd"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/metrics"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ClusterFluentdConfigDS reads the cluster-scoped ClusterFluentdConfigs, they hold
+// the config of the admin namespace together with its ConfigMap or FluentdConfigs
+type ClusterFluentdConfigDS struct {
+	Client     kfoClient.Interface
+	Lister     kfoListersV1beta1.ClusterFluentdConfigLister
+	Ready      func() bool
+	UpdateChan chan time.Time
+}
+
+func NewClusterFluentdConfigDS(ctx context.Context, kubeCfg *rest.Config, updateChan chan time.Time) (*ClusterFluentdConfigDS, error) {
+	kfocli, err := kfoClient.NewForConfig(kubeCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	factory := kfoInformers.NewSharedInformerFactory(kfocli, 0)
+	informer := factory.Logs().V1beta1().ClusterFluentdConfigs().Informer()
+
+	ds := &ClusterFluentdConfigDS{
+		Client:     kfocli,
+		Lister:     factory.Logs().V1beta1().ClusterFluentdConfigs().Lister(),
+		Ready:      informer.HasSynced,
+		UpdateChan: updateChan,
+	}
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: ds.handleChange,
+		UpdateFunc: func(old, new interface{}) {
+			// status updates do not change the config
+			if o, ok := old.(*kfo.ClusterFluentdConfig); ok {
+				if n, ok := new.(*kfo.ClusterFluentdConfig); ok && o.Generation == n.Generation {
+					return
+				}
+			}
+			ds.handleChange(new)
+		},
+		DeleteFunc: ds.handleChange,
+	})
+
+	if err := crd.CheckAndInstallClusterConfigCRD(ctx, kubeCfg); err != nil {
+		return nil, err
+	}
+
+	factory.Start(nil)
+
+	return ds, nil
+}
+
+// IsReady returns a boolean specifying whether the ClusterFluentdConfigDS is ready
+func (c *ClusterFluentdConfigDS) IsReady() bool {
+	return c.Ready()
+}
+
+// GetClusterFluentdConfigs returns the ClusterFluentdConfigs sorted by Spec.Order and then by name
+func (c *ClusterFluentdConfigDS) GetClusterFluentdConfigs() ([]*kfo.ClusterFluentdConfig, error) {
+	configs, err := c.Lister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	SortClusterFluentdConfigs(configs)
+	return configs, nil
+}
+
+// SortClusterFluentdConfigs sorts the configs in the order they are merged
+func SortClusterFluentdConfigs(configs []*kfo.ClusterFluentdConfig) {
+	sort.SliceStable(configs, func(i, j int) bool {
+		if configs[i].Spec.Order != configs[j].Spec.Order {
+			return configs[i].Spec.Order < configs[j].Spec.Order
+		}
+		return configs[i].Name < configs[j].Name
+	})
+}
+
+// WriteConfigStatus stores the outcome of the config generation in the status subresource
+// of the ClusterFluentdConfig
+func (c *ClusterFluentdConfigDS) WriteConfigStatus(ctx context.Context, name string, failedCondition string, message string, configHash string) {
+	orig, err := c.Lister.Get(name)
+	if err != nil {
+		logrus.Infof("Cannot find clusterfluentdconfig %s to update status: %+v", name, err)
+		return
+	}
+
+	cc := orig.DeepCopy()
+	setConditions(&cc.Status, cc.Generation, failedCondition, message)
+	cc.Status.ObservedGeneration = cc.Generation
+	if failedCondition == "" {
+		now := metav1.Now()
+		cc.Status.ConfigHash = configHash
+		cc.Status.LastAppliedTime = &now
+	}
+
+	_, err = c.Client.LogsV1beta1().ClusterFluentdConfigs().UpdateStatus(ctx, cc, metav1.UpdateOptions{})
+	logrus.Debugf("Saving status of clusterfluentdconfig %s: %+v", name, err)
+	if err != nil && !errors.IsConflict(err) {
+		logrus.Infof("Cannot set status on clusterfluentdconfig %s: %+v", name, err)
+	}
+}
+
+func (c *ClusterFluentdConfigDS) handleChange(obj interface{}) {
+	metrics.IncInformerUpdates("clusterfluentdconfig")
+	select {
+	case c.UpdateChan <- time.Now():
+	default:
+	}
+}
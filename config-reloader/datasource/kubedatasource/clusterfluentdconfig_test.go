@@ -0,0 +1,32 @@
+package kubedatasource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	kfo "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/apis/logs.vdp.vmware.com/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSortClusterFluentdConfigs(t *testing.T) {
+	makeConfig := func(name string, order int32) *kfo.ClusterFluentdConfig {
+		return &kfo.ClusterFluentdConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       kfo.ClusterFluentdConfigSpec{Order: order},
+		}
+	}
+
+	configs := []*kfo.ClusterFluentdConfig{
+		makeConfig("security", 10),
+		makeConfig("outputs", -5),
+		makeConfig("audit", 10),
+		makeConfig("defaults", 0),
+	}
+	SortClusterFluentdConfigs(configs)
+
+	names := []string{}
+	for _, c := range configs {
+		names = append(names, c.Name)
+	}
+	assert.Equal(t, []string{"outputs", "defaults", "audit", "security"}, names)
+}
@@ -10,9 +10,11 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/vmware/kube-fluentd-operator/config-reloader/config"
 	kfoListersV1beta1 "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/client/listers/logs.vdp.vmware.com/v1beta1"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/metrics"
 	core "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/informers"
 	listerv1 "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
@@ -89,6 +91,38 @@ func (c *ConfigMapDS) GetFluentdConfig(ctx context.Context, namespace string) (s
 	return c.readConfig(configmaps), nil
 }
 
+// GetIncludeFiles returns the entries of the configured ConfigMaps other than fluent.conf
+func (c *ConfigMapDS) GetIncludeFiles(ctx context.Context, namespace string) (map[string]string, error) {
+	configmaps, err := c.fetchConfigMaps(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string]string{}
+	for _, cm := range configmaps {
+		for k, v := range cm.Data {
+			if k != entryName {
+				files[k] = v
+			}
+		}
+	}
+	return files, nil
+}
+
+// ListConfigObjects returns the ConfigMaps the config of the namespace is read from
+func (c *ConfigMapDS) ListConfigObjects(ctx context.Context, namespace string) []runtime.Object {
+	configmaps, err := c.fetchConfigMaps(ctx, namespace)
+	if err != nil {
+		return nil
+	}
+
+	objects := make([]runtime.Object, 0, len(configmaps))
+	for _, cm := range configmaps {
+		objects = append(objects, cm)
+	}
+	return objects
+}
+
 func (c *ConfigMapDS) fetchConfigMaps(ctx context.Context, ns string) ([]*core.ConfigMap, error) {
 	configmaps := make([]*core.ConfigMap, 0)
 	nsmaps := c.cfglist.ConfigMaps(ns)
@@ -216,6 +250,7 @@ func (c *ConfigMapDS) handleCMChange(ctx context.Context, obj interface{}) {
 		}
 	}
 
+	metrics.IncInformerUpdates("configmap")
 	select {
 	case c.updateChan <- time.Now():
 	default:
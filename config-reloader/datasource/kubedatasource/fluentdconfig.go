@@ -2,6 +2,7 @@ package kubedatasource
 
 import (
 	"context"
+	"fmt"
 	"sort"
 	"strings"
 	"time"
@@ -13,15 +14,20 @@ import (
 	kfoInformers "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/client/informers/externalversions"
 	kfoListersV1beta1 "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/client/listers/logs.vdp.vmware.com/v1beta1"
 	"github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/crd"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/metrics"
 
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 )
 
 type FluentdConfigDS struct {
 	Cfg        *config.Config
+	Fdclient   kfoClient.Interface
 	Fdlist     kfoListersV1beta1.FluentdConfigLister
 	Fdready    func() bool
 	UpdateChan chan time.Time
@@ -38,6 +44,7 @@ func NewFluentdConfigDS(ctx context.Context, cfg *config.Config, kubeCfg *rest.C
 
 	fdDS := &FluentdConfigDS{
 		Cfg:        cfg,
+		Fdclient:   kfocli,
 		Fdlist:     fluentdConfigLister,
 		Fdready:    factory.Logs().V1beta1().FluentdConfigs().Informer().HasSynced,
 		UpdateChan: updateChan,
@@ -93,13 +100,142 @@ func (f *FluentdConfigDS) GetFluentdConfig(ctx context.Context, namespace string
 	for _, name := range sortedFluentdConfigs {
 		fd := fcByName[name]
 		logrus.Debugf("loaded config data from fluentdconfig: %s/%s", fd.ObjectMeta.Namespace, fd.ObjectMeta.Name)
-		configData = append(configData, fd.Spec.FluentConf)
+		conf, err := RenderFluentdConfigSpec(&fd.Spec)
+		if err != nil {
+			// a broken structured spec must not prevent the other namespaces from being configured
+			logrus.Warnf("Skipping fluentdconfig %s/%s with invalid spec: %+v", fd.ObjectMeta.Namespace, fd.ObjectMeta.Name, err)
+			// the config of the namespace does not change, so the generation will not report it
+			f.writeParseError(ctx, fd, err)
+			continue
+		}
+		configData = append(configData, conf)
 	}
 
 	// Concatenate all namespace's configs
 	return strings.Join(configData, "\n"), nil
 }
 
+// GetIncludeFiles returns the includes of the FluentdConfigs of the namespace
+func (f *FluentdConfigDS) GetIncludeFiles(ctx context.Context, namespace string) (map[string]string, error) {
+	fluentdConfigs, err := f.Fdlist.FluentdConfigs(namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(fluentdConfigs, func(i, j int) bool {
+		return fluentdConfigs[i].Name < fluentdConfigs[j].Name
+	})
+
+	files := map[string]string{}
+	for _, fd := range fluentdConfigs {
+		for k, v := range fd.Spec.Includes {
+			files[k] = v
+		}
+	}
+	return files, nil
+}
+
+// ListConfigObjects returns the FluentdConfigs of the namespace
+func (f *FluentdConfigDS) ListConfigObjects(ctx context.Context, namespace string) []runtime.Object {
+	fluentdConfigs, err := f.Fdlist.FluentdConfigs(namespace).List(labels.Everything())
+	if err != nil {
+		return nil
+	}
+
+	objects := make([]runtime.Object, 0, len(fluentdConfigs))
+	for _, fc := range fluentdConfigs {
+		objects = append(objects, fc)
+	}
+	return objects
+}
+
+// conditionOrder lists the conditions in the order they are evaluated, once one fails
+// the following ones cannot be determined
+var conditionOrder = []string{
+	kfo.ConditionParsed,
+	kfo.ConditionProcessed,
+	kfo.ConditionValidated,
+	kfo.ConditionApplied,
+}
+
+// WriteConfigStatus stores the outcome of the config generation in the status
+// subresource of every FluentdConfig in the namespace
+func (f *FluentdConfigDS) WriteConfigStatus(ctx context.Context, namespace string, failedCondition string, message string, configHash string) {
+	fluentdConfigs, err := f.Fdlist.FluentdConfigs(namespace).List(labels.Everything())
+	if err != nil {
+		logrus.Infof("Cannot list fluentdconfigs to update status for namespace %s: %+v", namespace, err)
+		return
+	}
+
+	now := metav1.Now()
+	for _, orig := range fluentdConfigs {
+		fc := orig.DeepCopy()
+		failed, msg := failedCondition, message
+		if _, err := RenderFluentdConfigSpec(&fc.Spec); err != nil {
+			// GetFluentdConfig skipped it, the outcome of the namespace does not apply
+			failed, msg = kfo.ConditionParsed, err.Error()
+		}
+		setConditions(&fc.Status, fc.Generation, failed, msg)
+		fc.Status.ObservedGeneration = fc.Generation
+		if failed == "" {
+			fc.Status.ConfigHash = configHash
+			fc.Status.LastAppliedTime = &now
+		}
+
+		_, err := f.Fdclient.LogsV1beta1().FluentdConfigs(namespace).UpdateStatus(ctx, fc, metav1.UpdateOptions{})
+		logrus.Debugf("Saving status of fluentdconfig %s/%s: %+v", namespace, fc.Name, err)
+		// a conflict means another log-router already updated the object, see kubeInformerConnection.UpdateStatus
+		if err != nil && !errors.IsConflict(err) {
+			logrus.Infof("Cannot set status on fluentdconfig %s/%s: %+v", namespace, fc.Name, err)
+		}
+	}
+}
+
+// writeParseError sets the Parsed condition of a FluentdConfig to False unless its status
+// already reports the error for the current generation
+func (f *FluentdConfigDS) writeParseError(ctx context.Context, orig *kfo.FluentdConfig, parseErr error) {
+	cond := meta.FindStatusCondition(orig.Status.Conditions, kfo.ConditionParsed)
+	if orig.Status.ObservedGeneration == orig.Generation && cond != nil &&
+		cond.Status == metav1.ConditionFalse && cond.Message == parseErr.Error() {
+		return
+	}
+
+	fc := orig.DeepCopy()
+	setConditions(&fc.Status, fc.Generation, kfo.ConditionParsed, parseErr.Error())
+	fc.Status.ObservedGeneration = fc.Generation
+
+	_, err := f.Fdclient.LogsV1beta1().FluentdConfigs(fc.Namespace).UpdateStatus(ctx, fc, metav1.UpdateOptions{})
+	if err != nil && !errors.IsConflict(err) {
+		logrus.Infof("Cannot set status on fluentdconfig %s/%s: %+v", fc.Namespace, fc.Name, err)
+	}
+}
+
+func setConditions(status *kfo.FluentdConfigStatus, generation int64, failedCondition string, message string) {
+	conditionStatus := metav1.ConditionTrue
+	for _, t := range conditionOrder {
+		cond := metav1.Condition{
+			Type:               t,
+			ObservedGeneration: generation,
+		}
+
+		switch {
+		case t == failedCondition:
+			cond.Status = metav1.ConditionFalse
+			cond.Reason = "Not" + t
+			cond.Message = message
+			conditionStatus = metav1.ConditionUnknown
+		case conditionStatus == metav1.ConditionUnknown:
+			cond.Status = metav1.ConditionUnknown
+			cond.Reason = "Skipped"
+			cond.Message = fmt.Sprintf("Not evaluated because %s is False", failedCondition)
+		default:
+			cond.Status = metav1.ConditionTrue
+			cond.Reason = t
+		}
+
+		meta.SetStatusCondition(&status.Conditions, cond)
+	}
+}
+
 // handleFDChange reacts to changes in the FluentdConfigs k8s resources and notifies the
 // main controller to re-run the main loop and sync the state
 func (f *FluentdConfigDS) handleFDChange(obj interface{}) {
@@ -136,6 +272,7 @@ func (f *FluentdConfigDS) handleFDChange(obj interface{}) {
 		}
 	}
 
+	metrics.IncInformerUpdates("fluentdconfig")
 	select {
 	case f.UpdateChan <- time.Now():
 	default:
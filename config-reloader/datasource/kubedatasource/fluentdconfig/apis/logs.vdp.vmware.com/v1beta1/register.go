@@ -29,8 +29,12 @@ func Resource(resource string) schema.GroupResource {
 // Adds the list of known types to the given scheme.
 func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(SchemeGroupVersion,
+		&ClusterFluentdConfig{},
+		&ClusterFluentdConfigList{},
 		&FluentdConfig{},
 		&FluentdConfigList{},
+		&FluentdPolicy{},
+		&FluentdPolicyList{},
 	)
 
 	scheme.AddKnownTypes(SchemeGroupVersion,
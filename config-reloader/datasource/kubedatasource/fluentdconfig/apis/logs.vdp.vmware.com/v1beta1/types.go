@@ -4,6 +4,42 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterFluentdConfig holds cluster-wide config like the ConfigMap of the admin namespace.
+// All ClusterFluentdConfigs are merged after the config of the admin namespace, in the
+// order of Spec.Order and then of their names.
+type ClusterFluentdConfig struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ClusterFluentdConfigSpec `json:"spec,omitempty"`
+	// +optional
+	Status FluentdConfigStatus `json:"status,omitempty"`
+}
+
+// ClusterFluentdConfigSpec is a part of the config of the admin namespace
+type ClusterFluentdConfigSpec struct {
+	// Order positions the config among the others, lower first
+	// +optional
+	Order      int32  `json:"order,omitempty"`
+	FluentConf string `json:"fluentconf,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterFluentdConfigList is the mandatory plural type
+type ClusterFluentdConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []ClusterFluentdConfig `json:"items"`
+}
+
 // +genclient
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
@@ -14,11 +50,137 @@ type FluentdConfig struct {
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
 	Spec FluentdConfigSpec `json:"spec,omitempty"`
+	// +optional
+	Status FluentdConfigStatus `json:"status,omitempty"`
 }
 
-// FluentdConfigSpec implements the fluent.conf file as CRD
+// FluentdConfigSpec implements the fluent.conf file as CRD.
+// The config can be given as raw text in FluentConf or declared with the structured
+// fields, or both: the structured fields are rendered after FluentConf in the order
+// MountedFiles, Filters, Shares, Outputs and Receivers.
 type FluentdConfigSpec struct {
 	FluentConf string `json:"fluentconf,omitempty"`
+	// Includes are the files FluentConf can @include, by name
+	// +optional
+	Includes map[string]string `json:"includes,omitempty"`
+	// MountedFiles ingest log files from the containers, see <source @type mounted-file>
+	// +optional
+	MountedFiles []MountedFile `json:"mountedFiles,omitempty"`
+	// Filters are rendered as <filter> directives
+	// +optional
+	Filters []Plugin `json:"filters,omitempty"`
+	// Shares send logs to other namespaces, see @type share
+	// +optional
+	Shares []Share `json:"shares,omitempty"`
+	// Outputs are rendered as <match> directives
+	// +optional
+	Outputs []Plugin `json:"outputs,omitempty"`
+	// Receivers process the logs shared by other namespaces, see <label @$from(...)>
+	// +optional
+	Receivers []Receiver `json:"receivers,omitempty"`
+}
+
+// Plugin is a <filter> or <match> directive. At most one of Tag and Selector can be set,
+// when both are empty all logs of the namespace are matched.
+type Plugin struct {
+	// Type is the @type of the plugin
+	Type string `json:"type"`
+	// Tag is the fluentd tag pattern of the directive
+	// +optional
+	Tag string `json:"tag,omitempty"`
+	// Selector matches the logs of the pods with these labels, like the $labels macro
+	// +optional
+	Selector map[string]string `json:"selector,omitempty"`
+	// Params are the parameters of the plugin
+	// +optional
+	Params map[string]string `json:"params,omitempty"`
+	// Sections are the nested directives of the plugin, for example <buffer>
+	// +optional
+	Sections []Section `json:"sections,omitempty"`
+}
+
+// Section is a directive nested in a plugin
+type Section struct {
+	// Name of the directive, for example buffer or store
+	Name string `json:"name"`
+	// Arg is the argument of the directive, for example the chunk keys of a <buffer>
+	// +optional
+	Arg string `json:"arg,omitempty"`
+	// +optional
+	Params map[string]string `json:"params,omitempty"`
+	// +optional
+	Sections []Section `json:"sections,omitempty"`
+}
+
+// Share copies the matching logs to other namespaces. Like every <match> it consumes
+// the logs, use Outputs to send them somewhere else as well.
+type Share struct {
+	// Namespaces the logs are shared with
+	Namespaces []string `json:"namespaces"`
+	// +optional
+	Tag string `json:"tag,omitempty"`
+	// +optional
+	Selector map[string]string `json:"selector,omitempty"`
+	// Outputs receive a copy of the shared logs
+	// +optional
+	Outputs []Plugin `json:"outputs,omitempty"`
+}
+
+// Receiver processes the logs shared by another namespace
+type Receiver struct {
+	// FromNamespace is the namespace sharing the logs
+	FromNamespace string `json:"fromNamespace"`
+	// +optional
+	Filters []Plugin `json:"filters,omitempty"`
+	// +optional
+	Outputs []Plugin `json:"outputs,omitempty"`
+}
+
+// MountedFile ingests a log file written by containers to an emptyDir volume
+type MountedFile struct {
+	// Path of the file inside the container
+	Path string `json:"path"`
+	// Labels locate the pods writing the file, the _container label selects the container
+	Labels map[string]string `json:"labels"`
+	// Parse is the <parse> section, defaults to @type none
+	// +optional
+	Parse *Parser `json:"parse,omitempty"`
+}
+
+// Parser is a <parse> section
+type Parser struct {
+	Type string `json:"type"`
+	// +optional
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// Condition types reported in FluentdConfigStatus, in the order they are evaluated
+const (
+	// ConditionParsed is true when the config is syntactically valid
+	ConditionParsed = "Parsed"
+	// ConditionProcessed is true when all macros and restrictions were applied successfully
+	ConditionProcessed = "Processed"
+	// ConditionValidated is true when fluentd accepted the generated config
+	ConditionValidated = "Validated"
+	// ConditionApplied is true when the generated config was written for fluentd to load
+	ConditionApplied = "Applied"
+)
+
+// FluentdConfigStatus reports the outcome of the last generation of the namespace config.
+// All FluentdConfigs in a namespace are concatenated, so they share the same status.
+type FluentdConfigStatus struct {
+	// ObservedGeneration is the generation of the object the status refers to
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions are the Parsed, Processed, Validated and Applied conditions
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// ConfigHash is the hash of the generated config for the namespace
+	// +optional
+	ConfigHash string `json:"configHash,omitempty"`
+	// LastAppliedTime is when a valid config was last generated for the namespace
+	// +optional
+	LastAppliedTime *metav1.Time `json:"lastAppliedTime,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -31,3 +193,90 @@ type FluentdConfigList struct {
 
 	Items []FluentdConfig `json:"items"`
 }
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// FluentdPolicy restricts the plugins and parameters the namespace configs can use.
+// It is cluster-scoped, all the policies selecting a namespace apply to its config.
+type FluentdPolicy struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec FluentdPolicySpec `json:"spec,omitempty"`
+}
+
+// FluentdPolicySpec selects namespaces and declares the rules their configs must follow.
+// A policy with neither Namespaces nor NamespaceSelector applies to every namespace.
+type FluentdPolicySpec struct {
+	// Namespaces the policy applies to
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+	// NamespaceSelector selects the namespaces with these labels, in addition to Namespaces
+	// +optional
+	NamespaceSelector map[string]string `json:"namespaceSelector,omitempty"`
+	// Plugins restrict the @type of the directives
+	// +optional
+	Plugins []PluginRule `json:"plugins,omitempty"`
+	// Params restrict the values of the plugin parameters
+	// +optional
+	Params []ParamRule `json:"params,omitempty"`
+	// Lookups allow the k8sLookup template function to read objects outside of the namespace
+	// +optional
+	Lookups []LookupRule `json:"lookups,omitempty"`
+	// AllowEmbeddedRuby lets the configs use "#{...}" Ruby code in their values,
+	// which fluentd runs when it loads the config
+	// +optional
+	AllowEmbeddedRuby bool `json:"allowEmbeddedRuby,omitempty"`
+}
+
+// PluginRule restricts the @type of a kind of directive. A type is refused if it is
+// in Denied, or if Allowed is not empty and does not list it.
+type PluginRule struct {
+	// Directive is match, filter, store or source, the rule applies to all of them when empty.
+	// A rule for match also applies to the store and secondary outputs.
+	// +optional
+	Directive string `json:"directive,omitempty"`
+	// +optional
+	Allowed []string `json:"allowed,omitempty"`
+	// +optional
+	Denied []string `json:"denied,omitempty"`
+}
+
+// ParamRule restricts the values of a parameter. Values are matched against glob
+// patterns like *.example.com. A value is refused if it matches Denied, or if Allowed
+// is not empty and no pattern matches it.
+type ParamRule struct {
+	// Name of the parameter, for example host
+	Name string `json:"name"`
+	// Types are the @type of the plugins the rule applies to, all plugins when empty
+	// +optional
+	Types []string `json:"types,omitempty"`
+	// +optional
+	Allowed []string `json:"allowed,omitempty"`
+	// +optional
+	Denied []string `json:"denied,omitempty"`
+}
+
+// LookupRule allows k8sLookup to read objects of some kinds in other namespaces
+type LookupRule struct {
+	// Kinds like ConfigMap or Deployment, all kinds when empty
+	// +optional
+	Kinds []string `json:"kinds,omitempty"`
+	// Namespaces are glob patterns like team-*, * also allows the cluster-scoped
+	// objects and the lists across all namespaces
+	Namespaces []string `json:"namespaces"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// FluentdPolicyList is the mandatory plural type
+type FluentdPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []FluentdPolicy `json:"items"`
+}
@@ -101,6 +101,18 @@ func (c *FakeFluentdConfigs) Update(ctx context.Context, fluentdConfig *v1beta1.
 	return obj.(*v1beta1.FluentdConfig), err
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeFluentdConfigs) UpdateStatus(ctx context.Context, fluentdConfig *v1beta1.FluentdConfig, opts v1.UpdateOptions) (*v1beta1.FluentdConfig, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(fluentdconfigsResource, "status", c.ns, fluentdConfig), &v1beta1.FluentdConfig{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta1.FluentdConfig), err
+}
+
 // Delete takes name of the fluentdConfig and deletes it. Returns an error if one occurs.
 func (c *FakeFluentdConfigs) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
 	_, err := c.Fake.
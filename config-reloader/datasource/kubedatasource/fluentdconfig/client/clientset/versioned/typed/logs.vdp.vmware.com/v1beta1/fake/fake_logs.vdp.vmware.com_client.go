@@ -27,10 +27,18 @@ type FakeLogsV1beta1 struct {
 	*testing.Fake
 }
 
+func (c *FakeLogsV1beta1) ClusterFluentdConfigs() v1beta1.ClusterFluentdConfigInterface {
+	return &FakeClusterFluentdConfigs{c}
+}
+
 func (c *FakeLogsV1beta1) FluentdConfigs(namespace string) v1beta1.FluentdConfigInterface {
 	return &FakeFluentdConfigs{c, namespace}
 }
 
+func (c *FakeLogsV1beta1) FluentdPolicies() v1beta1.FluentdPolicyInterface {
+	return &FakeFluentdPolicies{c}
+}
+
 // RESTClient returns a RESTClient that is used to communicate
 // with API server by this client implementation.
 func (c *FakeLogsV1beta1) RESTClient() rest.Interface {
@@ -39,6 +39,7 @@ type FluentdConfigsGetter interface {
 type FluentdConfigInterface interface {
 	Create(ctx context.Context, fluentdConfig *v1beta1.FluentdConfig, opts v1.CreateOptions) (*v1beta1.FluentdConfig, error)
 	Update(ctx context.Context, fluentdConfig *v1beta1.FluentdConfig, opts v1.UpdateOptions) (*v1beta1.FluentdConfig, error)
+	UpdateStatus(ctx context.Context, fluentdConfig *v1beta1.FluentdConfig, opts v1.UpdateOptions) (*v1beta1.FluentdConfig, error)
 	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
 	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
 	Get(ctx context.Context, name string, opts v1.GetOptions) (*v1beta1.FluentdConfig, error)
@@ -134,6 +135,22 @@ func (c *fluentdConfigs) Update(ctx context.Context, fluentdConfig *v1beta1.Flue
 	return
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *fluentdConfigs) UpdateStatus(ctx context.Context, fluentdConfig *v1beta1.FluentdConfig, opts v1.UpdateOptions) (result *v1beta1.FluentdConfig, err error) {
+	result = &v1beta1.FluentdConfig{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("fluentdconfigs").
+		Name(fluentdConfig.Name).
+		SubResource("status").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(fluentdConfig).
+		Do(ctx).
+		Into(result)
+	return
+}
+
 // Delete takes name of the fluentdConfig and deletes it. Returns an error if one occurs.
 func (c *fluentdConfigs) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
 	return c.client.Delete().
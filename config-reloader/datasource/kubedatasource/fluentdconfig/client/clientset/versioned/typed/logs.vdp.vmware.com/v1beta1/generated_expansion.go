@@ -17,4 +17,8 @@ limitations under the License.
 
 package v1beta1
 
+type ClusterFluentdConfigExpansion interface{}
+
 type FluentdConfigExpansion interface{}
+
+type FluentdPolicyExpansion interface{}
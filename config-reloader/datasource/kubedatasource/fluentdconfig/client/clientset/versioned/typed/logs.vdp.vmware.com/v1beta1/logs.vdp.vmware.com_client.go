@@ -25,7 +25,9 @@ import (
 
 type LogsV1beta1Interface interface {
 	RESTClient() rest.Interface
+	ClusterFluentdConfigsGetter
 	FluentdConfigsGetter
+	FluentdPoliciesGetter
 }
 
 // LogsV1beta1Client is used to interact with features provided by the logs.vdp.vmware.com group.
@@ -33,10 +35,18 @@ type LogsV1beta1Client struct {
 	restClient rest.Interface
 }
 
+func (c *LogsV1beta1Client) ClusterFluentdConfigs() ClusterFluentdConfigInterface {
+	return newClusterFluentdConfigs(c)
+}
+
 func (c *LogsV1beta1Client) FluentdConfigs(namespace string) FluentdConfigInterface {
 	return newFluentdConfigs(c, namespace)
 }
 
+func (c *LogsV1beta1Client) FluentdPolicies() FluentdPolicyInterface {
+	return newFluentdPolicies(c)
+}
+
 // NewForConfig creates a new LogsV1beta1Client for the given config.
 func NewForConfig(c *rest.Config) (*LogsV1beta1Client, error) {
 	config := *c
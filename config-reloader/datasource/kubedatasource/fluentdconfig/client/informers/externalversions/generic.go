@@ -52,8 +52,12 @@ func (f *genericInformer) Lister() cache.GenericLister {
 func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource) (GenericInformer, error) {
 	switch resource {
 	// Group=logs.vdp.vmware.com, Version=v1beta1
+	case v1beta1.SchemeGroupVersion.WithResource("clusterfluentdconfigs"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Logs().V1beta1().ClusterFluentdConfigs().Informer()}, nil
 	case v1beta1.SchemeGroupVersion.WithResource("fluentdconfigs"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Logs().V1beta1().FluentdConfigs().Informer()}, nil
+	case v1beta1.SchemeGroupVersion.WithResource("fluentdpolicies"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Logs().V1beta1().FluentdPolicies().Informer()}, nil
 
 	}
 
@@ -23,8 +23,12 @@ import (
 
 // Interface provides access to all the informers in this group version.
 type Interface interface {
+	// ClusterFluentdConfigs returns a ClusterFluentdConfigInformer.
+	ClusterFluentdConfigs() ClusterFluentdConfigInformer
 	// FluentdConfigs returns a FluentdConfigInformer.
 	FluentdConfigs() FluentdConfigInformer
+	// FluentdPolicies returns a FluentdPolicyInformer.
+	FluentdPolicies() FluentdPolicyInformer
 }
 
 type version struct {
@@ -38,7 +42,17 @@ func New(f internalinterfaces.SharedInformerFactory, namespace string, tweakList
 	return &version{factory: f, namespace: namespace, tweakListOptions: tweakListOptions}
 }
 
+// ClusterFluentdConfigs returns a ClusterFluentdConfigInformer.
+func (v *version) ClusterFluentdConfigs() ClusterFluentdConfigInformer {
+	return &clusterFluentdConfigInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
+
 // FluentdConfigs returns a FluentdConfigInformer.
 func (v *version) FluentdConfigs() FluentdConfigInformer {
 	return &fluentdConfigInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
+
+// FluentdPolicies returns a FluentdPolicyInformer.
+func (v *version) FluentdPolicies() FluentdPolicyInformer {
+	return &fluentdPolicyInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
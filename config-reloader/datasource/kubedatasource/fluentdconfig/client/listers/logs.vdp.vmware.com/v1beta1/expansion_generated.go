@@ -17,6 +17,10 @@ limitations under the License.
 
 package v1beta1
 
+// ClusterFluentdConfigListerExpansion allows custom methods to be added to
+// ClusterFluentdConfigLister.
+type ClusterFluentdConfigListerExpansion interface{}
+
 // FluentdConfigListerExpansion allows custom methods to be added to
 // FluentdConfigLister.
 type FluentdConfigListerExpansion interface{}
@@ -24,3 +28,7 @@ type FluentdConfigListerExpansion interface{}
 // FluentdConfigNamespaceListerExpansion allows custom methods to be added to
 // FluentdConfigNamespaceLister.
 type FluentdConfigNamespaceListerExpansion interface{}
+
+// FluentdPolicyListerExpansion allows custom methods to be added to
+// FluentdPolicyLister.
+type FluentdPolicyListerExpansion interface{}
@@ -27,6 +27,20 @@ type manager interface {
 // It will automatically install either the legacy v1beta1 CRD or the new v1 CRD
 // based on the available APIs in the Kubernetes cluster
 func CheckAndInstallCRD(ctx context.Context, config *rest.Config) error {
+	return checkAndInstall(ctx, config, &fluentdConfigCRD, &legacyFluentdConfigCRD)
+}
+
+// CheckAndInstallClusterConfigCRD does the same as CheckAndInstallCRD for the ClusterFluentdConfig CRD
+func CheckAndInstallClusterConfigCRD(ctx context.Context, config *rest.Config) error {
+	return checkAndInstall(ctx, config, &clusterFluentdConfigCRD, &legacyClusterFluentdConfigCRD)
+}
+
+// CheckAndInstallPolicyCRD does the same as CheckAndInstallCRD for the FluentdPolicy CRD
+func CheckAndInstallPolicyCRD(ctx context.Context, config *rest.Config) error {
+	return checkAndInstall(ctx, config, &fluentdPolicyCRD, &legacyFluentdPolicyCRD)
+}
+
+func checkAndInstall(ctx context.Context, config *rest.Config, crd *v1.CustomResourceDefinition, legacyCRD *v1beta1.CustomResourceDefinition) error {
 	clientset, err := clientset.NewForConfig(config)
 	if err != nil {
 		return err
@@ -39,9 +53,9 @@ func CheckAndInstallCRD(ctx context.Context, config *rest.Config) error {
 	}
 
 	if v1Available {
-		crdManager = &v1Manager{clientset}
+		crdManager = &v1Manager{clientset, crd}
 	} else {
-		crdManager = &v1beta1Manager{clientset}
+		crdManager = &v1beta1Manager{clientset, legacyCRD}
 	}
 
 	if err := crdManager.ApplyCRD(ctx); err != nil {
@@ -113,6 +127,194 @@ func monitorCRDAvailability(ctx context.Context, crdManager manager) error {
 
 // ////////////// v1 CRD Manager /////////////////
 
+var statusSchema = v1.JSONSchemaProps{
+	Type: "object",
+	Properties: map[string]v1.JSONSchemaProps{
+		"observedGeneration": {
+			Type:   "integer",
+			Format: "int64",
+		},
+		"configHash": {
+			Type: "string",
+		},
+		"lastAppliedTime": {
+			Type:   "string",
+			Format: "date-time",
+		},
+		"conditions": {
+			Type: "array",
+			Items: &v1.JSONSchemaPropsOrArray{
+				Schema: &v1.JSONSchemaProps{
+					Type:     "object",
+					Required: []string{"type", "status", "lastTransitionTime", "reason", "message"},
+					Properties: map[string]v1.JSONSchemaProps{
+						"type":               {Type: "string"},
+						"status":             {Type: "string", Enum: []v1.JSON{{Raw: []byte(`"True"`)}, {Raw: []byte(`"False"`)}, {Raw: []byte(`"Unknown"`)}}},
+						"observedGeneration": {Type: "integer", Format: "int64"},
+						"lastTransitionTime": {Type: "string", Format: "date-time"},
+						"reason":             {Type: "string"},
+						"message":            {Type: "string"},
+					},
+				},
+			},
+		},
+	},
+}
+
+var minLength int64 = 1
+
+var stringMapSchema = v1.JSONSchemaProps{
+	Type: "object",
+	AdditionalProperties: &v1.JSONSchemaPropsOrBool{
+		Schema: &v1.JSONSchemaProps{Type: "string"},
+	},
+}
+
+// maxSectionDepth bounds the schema of the nested sections as OpenAPI schemas in
+// CRDs cannot be recursive. Deeper sections are accepted without validation.
+const maxSectionDepth = 4
+
+func sectionsSchema(depth int) v1.JSONSchemaProps {
+	if depth == 0 {
+		preserve := true
+		return v1.JSONSchemaProps{
+			Type: "array",
+			Items: &v1.JSONSchemaPropsOrArray{
+				Schema: &v1.JSONSchemaProps{
+					Type:                   "object",
+					XPreserveUnknownFields: &preserve,
+				},
+			},
+		}
+	}
+
+	return v1.JSONSchemaProps{
+		Type: "array",
+		Items: &v1.JSONSchemaPropsOrArray{
+			Schema: &v1.JSONSchemaProps{
+				Type:     "object",
+				Required: []string{"name"},
+				Properties: map[string]v1.JSONSchemaProps{
+					"name":     {Type: "string", MinLength: &minLength},
+					"arg":      {Type: "string"},
+					"params":   stringMapSchema,
+					"sections": sectionsSchema(depth - 1),
+				},
+			},
+		},
+	}
+}
+
+var pluginSchema = v1.JSONSchemaProps{
+	Type:     "object",
+	Required: []string{"type"},
+	Properties: map[string]v1.JSONSchemaProps{
+		"type":     {Type: "string", MinLength: &minLength},
+		"tag":      {Type: "string"},
+		"selector": stringMapSchema,
+		"params":   stringMapSchema,
+		"sections": sectionsSchema(maxSectionDepth),
+	},
+}
+
+var pluginsSchema = v1.JSONSchemaProps{
+	Type: "array",
+	Items: &v1.JSONSchemaPropsOrArray{
+		Schema: &pluginSchema,
+	},
+}
+
+var specSchema = v1.JSONSchemaProps{
+	Type: "object",
+	Properties: map[string]v1.JSONSchemaProps{
+		"fluentconf": {
+			Type: "string",
+		},
+		"includes": stringMapSchema,
+		"mountedFiles": {
+			Type: "array",
+			Items: &v1.JSONSchemaPropsOrArray{
+				Schema: &v1.JSONSchemaProps{
+					Type:     "object",
+					Required: []string{"path", "labels"},
+					Properties: map[string]v1.JSONSchemaProps{
+						"path":   {Type: "string", MinLength: &minLength},
+						"labels": stringMapSchema,
+						"parse": {
+							Type:     "object",
+							Required: []string{"type"},
+							Properties: map[string]v1.JSONSchemaProps{
+								"type":   {Type: "string", MinLength: &minLength},
+								"params": stringMapSchema,
+							},
+						},
+					},
+				},
+			},
+		},
+		"filters": pluginsSchema,
+		"shares": {
+			Type: "array",
+			Items: &v1.JSONSchemaPropsOrArray{
+				Schema: &v1.JSONSchemaProps{
+					Type:     "object",
+					Required: []string{"namespaces"},
+					Properties: map[string]v1.JSONSchemaProps{
+						"namespaces": {
+							Type:     "array",
+							MinItems: &minLength,
+							Items: &v1.JSONSchemaPropsOrArray{
+								Schema: &v1.JSONSchemaProps{Type: "string"},
+							},
+						},
+						"tag":      {Type: "string"},
+						"selector": stringMapSchema,
+						"outputs":  pluginsSchema,
+					},
+				},
+			},
+		},
+		"outputs": pluginsSchema,
+		"receivers": {
+			Type: "array",
+			Items: &v1.JSONSchemaPropsOrArray{
+				Schema: &v1.JSONSchemaProps{
+					Type:     "object",
+					Required: []string{"fromNamespace"},
+					Properties: map[string]v1.JSONSchemaProps{
+						"fromNamespace": {Type: "string", MinLength: &minLength},
+						"filters":       pluginsSchema,
+						"outputs":       pluginsSchema,
+					},
+				},
+			},
+		},
+	},
+}
+
+var statusColumns = []v1.CustomResourceColumnDefinition{
+	{
+		Name:     "Applied",
+		Type:     "string",
+		JSONPath: `.status.conditions[?(@.type=="Applied")].status`,
+	},
+	{
+		Name:     "Reason",
+		Type:     "string",
+		JSONPath: `.status.conditions[?(@.status=="False")].reason`,
+	},
+	{
+		Name:     "Last Applied",
+		Type:     "date",
+		JSONPath: ".status.lastAppliedTime",
+	},
+	{
+		Name:     "Age",
+		Type:     "date",
+		JSONPath: ".metadata.creationTimestamp",
+	},
+}
+
 var fluentdConfigCRD = v1.CustomResourceDefinition{
 	ObjectMeta: metav1.ObjectMeta{
 		Name: "fluentdconfigs.logs.vdp.vmware.com",
@@ -124,6 +326,41 @@ var fluentdConfigCRD = v1.CustomResourceDefinition{
 			Kind:   "FluentdConfig",
 		},
 		Scope: v1.NamespaceScoped,
+		Versions: []v1.CustomResourceDefinitionVersion{
+			{
+				Name:    "v1beta1",
+				Served:  true,
+				Storage: true,
+				Schema: &v1.CustomResourceValidation{
+					OpenAPIV3Schema: &v1.JSONSchemaProps{
+						Type: "object",
+						Properties: map[string]v1.JSONSchemaProps{
+							"spec":   specSchema,
+							"status": statusSchema,
+						},
+					},
+				},
+				Subresources: &v1.CustomResourceSubresources{
+					Status: &v1.CustomResourceSubresourceStatus{},
+				},
+				AdditionalPrinterColumns: statusColumns,
+			},
+		},
+	},
+}
+
+var clusterFluentdConfigCRD = v1.CustomResourceDefinition{
+	ObjectMeta: metav1.ObjectMeta{
+		Name: "clusterfluentdconfigs.logs.vdp.vmware.com",
+	},
+	Spec: v1.CustomResourceDefinitionSpec{
+		Group: "logs.vdp.vmware.com",
+		Names: v1.CustomResourceDefinitionNames{
+			Plural:   "clusterfluentdconfigs",
+			Singular: "clusterfluentdconfig",
+			Kind:     "ClusterFluentdConfig",
+		},
+		Scope: v1.ClusterScoped,
 		Versions: []v1.CustomResourceDefinitionVersion{
 			{
 				Name:    "v1beta1",
@@ -136,11 +373,113 @@ var fluentdConfigCRD = v1.CustomResourceDefinition{
 							"spec": {
 								Type: "object",
 								Properties: map[string]v1.JSONSchemaProps{
-									"fluentconf": {
-										Type: "string",
-									},
+									"order":      {Type: "integer", Format: "int32"},
+									"fluentconf": {Type: "string"},
 								},
 							},
+							"status": statusSchema,
+						},
+					},
+				},
+				Subresources: &v1.CustomResourceSubresources{
+					Status: &v1.CustomResourceSubresourceStatus{},
+				},
+				AdditionalPrinterColumns: append([]v1.CustomResourceColumnDefinition{
+					{
+						Name:     "Order",
+						Type:     "integer",
+						JSONPath: ".spec.order",
+					},
+				}, statusColumns...),
+			},
+		},
+	},
+}
+
+var stringsSchema = v1.JSONSchemaProps{
+	Type: "array",
+	Items: &v1.JSONSchemaPropsOrArray{
+		Schema: &v1.JSONSchemaProps{Type: "string"},
+	},
+}
+
+var policySpecSchema = v1.JSONSchemaProps{
+	Type: "object",
+	Properties: map[string]v1.JSONSchemaProps{
+		"namespaces":        stringsSchema,
+		"namespaceSelector": stringMapSchema,
+		"plugins": {
+			Type: "array",
+			Items: &v1.JSONSchemaPropsOrArray{
+				Schema: &v1.JSONSchemaProps{
+					Type: "object",
+					Properties: map[string]v1.JSONSchemaProps{
+						"directive": {
+							Type: "string",
+							Enum: []v1.JSON{{Raw: []byte(`"match"`)}, {Raw: []byte(`"filter"`)}, {Raw: []byte(`"store"`)}, {Raw: []byte(`"source"`)}},
+						},
+						"allowed": stringsSchema,
+						"denied":  stringsSchema,
+					},
+				},
+			},
+		},
+		"params": {
+			Type: "array",
+			Items: &v1.JSONSchemaPropsOrArray{
+				Schema: &v1.JSONSchemaProps{
+					Type:     "object",
+					Required: []string{"name"},
+					Properties: map[string]v1.JSONSchemaProps{
+						"name":    {Type: "string", MinLength: &minLength},
+						"types":   stringsSchema,
+						"allowed": stringsSchema,
+						"denied":  stringsSchema,
+					},
+				},
+			},
+		},
+		"lookups": {
+			Type: "array",
+			Items: &v1.JSONSchemaPropsOrArray{
+				Schema: &v1.JSONSchemaProps{
+					Type:     "object",
+					Required: []string{"namespaces"},
+					Properties: map[string]v1.JSONSchemaProps{
+						"kinds":      stringsSchema,
+						"namespaces": stringsSchema,
+					},
+				},
+			},
+		},
+		"allowEmbeddedRuby": {
+			Type: "boolean",
+		},
+	},
+}
+
+var fluentdPolicyCRD = v1.CustomResourceDefinition{
+	ObjectMeta: metav1.ObjectMeta{
+		Name: "fluentdpolicies.logs.vdp.vmware.com",
+	},
+	Spec: v1.CustomResourceDefinitionSpec{
+		Group: "logs.vdp.vmware.com",
+		Names: v1.CustomResourceDefinitionNames{
+			Plural:   "fluentdpolicies",
+			Singular: "fluentdpolicy",
+			Kind:     "FluentdPolicy",
+		},
+		Scope: v1.ClusterScoped,
+		Versions: []v1.CustomResourceDefinitionVersion{
+			{
+				Name:    "v1beta1",
+				Served:  true,
+				Storage: true,
+				Schema: &v1.CustomResourceValidation{
+					OpenAPIV3Schema: &v1.JSONSchemaProps{
+						Type: "object",
+						Properties: map[string]v1.JSONSchemaProps{
+							"spec": policySpecSchema,
 						},
 					},
 				},
@@ -151,16 +490,96 @@ var fluentdConfigCRD = v1.CustomResourceDefinition{
 
 type v1Manager struct {
 	clientset *clientset.Clientset
+	crd       *v1.CustomResourceDefinition
 }
 
 func (m *v1Manager) ApplyCRD(ctx context.Context) error {
-	if _, err := m.clientset.ApiextensionsV1().CustomResourceDefinitions().Create(ctx, &fluentdConfigCRD, metav1.CreateOptions{}); err != nil && !errors.IsAlreadyExists(err) {
+	_, err := m.clientset.ApiextensionsV1().CustomResourceDefinitions().Create(ctx, m.crd, metav1.CreateOptions{})
+	if err == nil {
+		return nil
+	}
+	if !errors.IsAlreadyExists(err) {
 		return err
 	}
 
+	// an older release may have installed the CRD without the status subresource or
+	// without the fields added since, the CRD is left alone otherwise
+	existing, err := m.clientset.ApiextensionsV1().CustomResourceDefinitions().Get(ctx, m.GetCRDName(ctx), metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if !upgradeCRD(existing, m.crd) {
+		logrus.Infof("%s CRD is already installed", m.GetCRDName(ctx))
+		return nil
+	}
+	if _, err := m.clientset.ApiextensionsV1().CustomResourceDefinitions().Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+		logrus.Warnf("Cannot update %s CRD, status will not be reported: %+v", m.GetCRDName(ctx), err)
+	}
+
 	return nil
 }
 
+// upgradeCRD adds the status subresource and the schema of wanted to the versions of existing
+// that lack them. It returns false if existing already has what wanted needs.
+func upgradeCRD(existing *v1.CustomResourceDefinition, wanted *v1.CustomResourceDefinition) bool {
+	changed := false
+	for _, w := range wanted.Spec.Versions {
+		var version *v1.CustomResourceDefinitionVersion
+		for i := range existing.Spec.Versions {
+			if existing.Spec.Versions[i].Name == w.Name {
+				version = &existing.Spec.Versions[i]
+			}
+		}
+		if version == nil {
+			logrus.Warnf("%s CRD does not serve version %s", existing.Name, w.Name)
+			continue
+		}
+
+		if w.Subresources != nil && w.Subresources.Status != nil &&
+			(version.Subresources == nil || version.Subresources.Status == nil) {
+			if version.Subresources == nil {
+				version.Subresources = &v1.CustomResourceSubresources{}
+			}
+			version.Subresources.Status = w.Subresources.Status
+			changed = true
+		}
+
+		if w.Schema != nil && (version.Schema == nil || version.Schema.OpenAPIV3Schema == nil ||
+			!coversSchema(version.Schema.OpenAPIV3Schema, w.Schema.OpenAPIV3Schema)) {
+			version.Schema = w.Schema.DeepCopy()
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// coversSchema tells if have declares every field of want
+func coversSchema(have *v1.JSONSchemaProps, want *v1.JSONSchemaProps) bool {
+	if have.XPreserveUnknownFields != nil && *have.XPreserveUnknownFields {
+		return true
+	}
+
+	for name, w := range want.Properties {
+		h, ok := have.Properties[name]
+		if !ok || !coversSchema(&h, &w) {
+			return false
+		}
+	}
+
+	if want.Items != nil && want.Items.Schema != nil {
+		if have.Items == nil || have.Items.Schema == nil || !coversSchema(have.Items.Schema, want.Items.Schema) {
+			return false
+		}
+	}
+
+	if want.AdditionalProperties != nil && have.AdditionalProperties == nil {
+		return false
+	}
+
+	return true
+}
+
 func (m *v1Manager) CheckCRD(ctx context.Context) (bool, error) {
 	crd, err := m.clientset.ApiextensionsV1().CustomResourceDefinitions().Get(ctx, m.GetCRDName(ctx), metav1.GetOptions{})
 	if err != nil {
@@ -176,7 +595,7 @@ func (m *v1Manager) CheckCRD(ctx context.Context) (bool, error) {
 }
 
 func (m *v1Manager) GetCRDName(ctx context.Context) string {
-	return fluentdConfigCRD.ObjectMeta.Name
+	return m.crd.ObjectMeta.Name
 }
 
 // ////////////// v1beta1 CRD Manager ///////////////
@@ -203,9 +622,15 @@ var legacyFluentdConfigCRD = v1beta1.CustomResourceDefinition{
 							},
 						},
 					},
+					"status": {
+						Type: "object",
+					},
 				},
 			},
 		},
+		Subresources: &v1beta1.CustomResourceSubresources{
+			Status: &v1beta1.CustomResourceSubresourceStatus{},
+		},
 		Scope: v1beta1.NamespaceScoped,
 		Versions: []v1beta1.CustomResourceDefinitionVersion{
 			{
@@ -217,12 +642,60 @@ var legacyFluentdConfigCRD = v1beta1.CustomResourceDefinition{
 	},
 }
 
+var legacyClusterFluentdConfigCRD = v1beta1.CustomResourceDefinition{
+	ObjectMeta: metav1.ObjectMeta{
+		Name: "clusterfluentdconfigs.logs.vdp.vmware.com",
+	},
+	Spec: v1beta1.CustomResourceDefinitionSpec{
+		Group: "logs.vdp.vmware.com",
+		Names: v1beta1.CustomResourceDefinitionNames{
+			Plural:   "clusterfluentdconfigs",
+			Singular: "clusterfluentdconfig",
+			Kind:     "ClusterFluentdConfig",
+		},
+		Subresources: &v1beta1.CustomResourceSubresources{
+			Status: &v1beta1.CustomResourceSubresourceStatus{},
+		},
+		Scope: v1beta1.ClusterScoped,
+		Versions: []v1beta1.CustomResourceDefinitionVersion{
+			{
+				Name:    "v1beta1",
+				Served:  true,
+				Storage: true,
+			},
+		},
+	},
+}
+
+var legacyFluentdPolicyCRD = v1beta1.CustomResourceDefinition{
+	ObjectMeta: metav1.ObjectMeta{
+		Name: "fluentdpolicies.logs.vdp.vmware.com",
+	},
+	Spec: v1beta1.CustomResourceDefinitionSpec{
+		Group: "logs.vdp.vmware.com",
+		Names: v1beta1.CustomResourceDefinitionNames{
+			Plural:   "fluentdpolicies",
+			Singular: "fluentdpolicy",
+			Kind:     "FluentdPolicy",
+		},
+		Scope: v1beta1.ClusterScoped,
+		Versions: []v1beta1.CustomResourceDefinitionVersion{
+			{
+				Name:    "v1beta1",
+				Served:  true,
+				Storage: true,
+			},
+		},
+	},
+}
+
 type v1beta1Manager struct {
 	clientset *clientset.Clientset
+	crd       *v1beta1.CustomResourceDefinition
 }
 
 func (m *v1beta1Manager) ApplyCRD(ctx context.Context) error {
-	if _, err := m.clientset.ApiextensionsV1beta1().CustomResourceDefinitions().Create(ctx, &legacyFluentdConfigCRD, metav1.CreateOptions{}); err != nil && !errors.IsAlreadyExists(err) {
+	if _, err := m.clientset.ApiextensionsV1beta1().CustomResourceDefinitions().Create(ctx, m.crd, metav1.CreateOptions{}); err != nil && !errors.IsAlreadyExists(err) {
 		return err
 	}
 
@@ -244,5 +717,5 @@ func (m *v1beta1Manager) CheckCRD(ctx context.Context) (bool, error) {
 }
 
 func (m *v1beta1Manager) GetCRDName(ctx context.Context) string {
-	return legacyFluentdConfigCRD.ObjectMeta.Name
+	return m.crd.ObjectMeta.Name
 }
@@ -0,0 +1,41 @@
+package crd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+func TestUpgradeCRDLeavesCompleteCRDAlone(t *testing.T) {
+	existing := fluentdConfigCRD.DeepCopy()
+	existing.Spec.Versions[0].AdditionalPrinterColumns = []v1.CustomResourceColumnDefinition{
+		{Name: "Team", Type: "string", JSONPath: ".metadata.labels.team"},
+	}
+	existing.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["extra"] = v1.JSONSchemaProps{Type: "string"}
+
+	assert.False(t, upgradeCRD(existing, &fluentdConfigCRD))
+	assert.Equal(t, "Team", existing.Spec.Versions[0].AdditionalPrinterColumns[0].Name)
+
+	// fields preserved without schema are enough
+	preserve := true
+	existing.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["spec"] = v1.JSONSchemaProps{
+		Type:                   "object",
+		XPreserveUnknownFields: &preserve,
+	}
+	assert.False(t, upgradeCRD(existing, &fluentdConfigCRD))
+}
+
+func TestUpgradeCRDAddsWhatIsMissing(t *testing.T) {
+	existing := fluentdConfigCRD.DeepCopy()
+	existing.Spec.Versions[0].Subresources = nil
+	delete(existing.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties, "status")
+	existing.Spec.Versions[0].AdditionalPrinterColumns = nil
+
+	assert.True(t, upgradeCRD(existing, &fluentdConfigCRD))
+	assert.NotNil(t, existing.Spec.Versions[0].Subresources.Status)
+	assert.Contains(t, existing.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties, "status")
+	// only the status subresource and the schema are updated
+	assert.Nil(t, existing.Spec.Versions[0].AdditionalPrinterColumns)
+	assert.False(t, upgradeCRD(existing, &fluentdConfigCRD))
+}
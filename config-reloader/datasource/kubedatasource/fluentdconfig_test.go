@@ -0,0 +1,93 @@
+package kubedatasource
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	kfo "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/apis/logs.vdp.vmware.com/v1beta1"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/client/clientset/versioned/fake"
+	kfoListersV1beta1 "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/client/listers/logs.vdp.vmware.com/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestWriteConfigStatusReportsInvalidSpec(t *testing.T) {
+	good := &kfo.FluentdConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "good", Namespace: "ns-a"},
+		Spec:       kfo.FluentdConfigSpec{FluentConf: "<match **>\n  @type null\n</match>"},
+	}
+	broken := &kfo.FluentdConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "broken", Namespace: "ns-a"},
+		Spec:       kfo.FluentdConfigSpec{Outputs: []kfo.Plugin{{}}},
+	}
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	assert.Nil(t, indexer.Add(good))
+	assert.Nil(t, indexer.Add(broken))
+
+	client := fake.NewSimpleClientset(good, broken)
+	ds := &FluentdConfigDS{
+		Fdclient: client,
+		Fdlist:   kfoListersV1beta1.NewFluentdConfigLister(indexer),
+	}
+
+	ctx := context.Background()
+	conf, err := ds.GetFluentdConfig(ctx, "ns-a")
+	assert.Nil(t, err)
+	assert.Equal(t, good.Spec.FluentConf, conf)
+
+	ds.WriteConfigStatus(ctx, "ns-a", "", "", "hash")
+
+	fc, err := client.LogsV1beta1().FluentdConfigs("ns-a").Get(ctx, "good", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.True(t, meta.IsStatusConditionTrue(fc.Status.Conditions, kfo.ConditionApplied))
+	assert.Equal(t, "hash", fc.Status.ConfigHash)
+
+	fc, err = client.LogsV1beta1().FluentdConfigs("ns-a").Get(ctx, "broken", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.True(t, meta.IsStatusConditionFalse(fc.Status.Conditions, kfo.ConditionParsed))
+	assert.False(t, meta.IsStatusConditionTrue(fc.Status.Conditions, kfo.ConditionApplied))
+	assert.Equal(t, "", fc.Status.ConfigHash)
+}
+
+func TestGetFluentdConfigReportsNewInvalidSpec(t *testing.T) {
+	broken := &kfo.FluentdConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "broken", Namespace: "ns-a", Generation: 1},
+		Spec:       kfo.FluentdConfigSpec{Outputs: []kfo.Plugin{{}}},
+	}
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	client := fake.NewSimpleClientset()
+	ds := &FluentdConfigDS{
+		Fdclient: client,
+		Fdlist:   kfoListersV1beta1.NewFluentdConfigLister(indexer),
+	}
+
+	// the namespace renders the same empty config before and after the object is created
+	ctx := context.Background()
+	conf, err := ds.GetFluentdConfig(ctx, "ns-a")
+	assert.Nil(t, err)
+	assert.Equal(t, "", conf)
+
+	_, err = client.LogsV1beta1().FluentdConfigs("ns-a").Create(ctx, broken, metav1.CreateOptions{})
+	assert.Nil(t, err)
+	assert.Nil(t, indexer.Add(broken))
+
+	conf, err = ds.GetFluentdConfig(ctx, "ns-a")
+	assert.Nil(t, err)
+	assert.Equal(t, "", conf)
+
+	fc, err := client.LogsV1beta1().FluentdConfigs("ns-a").Get(ctx, "broken", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.True(t, meta.IsStatusConditionFalse(fc.Status.Conditions, kfo.ConditionParsed))
+	assert.Equal(t, int64(1), fc.Status.ObservedGeneration)
+
+	// the status is not written again while it reports the error
+	assert.Nil(t, indexer.Update(fc))
+	client.ClearActions()
+	_, err = ds.GetFluentdConfig(ctx, "ns-a")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(client.Actions()))
+}
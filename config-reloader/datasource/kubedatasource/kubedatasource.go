@@ -2,7 +2,9 @@ package kubedatasource
 
 import (
 	"context"
+
 	kfoListersV1beta1 "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/client/listers/logs.vdp.vmware.com/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // KubeDS is an interface defining behavor for the Kubernetes Resources
@@ -12,3 +14,23 @@ type KubeDS interface {
 	IsReady() bool
 	GetFdlist() kfoListersV1beta1.FluentdConfigLister
 }
+
+// StatusWriter is implemented by the KubeDS able to report the status of a
+// namespace config on the Kubernetes Resources the config was read from.
+// failedCondition is the first condition that did not hold or "" if the config was applied
+type StatusWriter interface {
+	WriteConfigStatus(ctx context.Context, namespace string, failedCondition string, message string, configHash string)
+}
+
+// IncludeSource is implemented by the KubeDS whose Kubernetes Resources hold other files
+// besides the config, that the config can @include. When several resources hold a file
+// of the same name, the last one in name order wins.
+type IncludeSource interface {
+	GetIncludeFiles(ctx context.Context, namespace string) (map[string]string, error)
+}
+
+// ConfigObjectLister is implemented by the KubeDS able to tell which Kubernetes Resources
+// the config of a namespace was read from
+type ConfigObjectLister interface {
+	ListConfigObjects(ctx context.Context, namespace string) []runtime.Object
+}
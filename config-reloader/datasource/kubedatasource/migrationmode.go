@@ -7,6 +7,7 @@ import (
 	"github.com/vmware/kube-fluentd-operator/config-reloader/config"
 	kfoListersV1beta1 "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/client/listers/logs.vdp.vmware.com/v1beta1"
 
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/rest"
 )
@@ -57,6 +58,44 @@ func (m *MigrationModeDS) GetFluentdConfig(ctx context.Context, namespace string
 	return cmConfigs + "\n" + fdConfigs, nil
 }
 
+// GetIncludeFiles merges the files of the ConfigMaps and of the FluentdConfigs
+func (m *MigrationModeDS) GetIncludeFiles(ctx context.Context, namespace string) (map[string]string, error) {
+	files := map[string]string{}
+	for _, kubeDS := range []KubeDS{m.cmKubeDS, m.fdKubeDS} {
+		is, ok := kubeDS.(IncludeSource)
+		if !ok {
+			continue
+		}
+
+		dsFiles, err := is.GetIncludeFiles(ctx, namespace)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range dsFiles {
+			files[k] = v
+		}
+	}
+	return files, nil
+}
+
+// WriteConfigStatus reports the status on the FluentdConfigs of the namespace
+func (m *MigrationModeDS) WriteConfigStatus(ctx context.Context, namespace string, failedCondition string, message string, configHash string) {
+	if sw, ok := m.fdKubeDS.(StatusWriter); ok {
+		sw.WriteConfigStatus(ctx, namespace, failedCondition, message, configHash)
+	}
+}
+
+// ListConfigObjects returns the ConfigMaps and the FluentdConfigs of the namespace
+func (m *MigrationModeDS) ListConfigObjects(ctx context.Context, namespace string) []runtime.Object {
+	var objects []runtime.Object
+	for _, kubeDS := range []KubeDS{m.cmKubeDS, m.fdKubeDS} {
+		if col, ok := kubeDS.(ConfigObjectLister); ok {
+			objects = append(objects, col.ListConfigObjects(ctx, namespace)...)
+		}
+	}
+	return objects
+}
+
 // GetFdlist return nil for this mode because it does not use CRDs:
 func (m *MigrationModeDS) GetFdlist() kfoListersV1beta1.FluentdConfigLister {
 	return m.fdKubeDS.GetFdlist()
@@ -0,0 +1,284 @@
+package kubedatasource
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	kfo "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/apis/logs.vdp.vmware.com/v1beta1"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
+)
+
+// RenderFluentdConfigSpec returns the fluentd config declared by a FluentdConfig:
+// the raw FluentConf followed by the directives built from the structured fields
+func RenderFluentdConfigSpec(spec *kfo.FluentdConfigSpec) (string, error) {
+	fragment, err := StructuredFragment(spec)
+	if err != nil {
+		return "", err
+	}
+
+	if len(fragment) == 0 {
+		return spec.FluentConf, nil
+	}
+
+	return spec.FluentConf + "\n" + fragment.String(), nil
+}
+
+// StructuredFragment converts the structured fields of a FluentdConfigSpec to fluentd
+// directives. The raw FluentConf is ignored.
+func StructuredFragment(spec *kfo.FluentdConfigSpec) (fluentd.Fragment, error) {
+	res := fluentd.Fragment{}
+
+	for i := range spec.MountedFiles {
+		d, err := mountedFileDirective(&spec.MountedFiles[i])
+		if err != nil {
+			return nil, fmt.Errorf("mountedFiles[%d]: %+v", i, err)
+		}
+		res = append(res, d)
+	}
+
+	filters, err := pluginDirectives("filter", "filters", spec.Filters)
+	if err != nil {
+		return nil, err
+	}
+	res = append(res, filters...)
+
+	for i := range spec.Shares {
+		d, err := shareDirective(&spec.Shares[i])
+		if err != nil {
+			return nil, fmt.Errorf("shares[%d]: %+v", i, err)
+		}
+		res = append(res, d)
+	}
+
+	outputs, err := pluginDirectives("match", "outputs", spec.Outputs)
+	if err != nil {
+		return nil, err
+	}
+	res = append(res, outputs...)
+
+	for i := range spec.Receivers {
+		d, err := receiverDirective(&spec.Receivers[i])
+		if err != nil {
+			return nil, fmt.Errorf("receivers[%d]: %+v", i, err)
+		}
+		res = append(res, d)
+	}
+
+	return res, nil
+}
+
+func pluginDirectives(name string, field string, plugins []kfo.Plugin) (fluentd.Fragment, error) {
+	res := fluentd.Fragment{}
+	for i := range plugins {
+		d, err := pluginDirective(name, &plugins[i])
+		if err != nil {
+			return nil, fmt.Errorf("%s[%d]: %+v", field, i, err)
+		}
+		res = append(res, d)
+	}
+
+	return res, nil
+}
+
+func pluginDirective(name string, plugin *kfo.Plugin) (*fluentd.Directive, error) {
+	if plugin.Type == "" {
+		return nil, fmt.Errorf("type is required")
+	}
+
+	tag, err := directiveTag(plugin.Tag, plugin.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := directiveParams(plugin.Params)
+	if err != nil {
+		return nil, err
+	}
+	params["@type"] = &fluentd.Param{Name: "@type", Value: plugin.Type}
+
+	nested, err := sectionDirectives(plugin.Sections)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fluentd.Directive{
+		Name:   name,
+		Tag:    tag,
+		Params: params,
+		Nested: nested,
+	}, nil
+}
+
+func sectionDirectives(sections []kfo.Section) (fluentd.Fragment, error) {
+	res := fluentd.Fragment{}
+	for i := range sections {
+		s := &sections[i]
+		if s.Name == "" {
+			return nil, fmt.Errorf("sections[%d]: name is required", i)
+		}
+
+		params, err := directiveParams(s.Params)
+		if err != nil {
+			return nil, fmt.Errorf("sections[%d]: %+v", i, err)
+		}
+
+		nested, err := sectionDirectives(s.Sections)
+		if err != nil {
+			return nil, fmt.Errorf("sections[%d].%+v", i, err)
+		}
+
+		res = append(res, &fluentd.Directive{
+			Name:   s.Name,
+			Tag:    s.Arg,
+			Params: params,
+			Nested: nested,
+		})
+	}
+
+	return res, nil
+}
+
+func shareDirective(share *kfo.Share) (*fluentd.Directive, error) {
+	if len(share.Namespaces) == 0 {
+		return nil, fmt.Errorf("namespaces is required")
+	}
+
+	tag, err := directiveTag(share.Tag, share.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	stores := fluentd.Fragment{}
+	for _, ns := range share.Namespaces {
+		stores = append(stores, &fluentd.Directive{
+			Name:   "store",
+			Params: fluentd.ParamsFromKV("@type", "share", "with_namespace", ns),
+		})
+	}
+
+	for i := range share.Outputs {
+		d, err := pluginDirective("store", &share.Outputs[i])
+		if err != nil {
+			return nil, fmt.Errorf("outputs[%d]: %+v", i, err)
+		}
+		if d.Tag != "**" {
+			return nil, fmt.Errorf("outputs[%d]: tag and selector cannot be used in a share", i)
+		}
+		d.Tag = ""
+		stores = append(stores, d)
+	}
+
+	return &fluentd.Directive{
+		Name:   "match",
+		Tag:    tag,
+		Params: fluentd.ParamsFromKV("@type", "copy"),
+		Nested: stores,
+	}, nil
+}
+
+func receiverDirective(receiver *kfo.Receiver) (*fluentd.Directive, error) {
+	if receiver.FromNamespace == "" {
+		return nil, fmt.Errorf("fromNamespace is required")
+	}
+
+	filters, err := pluginDirectives("filter", "filters", receiver.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	outputs, err := pluginDirectives("match", "outputs", receiver.Outputs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fluentd.Directive{
+		Name:   "label",
+		Tag:    fmt.Sprintf("@$from(%s)", receiver.FromNamespace),
+		Params: fluentd.Params{},
+		Nested: append(filters, outputs...),
+	}, nil
+}
+
+func mountedFileDirective(mf *kfo.MountedFile) (*fluentd.Directive, error) {
+	if mf.Path == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+	if len(mf.Labels) == 0 {
+		return nil, fmt.Errorf("labels is required")
+	}
+
+	d := &fluentd.Directive{
+		Name:   "source",
+		Params: fluentd.ParamsFromKV("@type", "mounted-file", "path", mf.Path, "labels", joinLabels(mf.Labels)),
+		Nested: fluentd.Fragment{},
+	}
+
+	if mf.Parse != nil {
+		if mf.Parse.Type == "" {
+			return nil, fmt.Errorf("parse: type is required")
+		}
+		params, err := directiveParams(mf.Parse.Params)
+		if err != nil {
+			return nil, fmt.Errorf("parse: %+v", err)
+		}
+		params["@type"] = &fluentd.Param{Name: "@type", Value: mf.Parse.Type}
+		d.Nested = append(d.Nested, &fluentd.Directive{
+			Name:   "parse",
+			Params: params,
+		})
+	}
+
+	return d, nil
+}
+
+// directiveTag returns the tag pattern or the $labels macro for the selector,
+// matching all logs of the namespace when none is given
+func directiveTag(tag string, selector map[string]string) (string, error) {
+	if tag != "" && len(selector) > 0 {
+		return "", fmt.Errorf("tag and selector are mutually exclusive")
+	}
+
+	if len(selector) > 0 {
+		return fmt.Sprintf("$labels(%s)", joinLabels(selector)), nil
+	}
+
+	if tag == "" {
+		return "**", nil
+	}
+
+	return tag, nil
+}
+
+func directiveParams(kv map[string]string) (fluentd.Params, error) {
+	res := fluentd.Params{}
+	for k, v := range kv {
+		if k == "@type" || k == "type" {
+			return nil, fmt.Errorf("use the type field instead of the %s param", k)
+		}
+		if k == "" || strings.ContainsAny(k, " \t\n<>") {
+			return nil, fmt.Errorf("invalid param name %q", k)
+		}
+		if strings.Contains(v, "\n") {
+			return nil, fmt.Errorf("value of param %s cannot span multiple lines", k)
+		}
+		res[k] = &fluentd.Param{Name: k, Value: v}
+	}
+
+	return res, nil
+}
+
+func joinLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", k, labels[k]))
+	}
+
+	return strings.Join(pairs, ", ")
+}
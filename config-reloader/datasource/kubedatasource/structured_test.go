@@ -0,0 +1,136 @@
+package kubedatasource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	kfo "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/apis/logs.vdp.vmware.com/v1beta1"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
+)
+
+func TestRenderStructuredSpec(t *testing.T) {
+	spec := &kfo.FluentdConfigSpec{
+		MountedFiles: []kfo.MountedFile{
+			{
+				Path:   "/var/log/welcome.log",
+				Labels: map[string]string{"app": "grafana", "_container": "test"},
+				Parse:  &kfo.Parser{Type: "json"},
+			},
+		},
+		Filters: []kfo.Plugin{
+			{
+				Type:     "detect_exceptions",
+				Selector: map[string]string{"app": "jpetstore"},
+				Params:   map[string]string{"language": "java"},
+			},
+		},
+		Shares: []kfo.Share{
+			{
+				Namespaces: []string{"consumer"},
+				Selector:   map[string]string{"msg": "stdout"},
+				Outputs:    []kfo.Plugin{{Type: "null"}},
+			},
+		},
+		Outputs: []kfo.Plugin{
+			{
+				Type:   "logzio_buffered",
+				Params: map[string]string{"endpoint_url": "https://listener.logz.io:8071?token=secret"},
+				Sections: []kfo.Section{
+					{Name: "buffer", Arg: "tag", Params: map[string]string{"flush_interval": "5s"}},
+				},
+			},
+		},
+		Receivers: []kfo.Receiver{
+			{
+				FromNamespace: "producer",
+				Outputs:       []kfo.Plugin{{Type: "stdout"}},
+			},
+		},
+	}
+
+	expected := `<source>
+  @type mounted-file
+  labels _container=test, app=grafana
+  path /var/log/welcome.log
+
+  <parse>
+    @type json
+  </parse>
+</source>
+
+<filter $labels(app=jpetstore)>
+  @type detect_exceptions
+  language java
+</filter>
+
+<match $labels(msg=stdout)>
+  @type copy
+
+  <store>
+    @type share
+    with_namespace consumer
+  </store>
+  <store>
+    @type null
+  </store>
+</match>
+
+<match **>
+  @type logzio_buffered
+  endpoint_url https://listener.logz.io:8071?token=secret
+
+  <buffer tag>
+    flush_interval 5s
+  </buffer>
+</match>
+
+<label @$from(producer)>
+  <match **>
+    @type stdout
+  </match>
+</label>
+
+`
+	fragment, err := StructuredFragment(spec)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, fragment.String())
+
+	// the rendered config goes through the same parser as the raw config
+	reparsed, err := fluentd.ParseString(fragment.String())
+	assert.Nil(t, err)
+	assert.Equal(t, expected, reparsed.String())
+}
+
+func TestRenderFluentdConfigSpecKeepsRawConfig(t *testing.T) {
+	raw := "<match **>\n  @type null\n</match>\n"
+
+	conf, err := RenderFluentdConfigSpec(&kfo.FluentdConfigSpec{FluentConf: raw})
+	assert.Nil(t, err)
+	assert.Equal(t, raw, conf)
+
+	conf, err = RenderFluentdConfigSpec(&kfo.FluentdConfigSpec{
+		FluentConf: raw,
+		Filters:    []kfo.Plugin{{Type: "stdout"}},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, raw+"\n<filter **>\n  @type stdout\n</filter>\n\n", conf)
+}
+
+func TestRenderStructuredSpecErrors(t *testing.T) {
+	specs := []kfo.FluentdConfigSpec{
+		{Outputs: []kfo.Plugin{{}}},
+		{Outputs: []kfo.Plugin{{Type: "null", Tag: "**", Selector: map[string]string{"a": "b"}}}},
+		{Outputs: []kfo.Plugin{{Type: "null", Params: map[string]string{"@type": "stdout"}}}},
+		{Outputs: []kfo.Plugin{{Type: "null", Params: map[string]string{"key": "a\n</match>"}}}},
+		{Filters: []kfo.Plugin{{Type: "stdout", Sections: []kfo.Section{{}}}}},
+		{Shares: []kfo.Share{{}}},
+		{Shares: []kfo.Share{{Namespaces: []string{"a"}, Outputs: []kfo.Plugin{{Type: "null", Tag: "x"}}}}},
+		{Receivers: []kfo.Receiver{{}}},
+		{MountedFiles: []kfo.MountedFile{{Path: "/var/log/a.log"}}},
+	}
+
+	for i := range specs {
+		_, err := RenderFluentdConfigSpec(&specs[i])
+		assert.NotNil(t, err, "spec %d should be rejected", i)
+	}
+}
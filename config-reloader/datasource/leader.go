@@ -0,0 +1,97 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package datasource
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/config"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	leaseDuration = 15 * time.Second
+	renewDeadline = 10 * time.Second
+	retryPeriod   = 2 * time.Second
+)
+
+// leaderElection elects one of the replicas sharing the same deployment ID using a Lease.
+// Only the leader writes to the Kubernetes API, all replicas keep rendering and
+// reloading fluentd locally. A nil leaderElection always leads.
+type leaderElection struct {
+	leading atomic.Bool
+}
+
+// startLeaderElection joins the election in the background. onStartedLeading is called
+// every time this replica becomes the leader.
+func startLeaderElection(ctx context.Context, cfg *config.Config, client kubernetes.Interface, onStartedLeading func(context.Context)) (*leaderElection, error) {
+	identity, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      cfg.ID + "-leader",
+			Namespace: cfg.LeaderElectNS,
+		},
+		Client: client.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: identity,
+		},
+	}
+
+	l := &leaderElection{}
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   leaseDuration,
+		RenewDeadline:   renewDeadline,
+		RetryPeriod:     retryPeriod,
+		ReleaseOnCancel: true,
+		Name:            lock.LeaseMeta.Name,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				logrus.Infof("Became the leader, writing statuses to the Kubernetes API")
+				l.leading.Store(true)
+				onStartedLeading(ctx)
+			},
+			OnStoppedLeading: func() {
+				logrus.Infof("Stopped leading, no longer writing statuses to the Kubernetes API")
+				l.leading.Store(false)
+			},
+			OnNewLeader: func(id string) {
+				logrus.Debugf("Current leader is %s", id)
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		// Run returns when the leadership is lost, join the election again
+		for ctx.Err() == nil {
+			elector.Run(ctx)
+		}
+	}()
+
+	return l, nil
+}
+
+// IsLeader tells if this replica should write to the Kubernetes API
+func (l *leaderElection) IsLeader() bool {
+	if l == nil {
+		return true
+	}
+
+	return l.leading.Load()
+}
@@ -0,0 +1,194 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package datasource
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/vmware/kube-fluentd-operator/config-reloader/template"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/cache"
+)
+
+// when the informer of a kind cannot sync, for example without list and watch permissions,
+// the lookups of the kind fail right away for this long instead of waiting for it again
+const lookupRetryPeriod = 5 * time.Minute
+
+// informerLookups serves k8sLookup from informers instead of querying the API server on
+// every run. An informer is started the first time a kind is read in a namespace, it only
+// watches that namespace unless a lookup reads across namespaces or a cluster-scoped kind.
+type informerLookups struct {
+	mapper *restmapper.DeferredDiscoveryRESTMapper
+	dyn    dynamic.Interface
+	ctx    context.Context
+
+	lock      sync.Mutex
+	informers map[lookupKey]*lookupInformer
+	failed    map[lookupKey]time.Time
+}
+
+// lookupKey identifies an informer, the namespace is empty for the informers of a whole kind
+type lookupKey struct {
+	gvr       schema.GroupVersionResource
+	namespace string
+}
+
+type lookupInformer struct {
+	informer informers.GenericInformer
+	cancel   context.CancelFunc
+	// used tells if the informer was read since the last prune
+	used bool
+}
+
+var _ template.Backend = &informerLookups{}
+
+func newInformerLookups(ctx context.Context, dyn dynamic.Interface, disco discovery.DiscoveryInterface) *informerLookups {
+	return &informerLookups{
+		mapper:    restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(disco)),
+		dyn:       dyn,
+		ctx:       ctx,
+		informers: map[lookupKey]*lookupInformer{},
+		failed:    map[lookupKey]time.Time{},
+	}
+}
+
+func (l *informerLookups) restMapping(gvk schema.GroupVersionKind) (*meta.RESTMapping, error) {
+	mapping, err := l.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		// the kind may have been installed since the discovery was cached
+		l.mapper.Reset()
+		mapping, err = l.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	}
+	return mapping, err
+}
+
+// Namespaced tells from the discovery whether the kind is namespaced
+func (l *informerLookups) Namespaced(ctx context.Context, gvk schema.GroupVersionKind) (bool, error) {
+	mapping, err := l.restMapping(gvk)
+	if err != nil {
+		return false, err
+	}
+
+	return mapping.Scope.Name() == meta.RESTScopeNameNamespace, nil
+}
+
+// informerFor returns the synced informer of the kind for the namespace and whether the kind is namespaced
+func (l *informerLookups) informerFor(ctx context.Context, gvk schema.GroupVersionKind, namespace string) (informers.GenericInformer, bool, error) {
+	mapping, err := l.restMapping(gvk)
+	if err != nil {
+		return nil, false, err
+	}
+
+	namespaced := mapping.Scope.Name() == meta.RESTScopeNameNamespace
+	key := lookupKey{gvr: mapping.Resource}
+	if namespaced {
+		key.namespace = namespace
+	}
+
+	l.lock.Lock()
+	li, ok := l.informers[lookupKey{gvr: mapping.Resource}]
+	if !ok {
+		li, ok = l.informers[key]
+	}
+	if !ok {
+		if failedAt, failed := l.failed[key]; failed && time.Since(failedAt) < lookupRetryPeriod {
+			l.lock.Unlock()
+			return nil, false, fmt.Errorf("cannot sync informer of %s", mapping.Resource.String())
+		}
+
+		informerCtx, cancel := context.WithCancel(l.ctx)
+		li = &lookupInformer{
+			informer: dynamicinformer.NewFilteredDynamicInformer(l.dyn, mapping.Resource, key.namespace, 0,
+				cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, nil),
+			cancel: cancel,
+		}
+		l.informers[key] = li
+		go li.informer.Informer().Run(informerCtx.Done())
+	}
+	li.used = true
+	l.lock.Unlock()
+
+	if !cache.WaitForCacheSync(ctx.Done(), li.informer.Informer().HasSynced) {
+		// don't leave a reflector retrying in the background
+		l.lock.Lock()
+		if l.informers[key] == li {
+			li.cancel()
+			delete(l.informers, key)
+			l.failed[key] = time.Now()
+		}
+		l.lock.Unlock()
+		return nil, false, fmt.Errorf("cannot sync informer of %s", mapping.Resource.String())
+	}
+
+	return li.informer, namespaced, nil
+}
+
+// Prune stops the informers that were not read since the previous call
+func (l *informerLookups) Prune() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for key, li := range l.informers {
+		if !li.used {
+			li.cancel()
+			delete(l.informers, key)
+			continue
+		}
+		li.used = false
+	}
+}
+
+func (l *informerLookups) Get(ctx context.Context, gvk schema.GroupVersionKind, namespace string, name string) (*unstructured.Unstructured, error) {
+	informer, namespaced, err := l.informerFor(ctx, gvk, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	lister := informer.Lister()
+	var res runtime.Object
+	if namespaced {
+		res, err = lister.ByNamespace(namespace).Get(name)
+	} else {
+		res, err = lister.Get(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return res.(*unstructured.Unstructured).DeepCopy(), nil
+}
+
+func (l *informerLookups) List(ctx context.Context, gvk schema.GroupVersionKind, namespace string) ([]unstructured.Unstructured, error) {
+	informer, _, err := l.informerFor(ctx, gvk, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	// an empty namespace lists across all namespaces
+	objs, err := informer.Lister().ByNamespace(namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]unstructured.Unstructured, 0, len(objs))
+	for _, o := range objs {
+		res = append(res, *o.(*unstructured.Unstructured).DeepCopy())
+	}
+
+	return res, nil
+}
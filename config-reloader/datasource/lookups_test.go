@@ -0,0 +1,138 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package datasource
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestInformerLookups(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	makeConfigMap := func(namespace string, name string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion("v1")
+		u.SetKind("ConfigMap")
+		u.SetNamespace(namespace)
+		u.SetName(name)
+		return u
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	dyn := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{gvr: "ConfigMapList"},
+		makeConfigMap("infra", "shared"),
+		makeConfigMap("infra", "other"),
+		makeConfigMap("tenant", "own"),
+	)
+	disco := &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{}}
+	disco.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: []string{"get", "list", "watch"}},
+			},
+		},
+	}
+
+	lookups := newInformerLookups(ctx, dyn, disco)
+
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	u, err := lookups.Get(ctx, gvk, "infra", "shared")
+	assert.Nil(t, err)
+	assert.Equal(t, "shared", u.GetName())
+
+	_, err = lookups.Get(ctx, gvk, "tenant", "shared")
+	assert.True(t, errors.IsNotFound(err))
+
+	items, err := lookups.List(ctx, gvk, "infra")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(items))
+
+	items, err = lookups.List(ctx, gvk, "")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(items))
+
+	_, err = lookups.Get(ctx, schema.GroupVersionKind{Version: "v1", Kind: "Unknown"}, "infra", "shared")
+	assert.NotNil(t, err)
+
+	namespaced, err := lookups.Namespaced(ctx, gvk)
+	assert.Nil(t, err)
+	assert.True(t, namespaced)
+}
+
+func TestInformerLookupsScopeAndPrune(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cm := &unstructured.Unstructured{}
+	cm.SetAPIVersion("v1")
+	cm.SetKind("ConfigMap")
+	cm.SetNamespace("tenant")
+	cm.SetName("own")
+
+	cmGVR := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	secretGVR := schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	dyn := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{cmGVR: "ConfigMapList", secretGVR: "SecretList"}, cm)
+	dyn.PrependReactor("list", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "", nil)
+	})
+	disco := &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{}}
+	disco.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: []string{"get", "list", "watch"}},
+				{Name: "secrets", Kind: "Secret", Namespaced: true, Verbs: []string{"get", "list", "watch"}},
+				{Name: "namespaces", Kind: "Namespace", Namespaced: false, Verbs: []string{"get", "list", "watch"}},
+			},
+		},
+	}
+
+	lookups := newInformerLookups(ctx, dyn, disco)
+
+	// the informer only watches the namespace of the lookup
+	_, err := lookups.Get(ctx, schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, "tenant", "own")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(lookups.informers))
+	assert.NotNil(t, lookups.informers[lookupKey{gvr: cmGVR, namespace: "tenant"}])
+
+	// the scope comes from the discovery
+	namespaced, err := lookups.Namespaced(ctx, schema.GroupVersionKind{Version: "v1", Kind: "Namespace"})
+	assert.Nil(t, err)
+	assert.False(t, namespaced)
+
+	// the informers not read since the previous prune are stopped
+	lookups.Prune()
+	assert.Equal(t, 1, len(lookups.informers))
+	lookups.Prune()
+	assert.Equal(t, 0, len(lookups.informers))
+
+	// an informer that cannot sync is stopped and the kind fails right away afterwards
+	timeout, cancelTimeout := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancelTimeout()
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "Secret"}
+	_, err = lookups.Get(timeout, gvk, "tenant", "creds")
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, len(lookups.informers))
+
+	start := time.Now()
+	_, err = lookups.Get(ctx, gvk, "tenant", "creds")
+	assert.NotNil(t, err)
+	assert.True(t, time.Since(start) < time.Second)
+}
@@ -0,0 +1,146 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package datasource
+
+import (
+	"context"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	kfo "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/apis/logs.vdp.vmware.com/v1beta1"
+	kfoClient "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/client/clientset/versioned"
+	kfoInformers "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/client/informers/externalversions"
+	kfoListersV1beta1 "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/client/listers/logs.vdp.vmware.com/v1beta1"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/crd"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/metrics"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+// PolicySource is implemented by the datasources that know the FluentdPolicies
+type PolicySource interface {
+	// GetPolicies returns the FluentdPolicies that apply to the namespace, sorted by name
+	GetPolicies(ctx context.Context, namespace string) []*kfo.FluentdPolicy
+}
+
+var _ PolicySource = &kubeInformerConnection{}
+
+// newPolicyLister installs the FluentdPolicy CRD and watches the policies. Every change
+// triggers a run as the policies apply to the configs of many namespaces.
+func newPolicyLister(ctx context.Context, kubeCfg *rest.Config, updateChan chan time.Time) (kfoListersV1beta1.FluentdPolicyLister, cache.InformerSynced, error) {
+	kfocli, err := kfoClient.NewForConfig(kubeCfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	factory := kfoInformers.NewSharedInformerFactory(kfocli, 0)
+	informer := factory.Logs().V1beta1().FluentdPolicies().Informer()
+
+	notify := func(obj interface{}) {
+		metrics.IncInformerUpdates("fluentdpolicy")
+		select {
+		case updateChan <- time.Now():
+		default:
+		}
+	}
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: notify,
+		UpdateFunc: func(old, new interface{}) {
+			notify(new)
+		},
+		DeleteFunc: notify,
+	})
+
+	if err := crd.CheckAndInstallPolicyCRD(ctx, kubeCfg); err != nil {
+		return nil, nil, err
+	}
+
+	factory.Start(nil)
+
+	return factory.Logs().V1beta1().FluentdPolicies().Lister(), informer.HasSynced, nil
+}
+
+// GetPolicies returns the FluentdPolicies that apply to the namespace, sorted by name
+func (d *kubeInformerConnection) GetPolicies(ctx context.Context, namespace string) []*kfo.FluentdPolicy {
+	if d.policylist == nil {
+		return nil
+	}
+
+	var nsLabels map[string]string
+	if ns, err := d.nslist.Get(namespace); err == nil {
+		nsLabels = ns.Labels
+	}
+
+	policies, err := d.policylist.List(labels.Everything())
+	if err != nil {
+		logrus.Warnf("Cannot list fluentdpolicies: %+v", err)
+		return nil
+	}
+
+	return SelectPolicies(policies, namespace, nsLabels)
+}
+
+// SelectPolicies returns the policies that apply to the namespace, sorted by name
+func SelectPolicies(policies []*kfo.FluentdPolicy, namespace string, nsLabels map[string]string) []*kfo.FluentdPolicy {
+	res := []*kfo.FluentdPolicy{}
+	for _, p := range policies {
+		if policyApplies(&p.Spec, namespace, nsLabels) {
+			res = append(res, p)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+
+	return res
+}
+
+func policyApplies(spec *kfo.FluentdPolicySpec, namespace string, nsLabels map[string]string) bool {
+	if len(spec.Namespaces) == 0 && len(spec.NamespaceSelector) == 0 {
+		return true
+	}
+
+	for _, ns := range spec.Namespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+
+	return len(spec.NamespaceSelector) > 0 &&
+		labels.SelectorFromSet(spec.NamespaceSelector).Matches(labels.Set(nsLabels))
+}
+
+// AllowLookup returns whether the policies let k8sLookup read objects of the kind in another
+// namespace. The lookups outside of the namespace are refused unless a policy allows them.
+func AllowLookup(policies []*kfo.FluentdPolicy) func(kind string, namespace string) bool {
+	return func(kind string, namespace string) bool {
+		for _, p := range policies {
+			for _, rule := range p.Spec.Lookups {
+				if len(rule.Kinds) > 0 && !contains(rule.Kinds, kind) {
+					continue
+				}
+				for _, pattern := range rule.Namespaces {
+					if ok, _ := path.Match(pattern, namespace); ok {
+						return true
+					}
+				}
+			}
+		}
+		return false
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package datasource
+
+import (
+	"testing"
+
+	kfo "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/apis/logs.vdp.vmware.com/v1beta1"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSelectPolicies(t *testing.T) {
+	makePolicy := func(name string, spec kfo.FluentdPolicySpec) *kfo.FluentdPolicy {
+		return &kfo.FluentdPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       spec,
+		}
+	}
+
+	policies := []*kfo.FluentdPolicy{
+		makePolicy("tenants", kfo.FluentdPolicySpec{NamespaceSelector: map[string]string{"tier": "tenant"}}),
+		makePolicy("all", kfo.FluentdPolicySpec{}),
+		makePolicy("listed", kfo.FluentdPolicySpec{Namespaces: []string{"acme"}}),
+	}
+
+	names := func(policies []*kfo.FluentdPolicy) []string {
+		res := []string{}
+		for _, p := range policies {
+			res = append(res, p.Name)
+		}
+		return res
+	}
+
+	assert.Equal(t, []string{"all", "listed"}, names(SelectPolicies(policies, "acme", nil)))
+	assert.Equal(t, []string{"all", "tenants"}, names(SelectPolicies(policies, "shop", map[string]string{"tier": "tenant"})))
+	assert.Equal(t, []string{"all"}, names(SelectPolicies(policies, "kube-system", map[string]string{"tier": "system"})))
+}
+
+func TestAllowLookup(t *testing.T) {
+	policies := []*kfo.FluentdPolicy{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "shared"},
+			Spec: kfo.FluentdPolicySpec{
+				Lookups: []kfo.LookupRule{
+					{Kinds: []string{"ConfigMap"}, Namespaces: []string{"infra", "team-*"}},
+					{Namespaces: []string{"public"}},
+				},
+			},
+		},
+	}
+
+	allow := AllowLookup(policies)
+	assert.True(t, allow("ConfigMap", "infra"))
+	assert.True(t, allow("ConfigMap", "team-a"))
+	assert.False(t, allow("Secret", "infra"))
+	assert.True(t, allow("Secret", "public"))
+	assert.False(t, allow("ConfigMap", "kube-system"))
+	assert.False(t, allow("Namespace", ""))
+
+	assert.False(t, AllowLookup(nil)("ConfigMap", "infra"))
+}
@@ -0,0 +1,153 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package datasource
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/metrics"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/util"
+
+	"github.com/sirupsen/logrus"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+var _ SecretSource = &kubeInformerConnection{}
+
+// ReferencedSecrets returns the sorted names of the Secrets the config refers to with $secret.
+// Malformed macros are ignored, the processor reports them.
+func ReferencedSecrets(fragment fluentd.Fragment) []string {
+	names := map[string]bool{}
+	var walk func(fluentd.Fragment)
+	walk = func(directives fluentd.Fragment) {
+		for _, d := range directives {
+			for _, p := range d.Params {
+				if name, _, ok, err := util.ParseSecretMacro(p.Value); ok && err == nil {
+					names[name] = true
+				}
+			}
+			walk(d.Nested)
+		}
+	}
+	walk(fragment)
+
+	res := make([]string, 0, len(names))
+	for name := range names {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+
+	return res
+}
+
+// GetSecrets reads the Secrets from the API server rather than from an informer so that
+// the config-reloader does not keep every Secret of the cluster in memory
+func (d *kubeInformerConnection) GetSecrets(ctx context.Context, namespace string, names []string) map[string]map[string][]byte {
+	if len(names) == 0 {
+		return nil
+	}
+
+	res := map[string]map[string][]byte{}
+	for _, name := range names {
+		secret, err := d.client.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				logrus.Warnf("Cannot read secret %s/%s: %+v", namespace, name, err)
+			}
+			continue
+		}
+		res[name] = secret.Data
+	}
+
+	return res
+}
+
+// watchSecrets watches the Secrets referenced by the configs, by "namespace/name", so that
+// fluentd is reloaded when one changes. The watches of the Secrets no longer referenced are stopped.
+func (d *kubeInformerConnection) watchSecrets(referenced map[string]bool) {
+	if d.secretWatches == nil {
+		d.secretWatches = map[string]chan struct{}{}
+	}
+
+	for key, stop := range d.secretWatches {
+		if !referenced[key] {
+			close(stop)
+			delete(d.secretWatches, key)
+		}
+	}
+
+	for key := range referenced {
+		if _, ok := d.secretWatches[key]; ok {
+			continue
+		}
+
+		namespace, name, err := cache.SplitMetaNamespaceKey(key)
+		if err != nil {
+			continue
+		}
+		stop := make(chan struct{})
+		d.secretWatches[key] = stop
+		go d.newSecretInformer(namespace, name).Run(stop)
+	}
+}
+
+// newSecretInformer watches a single Secret. Only its metadata is kept, GetSecrets reads the data.
+func (d *kubeInformerConnection) newSecretInformer(namespace string, name string) cache.Controller {
+	selector := fields.OneTermEqualSelector("metadata.name", name).String()
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = selector
+			return d.client.CoreV1().Secrets(namespace).List(context.Background(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = selector
+			return d.client.CoreV1().Secrets(namespace).Watch(context.Background(), options)
+		},
+	}
+
+	stripData := func(obj interface{}) (interface{}, error) {
+		if secret, ok := obj.(*core.Secret); ok {
+			secret.Data = nil
+			secret.StringData = nil
+		}
+		return obj, nil
+	}
+
+	_, controller := cache.NewTransformingInformer(lw, &core.Secret{}, 0, cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			// the first run after the watch is started already read the Secret
+			if !isInInitialList {
+				d.notifySecretChange(namespace, name)
+			}
+		},
+		UpdateFunc: func(old, obj interface{}) {
+			if old.(*core.Secret).ResourceVersion != obj.(*core.Secret).ResourceVersion {
+				d.notifySecretChange(namespace, name)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			d.notifySecretChange(namespace, name)
+		},
+	}, stripData)
+
+	return controller
+}
+
+func (d *kubeInformerConnection) notifySecretChange(namespace string, name string) {
+	logrus.Infof("Detected change of secret %s in namespace: %s", name, namespace)
+	metrics.IncInformerUpdates("secret")
+	select {
+	case d.updateChan <- time.Now():
+	default:
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package datasource
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWatchSecretsNotifiesChanges(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: "ns-a", ResourceVersion: "1"},
+		Data:       map[string][]byte{"password": []byte("hunter2")},
+	}
+	client := testclient.NewSimpleClientset(secret)
+	updateChan := make(chan time.Time, 1)
+	d := &kubeInformerConnection{
+		client:     client,
+		updateChan: updateChan,
+	}
+
+	d.watchSecrets(map[string]bool{"ns-a/creds": true})
+	assert.Equal(t, 1, len(d.secretWatches))
+
+	// the secret was read by the run that started the watch
+	select {
+	case <-updateChan:
+		t.Fatal("the initial list must not trigger a run")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	secret = secret.DeepCopy()
+	secret.ResourceVersion = "2"
+	secret.Data["password"] = []byte("hunter3")
+	_, err := client.CoreV1().Secrets("ns-a").Update(context.Background(), secret, metav1.UpdateOptions{})
+	assert.Nil(t, err)
+
+	select {
+	case <-updateChan:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a changed secret must trigger a run")
+	}
+
+	d.watchSecrets(map[string]bool{})
+	assert.Equal(t, 0, len(d.secretWatches))
+}
@@ -0,0 +1,306 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package fluentd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// conformanceCase is a valid fluentd config and what the parser must make of it
+type conformanceCase struct {
+	name   string
+	config string
+	files  map[string]string
+	check  func(t *testing.T, f Fragment)
+}
+
+var conformanceCases = []conformanceCase{
+	{
+		name: "comments and blank lines",
+		config: `
+# a comment
+
+<source>   # after the tag
+  # before a param
+  @type forward
+
+  port 24224
+</source>  # after the closing tag
+`,
+		check: func(t *testing.T, f Fragment) {
+			assert.Equal(t, 1, len(f))
+			assert.Equal(t, "forward", f[0].Type())
+			assert.Equal(t, "24224", f[0].Param("port"))
+		},
+	},
+	{
+		name: "multiple patterns, labels and worker ranges",
+		config: `
+<match app.** system.{a,b}>
+  @type null
+</match>
+<label @ERRORS>
+  <match **>
+    @type null
+  </match>
+</label>
+<worker 0-1>
+  <source>
+    @type sample
+  </source>
+</worker>
+`,
+		check: func(t *testing.T, f Fragment) {
+			assert.Equal(t, 3, len(f))
+			assert.Equal(t, "app.** system.{a,b}", f[0].Tag)
+			assert.Equal(t, "@ERRORS", f[1].Tag)
+			assert.Equal(t, "match", f[1].Nested[0].Name)
+			assert.Equal(t, "0-1", f[2].Tag)
+			assert.Equal(t, "sample", f[2].Nested[0].Type())
+		},
+	},
+	{
+		name: "deeply nested sections and params without value",
+		config: `
+<match **>
+  @type copy
+  <store>
+    @type elasticsearch
+    include_tag_key
+    <buffer tag, time>
+      timekey 1h
+      <secondary>
+        @type file
+      </secondary>
+    </buffer>
+  </store>
+</match>
+`,
+		check: func(t *testing.T, f Fragment) {
+			store := f[0].Nested[0]
+			assert.Equal(t, "", store.ParamVerbatim("include_tag_key"))
+			assert.Equal(t, "tag, time", store.Nested[0].Tag)
+			assert.Equal(t, "1h", store.Nested[0].Param("timekey"))
+			assert.Equal(t, "file", store.Nested[0].Nested[0].Type())
+		},
+	},
+	{
+		name: "quoted strings",
+		config: `
+<filter **>
+  @type record_transformer
+  hash "a#b"
+  single 'it''s'
+  escaped "say \"hi\""
+</filter>
+`,
+		check: func(t *testing.T, f Fragment) {
+			assert.Equal(t, `"a#b"`, f[0].ParamVerbatim("hash"))
+			assert.Equal(t, `'it''s'`, f[0].ParamVerbatim("single"))
+			assert.Equal(t, `"say \"hi\""`, f[0].ParamVerbatim("escaped"))
+		},
+	},
+	{
+		name: "multi-line double quoted string",
+		config: `
+<filter **>
+  @type grep
+  message "first line
+second line \" still quoted
+  last line"
+  next 1
+</filter>
+`,
+		check: func(t *testing.T, f Fragment) {
+			assert.Equal(t, "\"first line\nsecond line \\\" still quoted\n  last line\"", f[0].ParamVerbatim("message"))
+			assert.Equal(t, "1", f[0].Param("next"))
+			assert.Equal(t, 3, len(f[0].Params))
+		},
+	},
+	{
+		name: "multi-line single quoted string",
+		config: `
+<filter **>
+  @type grep
+  message 'first line
+  <not a directive>'
+</filter>
+`,
+		check: func(t *testing.T, f Fragment) {
+			assert.Equal(t, "'first line\n  <not a directive>'", f[0].ParamVerbatim("message"))
+			assert.Equal(t, 0, len(f[0].Nested))
+		},
+	},
+	{
+		name: "multi-line array and hash",
+		config: `
+<match **>
+  @type http
+  keys ["a",
+        "b]",
+        "c"]
+  headers {
+    "X-Token": "abc",
+    "X-Nested": {"a": [1, 2]}
+  }
+  json_array true
+</match>
+`,
+		check: func(t *testing.T, f Fragment) {
+			assert.Equal(t, "[\"a\",\n        \"b]\",\n        \"c\"]", f[0].ParamVerbatim("keys"))
+			assert.Equal(t, "{\n    \"X-Token\": \"abc\",\n    \"X-Nested\": {\"a\": [1, 2]}\n  }", f[0].ParamVerbatim("headers"))
+			assert.Equal(t, "true", f[0].Param("json_array"))
+		},
+	},
+	{
+		name: "single line arrays, hashes and regexps",
+		config: `
+<filter **>
+  @type parser
+  keys ["a", "b"]
+  mapping {"a": "b"}
+  expression /^\[(?<time>[^\]]*)\] (?<message>.*)$/
+</filter>
+`,
+		check: func(t *testing.T, f Fragment) {
+			assert.Equal(t, `["a", "b"]`, f[0].ParamVerbatim("keys"))
+			assert.Equal(t, `{"a": "b"}`, f[0].ParamVerbatim("mapping"))
+			assert.Equal(t, `/^\[(?<time>[^\]]*)\] (?<message>.*)$/`, f[0].ParamVerbatim("expression"))
+		},
+	},
+	{
+		name: "embedded ruby",
+		config: `
+<match **>
+  @type forward
+  host "#{ENV['HOST']}"
+  tags ["a", "#{Socket.gethostname}"]
+  literal '#{not evaluated}'
+  escaped "\#{not evaluated}"
+  placeholder ${tag}
+</match>
+`,
+		check: func(t *testing.T, f Fragment) {
+			assert.True(t, f[0].Params["host"].EmbeddedRuby())
+			assert.True(t, f[0].Params["tags"].EmbeddedRuby())
+			assert.False(t, f[0].Params["literal"].EmbeddedRuby())
+			assert.False(t, f[0].Params["escaped"].EmbeddedRuby())
+			assert.False(t, f[0].Params["placeholder"].EmbeddedRuby())
+		},
+	},
+	{
+		name: "@include at the top level and in a directive",
+		config: `
+@include sources/*.conf
+<match **>
+  @type forward
+  @include server.conf
+</match>
+`,
+		files: map[string]string{
+			"sources/b.conf": "<source>\n  @type tail\n</source>",
+			"sources/a.conf": "<source>\n  @type forward\n</source>",
+			"server.conf":    "<server>\n  host a.example.com\n</server>\nheartbeat_type none",
+			"unused.conf":    "<match>",
+		},
+		check: func(t *testing.T, f Fragment) {
+			assert.Equal(t, 3, len(f))
+			assert.Equal(t, "forward", f[0].Type())
+			assert.Equal(t, Position{File: "sources/a.conf", Line: 1, Column: 1}, f[0].Pos)
+			assert.Equal(t, "tail", f[1].Type())
+			assert.Equal(t, "none", f[2].Param("heartbeat_type"))
+			assert.Equal(t, "a.example.com", f[2].Nested[0].Param("host"))
+		},
+	},
+}
+
+func TestParserConformance(t *testing.T) {
+	for _, c := range conformanceCases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			f, err := ParseWithIncludes("fluent.conf", c.config, c.files)
+			assert.Nil(t, err)
+			if err != nil {
+				return
+			}
+			c.check(t, f)
+
+			// the printed config parses to the same config
+			again, err := ParseWithIncludes("fluent.conf", f.String(), nil)
+			assert.Nil(t, err)
+			assert.Equal(t, f.String(), again.String())
+
+			// formatting keeps everything
+			doc, err := ParseDocument("fluent.conf", c.config)
+			assert.Nil(t, err)
+			assert.Empty(t, doc.Skipped)
+			again, err = ParseWithIncludes("fluent.conf", doc.Format(), c.files)
+			assert.Nil(t, err)
+			assert.Equal(t, f.String(), again.String())
+		})
+	}
+}
+
+func TestParserConformanceErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		config string
+		files  map[string]string
+		err    string
+	}{
+		{
+			name:   "unterminated string",
+			config: "<match **>\n  @type null\n  message \"abc\n</match>\n",
+			err:    "fluent.conf, line 1: syntax error: incomplete directive <match>; fluent.conf, line 3: syntax error: unterminated value of message",
+		},
+		{
+			name:   "unterminated array",
+			config: "<match **>\n  keys [\"a\",\n</match>\n",
+			err:    "fluent.conf, line 1: syntax error: incomplete directive <match>; fluent.conf, line 2: syntax error: unterminated value of keys",
+		},
+		{
+			name:   "missing include",
+			config: "@include other.conf\n",
+			err:    "fluent.conf, line 1: cannot @include other.conf: no such file",
+		},
+		{
+			name:   "include cycle",
+			config: "@include a.conf\n",
+			files: map[string]string{
+				"a.conf": "@include b.conf",
+				"b.conf": "<match **>\n  @type null\n</match>\n@include a.conf",
+			},
+			err: "b.conf, line 4: @include cycle: a.conf -> b.conf -> a.conf",
+		},
+		{
+			name:   "errors in included files",
+			config: "<match **>\n  @include params.conf\n</match>\n",
+			files: map[string]string{
+				"params.conf": "@type null\n</match>",
+			},
+			err: "params.conf, line 2: syntax error: </match> closes no directive",
+		},
+		{
+			name:   "dangling included param",
+			config: "@include params.conf\n",
+			files: map[string]string{
+				"params.conf": "@type null",
+			},
+			err: "params.conf, line 1: syntax error: dangling parameter @type",
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			_, err := ParseWithIncludes("fluent.conf", c.config, c.files)
+			assert.NotNil(t, err)
+			if err != nil {
+				assert.Equal(t, c.err, err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package fluentd
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// Format prints the document like gofmt would: the comments, the order of the params
+// and their values as written are kept, indentation is normalized to two spaces and
+// top-level directives are separated by a single blank line.
+func (doc *Document) Format() string {
+	buf := &bytes.Buffer{}
+
+	formatFragment(buf, doc.Fragment)
+	if len(doc.Comments) > 0 {
+		if len(doc.Fragment) > 0 {
+			buf.WriteString("\n")
+		}
+		writeComments(buf, doc.Comments, 0)
+	}
+
+	return buf.String()
+}
+
+// Format prints the fragment keeping the layout recorded by ParseDocument. Unlike String
+// the params are not sorted: params added by the processors follow the parsed ones.
+func (f Fragment) Format() string {
+	buf := &bytes.Buffer{}
+	formatFragment(buf, f)
+	return buf.String()
+}
+
+func formatFragment(buf *bytes.Buffer, f Fragment) {
+	for i, d := range f {
+		// consecutive @include lines stay together
+		if i > 0 && !(d.Name == IncludeDirective && f[i-1].Name == IncludeDirective && !d.BlankBefore) {
+			buf.WriteString("\n")
+		}
+		d.format(buf, 0)
+	}
+}
+
+// element is a param or a nested directive
+type element struct {
+	param     *Param
+	directive *Directive
+}
+
+func (e element) layout() Layout {
+	if e.param != nil {
+		return e.param.Layout
+	}
+	return e.directive.Layout
+}
+
+// elements merges the params and the nested directives in the order they were parsed,
+// included files too
+func (d *Directive) elements() []element {
+	var parsed, addedParams, addedNested []element
+
+	for _, k := range sortedKeys(d.Params) {
+		p := d.Params[k]
+		if p.seq > 0 {
+			parsed = append(parsed, element{param: p})
+		} else {
+			addedParams = append(addedParams, element{param: p})
+		}
+	}
+
+	for _, n := range d.Nested {
+		if n.seq > 0 {
+			parsed = append(parsed, element{directive: n})
+		} else {
+			addedNested = append(addedNested, element{directive: n})
+		}
+	}
+
+	sort.SliceStable(parsed, func(i, j int) bool {
+		return parsed[i].layout().seq < parsed[j].layout().seq
+	})
+
+	// the added params go after the last parsed one
+	at := 0
+	for i, e := range parsed {
+		if e.param != nil {
+			at = i + 1
+		}
+	}
+
+	res := make([]element, 0, len(parsed)+len(addedParams)+len(addedNested))
+	res = append(res, parsed[:at]...)
+	res = append(res, addedParams...)
+	res = append(res, parsed[at:]...)
+	return append(res, addedNested...)
+}
+
+func (d *Directive) format(buf *bytes.Buffer, indent int) {
+	writeComments(buf, d.Comments, indent)
+
+	writeIndent(buf, indent)
+	if d.Name == IncludeDirective {
+		buf.WriteString(d.Name + " " + d.Tag)
+		writeLineComment(buf, d.LineComment)
+		return
+	}
+
+	t := d.Tag
+	if t != "" {
+		t = " " + t
+	}
+	buf.WriteString(fmt.Sprintf("<%s%s>", d.Name, t))
+	writeLineComment(buf, d.LineComment)
+
+	var prev *element
+	for _, e := range d.elements() {
+		e := e
+		if prev != nil && (e.layout().BlankBefore || e.layout().seq == 0 && e.directive != nil && prev.param != nil) {
+			buf.WriteString("\n")
+		}
+		prev = &e
+
+		if e.directive != nil {
+			e.directive.format(buf, indent+2)
+			continue
+		}
+
+		p := e.param
+		writeComments(buf, p.Comments, indent+2)
+		writeIndent(buf, indent+2)
+		buf.WriteString(p.Name)
+		if p.Value != "" {
+			buf.WriteString(" ")
+			buf.WriteString(p.Value)
+		}
+		writeLineComment(buf, p.LineComment)
+	}
+
+	writeComments(buf, d.EndComments, indent+2)
+	writeIndent(buf, indent)
+	buf.WriteString(fmt.Sprintf("</%s>", d.Name))
+	writeLineComment(buf, d.EndLineComment)
+}
+
+func writeComments(buf *bytes.Buffer, comments []string, indent int) {
+	for _, c := range comments {
+		writeIndent(buf, indent)
+		buf.WriteString(c)
+		buf.WriteString("\n")
+	}
+}
+
+func writeLineComment(buf *bytes.Buffer, comment string) {
+	if comment != "" {
+		buf.WriteString(" ")
+		buf.WriteString(comment)
+	}
+	buf.WriteString("\n")
+}
@@ -0,0 +1,102 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package fluentd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatNormalizesLayout(t *testing.T) {
+	s := `
+# send everything to ES
+
+
+<match **>   # the catch-all
+    @type elasticsearch   # bundled plugin
+    port 9200
+      host "es.example.com"
+
+    # keep the buffer small
+  <buffer>
+  flush_interval 5s
+  # more to come
+  </buffer>
+  include_tag_key
+</match>
+<filter **>
+ @type stdout
+</filter>   # end
+
+# trailing
+`
+	expected := `# send everything to ES
+<match **> # the catch-all
+  @type elasticsearch # bundled plugin
+  port 9200
+  host "es.example.com"
+
+  # keep the buffer small
+  <buffer>
+    flush_interval 5s
+    # more to come
+  </buffer>
+  include_tag_key
+</match>
+
+<filter **>
+  @type stdout
+</filter> # end
+
+# trailing
+`
+
+	doc, err := ParseDocument("", s)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, doc.Format())
+
+	// formatting is idempotent
+	doc, err = ParseDocument("", expected)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, doc.Format())
+}
+
+func TestFormatPlacesAddedElements(t *testing.T) {
+	s := `
+<match **>
+  @type   copy # fan out
+  <store>
+    @type null
+  </store>
+</match>
+`
+	fragment, err := ParseString(s)
+	assert.Nil(t, err)
+
+	match := fragment[0]
+	match.SetParam("zz_added", "1")
+	match.SetParam("aa_added", "2")
+	match.Nested = append(match.Nested, &Directive{
+		Name:   "store",
+		Params: ParamsFromKV("@type", "stdout"),
+	})
+
+	expected := `<match **>
+  @type copy # fan out
+  aa_added 2
+  zz_added 1
+  <store>
+    @type null
+  </store>
+  <store>
+    @type stdout
+  </store>
+</match>
+`
+	assert.Equal(t, expected, fragment.Format())
+
+	match.SetParam("@type", "relabel")
+	assert.Equal(t, "", match.Params["@type"].LineComment)
+}
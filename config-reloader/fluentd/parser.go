@@ -5,8 +5,8 @@ package fluentd
 
 import (
 	"bytes"
-	"errors"
 	"fmt"
+	"path"
 	"regexp"
 	"sort"
 	"strings"
@@ -41,12 +41,48 @@ type Directive struct {
 	Tag    string
 	Params Params
 	Nested Fragment
+	Pos    Position
+	Layout
+	// EndComments are the comments before the closing tag
+	EndComments []string
+	// EndLineComment follows the closing tag
+	EndLineComment string
 }
 
 // Param just holds a Name/Value pair
 type Param struct {
 	Name  string
 	Value string
+	Pos   Position
+	Layout
+}
+
+// Layout is what Format needs to print a directive or a param like in the parsed config,
+// the other printers ignore it
+type Layout struct {
+	// Comments are the comment lines before the directive or the param
+	Comments []string
+	// BlankBefore is set when blank lines precede the comments
+	BlankBefore bool
+	// LineComment follows the opening tag of a directive, or the @type of a plugin.
+	// The comments after the other params are part of their value.
+	LineComment string
+
+	// seq is the order of the parsed elements, 0 for those added by the processors
+	seq int
+}
+
+// Clone returns a deep copy of the layout
+func (l Layout) Clone() Layout {
+	l.Comments = cloneStrings(l.Comments)
+	return l
+}
+
+func cloneStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string{}, s...)
 }
 
 // Type return the @type parameter or type parameter. "" if not type is defined
@@ -86,13 +122,20 @@ func (d *Directive) Clone() *Directive {
 		Tag:    d.Tag,
 		Params: d.Params.Clone(),
 		Nested: d.Nested.Clone(),
+		Pos:    d.Pos,
+		Layout: d.Layout.Clone(),
+
+		EndComments:    cloneStrings(d.EndComments),
+		EndLineComment: d.EndLineComment,
 	}
 }
 
 func (p *Param) Clone() *Param {
 	return &Param{
-		Name:  p.Name,
-		Value: p.Value,
+		Name:   p.Name,
+		Value:  p.Value,
+		Pos:    p.Pos,
+		Layout: p.Layout.Clone(),
 	}
 }
 
@@ -156,8 +199,10 @@ func (d *Directive) SetParam(name string, value string) {
 			Value: value,
 		}
 		d.Params[name] = p
-	} else {
+	} else if p.Value != value {
 		p.Value = value
+		// a comment on the @type no longer describes the new value
+		p.LineComment = ""
 	}
 }
 
@@ -185,6 +230,12 @@ func sortedKeys(m Params) []string {
 func (d *Directive) stringIndent(indent int) string {
 	var buffer bytes.Buffer
 
+	if d.Name == IncludeDirective {
+		writeIndent(&buffer, indent)
+		buffer.WriteString(fmt.Sprintf("%s %s\n", d.Name, d.Tag))
+		return buffer.String()
+	}
+
 	writeIndent(&buffer, indent)
 	t := d.Tag
 	if t != "" {
@@ -212,6 +263,29 @@ func (d *Directive) stringIndent(indent int) string {
 	return buffer.String()
 }
 
+// EmbeddedRuby tells whether fluentd runs Ruby code to get the value: this is the case
+// for "#{...}" in double quoted strings, also in arrays and hashes
+func (p *Param) EmbeddedRuby() bool {
+	v := p.Value
+	if v == "" || !strings.ContainsRune(`"[{`, rune(v[0])) {
+		return false
+	}
+
+	inString := false
+	for i := 0; i < len(v); i++ {
+		switch c := v[i]; {
+		case inString && c == '\\':
+			i++
+		case c == '"':
+			inString = !inString
+		case inString && strings.HasPrefix(v[i:], "#{"):
+			return true
+		}
+	}
+
+	return false
+}
+
 func (p *Param) String() string {
 	return fmt.Sprintf("%s %s\n", p.Name, p.Value)
 }
@@ -233,21 +307,126 @@ func topDir(s *Stack) *Directive {
 }
 
 // ParseString produces a fragment of fluentd config part
-// nolint:gocognit
 func ParseString(s string) (Fragment, error) {
-	res := []*Directive{}
+	return ParseFile("", s)
+}
+
+// ParseFile works like ParseString and records the name of the file in the positions.
+// It reports all the syntax errors as ParseErrors rather than stopping at the first one.
+func ParseFile(filename string, s string) (Fragment, error) {
+	return ParseWithIncludes(filename, s, nil)
+}
+
+// ParseWithIncludes works like ParseFile and replaces the @include lines with the contents
+// of the files they name. The files are the other entries of the ConfigMap or FluentdConfig
+// holding the config, an @include can name one of them or a glob pattern like *.conf.
+func ParseWithIncludes(filename string, s string, files map[string]string) (Fragment, error) {
+	p := &parser{
+		files:   files,
+		resolve: true,
+	}
+
+	doc, err := p.parseDocument(filename, s)
+	if err != nil {
+		return nil, err
+	}
+
+	return doc.Fragment, nil
+}
+
+// Document is a parsed config together with the comments after its last directive
+type Document struct {
+	Fragment Fragment
+	Comments []string
+	// Skipped are the lines the parser ignored, they are lost when the document is printed
+	Skipped []Position
+	// Duplicates are the params that replaced an earlier param of the same name in their
+	// directive, only the last one is printed
+	Duplicates []Position
+}
+
+// ParseDocument parses a whole config file, keeping the layout Format needs. The @include
+// lines are kept as directives named @include with the included path as tag.
+func ParseDocument(filename string, s string) (*Document, error) {
+	p := &parser{}
+	return p.parseDocument(filename, s)
+}
+
+// IncludeDirective is the name of the directives ParseDocument records for @include lines
+const IncludeDirective = "@include"
+
+type parser struct {
+	// files are what @include refers to
+	files map[string]string
+	// resolve replaces the @include lines with the files they name
+	resolve bool
+	// including are the files being included, to detect cycles
+	including []string
+	// seq numbers the parsed directives and params in the order they appear
+	seq        int
+	errs       ParseErrors
+	skipped    []Position
+	duplicates []Position
+}
+
+func (p *parser) parseDocument(filename string, s string) (*Document, error) {
+	// the document root holds the top-level directives
+	root := &Directive{
+		Params: Params{},
+	}
+	comments := p.parse(filename, s, root)
+
+	if len(p.errs) > 0 {
+		sort.SliceStable(p.errs, func(i, j int) bool {
+			if p.errs[i].Pos.File != p.errs[j].Pos.File {
+				return p.errs[i].Pos.File < p.errs[j].Pos.File
+			}
+			return p.errs[i].Pos.Line < p.errs[j].Pos.Line
+		})
+		return nil, p.errs
+	}
+
+	return &Document{
+		Fragment:   root.Nested,
+		Comments:   comments,
+		Skipped:    p.skipped,
+		Duplicates: p.duplicates,
+	}, nil
+}
+
+// parse adds the contents of a file to the directive and returns the comments after the
+// last directive or param
+// nolint:gocognit
+func (p *parser) parse(filename string, s string, into *Directive) []string {
+	isRoot := into.Name == ""
+
+	// the layout of the next directive or param
+	var layout Layout
 
 	stack := NewStack()
+	stack.Push(into)
 	lines := strings.Split(s, "\n")
-	for _, line := range lines {
-		if line == "" {
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" {
+			layout.BlankBefore = layout.BlankBefore || len(layout.Comments) == 0
 			continue
 		}
 
 		if reComment.MatchString(line) {
+			layout.Comments = append(layout.Comments, util.Trim(line))
 			continue
 		}
 
+		pos := Position{
+			File:   filename,
+			Line:   i + 1,
+			Column: len(line) - len(strings.TrimLeft(line, " \t")) + 1,
+		}
+		syntaxError := func(format string, args ...interface{}) {
+			p.errs = append(p.errs, &PositionError{Pos: pos, Err: fmt.Errorf(format, args...)})
+		}
+
 		line = util.Trim(line)
 
 		start := reStartDirective.FindStringSubmatch(line)
@@ -255,17 +434,17 @@ func ParseString(s string) (Fragment, error) {
 			d := &Directive{
 				Name:   util.Trim(start[1]),
 				Params: Params{},
+				Pos:    pos,
+				Layout: p.next(layout),
 			}
+			d.LineComment = lineComment(line[len(start[0]):])
+			layout = Layout{}
 			if len(start) > 2 {
 				d.Tag = util.Trim(start[3])
 			}
 
-			if stack.Len() == 0 {
-				res = append(res, d)
-			} else {
-				top := topDir(stack)
-				top.Nested = append(top.Nested, d)
-			}
+			top := topDir(stack)
+			top.Nested = append(top.Nested, d)
 			stack.Push(d)
 
 			continue
@@ -275,45 +454,189 @@ func ParseString(s string) (Fragment, error) {
 		if len(end) > 0 {
 			name := end[1]
 
-			if stack.Len() == 0 {
-				return nil, errors.New("syntax error")
+			if stack.Len() == 1 {
+				syntaxError("syntax error: </%s> closes no directive", name)
+				continue
 			}
 			top := topDir(stack)
 
 			if top.Name == name {
+				top.EndComments = layout.Comments
+				top.EndLineComment = lineComment(line[len(end[0]):])
+				layout = Layout{}
 				stack.Pop()
 				continue
 			}
 
-			return nil, errors.New("mismatched tags")
+			syntaxError("mismatched tags: </%s> closes <%s> of line %d", name, top.Name, top.Pos.Line)
+			// carry on after the directive the tag closes if it is open, a typo closes the
+			// innermost directive
+			if stack.Contains(func(v interface{}) bool { return v != into && v.(*Directive).Name == name }) {
+				for topDir(stack).Name != name {
+					stack.Pop()
+				}
+			}
+			stack.Pop()
+			continue
 		}
 
-		p := reParam.FindStringSubmatch(line)
-		if len(p) > 0 {
+		m := reParam.FindStringSubmatch(line)
+		if len(m) > 0 {
 			param := &Param{
-				Name: p[1],
+				Name:   m[1],
+				Pos:    pos,
+				Layout: layout,
 			}
-			if len(p) > 2 {
-				param.Value = p[3]
-				// special handling for @type as it is processed
-				if param.Name == "type" || param.Name == "@type" {
-					param.Value = util.TrimTrailingComment(param.Value)
-				}
+			layout = Layout{}
+			if len(m) > 2 {
+				param.Value = m[3]
 			}
 
-			if stack.Len() == 0 {
-				return nil, fmt.Errorf("syntax error: dangling parameter %s", param.Name)
+			// quoted strings, arrays and hashes can span lines
+			for valueIsOpen(param.Value) && i+1 < len(lines) {
+				i++
+				param.Value += "\n" + strings.TrimRight(lines[i], " \t\r")
 			}
+			if valueIsOpen(param.Value) {
+				syntaxError("syntax error: unterminated value of %s", param.Name)
+				continue
+			}
+
+			// special handling for @type as it is processed
+			if param.Name == "type" || param.Name == "@type" {
+				if i := strings.IndexByte(param.Value, '#'); i > 0 {
+					param.LineComment = util.Trim(param.Value[i:])
+				}
+				param.Value = util.TrimTrailingComment(param.Value)
+			}
+
 			top := topDir(stack)
+
+			if param.Name == IncludeDirective {
+				p.include(param, top)
+				continue
+			}
+
+			if isRoot && stack.Len() == 1 {
+				syntaxError("syntax error: dangling parameter %s", param.Name)
+				continue
+			}
+			param.Layout = p.next(param.Layout)
+			if _, ok := top.Params[param.Name]; ok {
+				p.duplicates = append(p.duplicates, pos)
+			}
 			top.Params[param.Name] = param
 
 			continue
 		}
+
+		p.skipped = append(p.skipped, pos)
+	}
+
+	for stack.Len() > 1 {
+		d := stack.Pop().(*Directive)
+		p.errs = append(p.errs, &PositionError{Pos: d.Pos, Err: fmt.Errorf("syntax error: incomplete directive <%s>", d.Name)})
+	}
+
+	return layout.Comments
+}
+
+// next numbers the layout of a parsed directive or param
+func (p *parser) next(layout Layout) Layout {
+	p.seq++
+	layout.seq = p.seq
+	return layout
+}
+
+// include adds the files named by an @include to the directive, or records the @include
+// when not resolving
+func (p *parser) include(param *Param, into *Directive) {
+	pattern := util.TrimTrailingComment(param.Value)
+
+	if !p.resolve {
+		d := &Directive{
+			Name:   IncludeDirective,
+			Tag:    pattern,
+			Params: Params{},
+			Pos:    param.Pos,
+			Layout: p.next(param.Layout),
+		}
+		if i := strings.IndexByte(param.Value, '#'); i > 0 {
+			d.LineComment = util.Trim(param.Value[i:])
+		}
+		into.Nested = append(into.Nested, d)
+		return
 	}
 
-	if stack.Len() != 0 {
-		return nil, errors.New("syntax error: incomplete directive")
+	includeError := func(format string, args ...interface{}) {
+		p.errs = append(p.errs, &PositionError{Pos: param.Pos, Err: fmt.Errorf(format, args...)})
 	}
 
-	return res, nil
+	names := []string{}
+	for _, name := range util.SortedKeys(p.files) {
+		if ok, _ := path.Match(strings.TrimPrefix(pattern, "./"), name); ok {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 && !strings.ContainsAny(pattern, "*?[") {
+		includeError("cannot @include %s: no such file", pattern)
+		return
+	}
+
+	for _, name := range names {
+		for i, f := range p.including {
+			if f == name {
+				includeError("@include cycle: %s -> %s", strings.Join(p.including[i:], " -> "), name)
+				return
+			}
+		}
+
+		p.including = append(p.including, name)
+		p.parse(name, p.files[name], into)
+		p.including = p.including[:len(p.including)-1]
+	}
+}
+
+// valueIsOpen tells whether a quoted string, an array or a hash continues on the next line
+func valueIsOpen(v string) bool {
+	if v == "" || !strings.ContainsRune(`"'[{`, rune(v[0])) {
+		return false
+	}
+
+	var quote byte
+	depth := 0
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+				if depth == 0 {
+					return false
+				}
+			}
+		case c == '"' || (c == '\'' && depth == 0):
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+			if depth == 0 {
+				return false
+			}
+		}
+	}
+
+	return quote != 0 || depth > 0
+}
+
+// lineComment returns the comment in what follows a tag
+func lineComment(rest string) string {
+	rest = util.Trim(rest)
+	if strings.HasPrefix(rest, "#") {
+		return rest
+	}
+	return ""
 }
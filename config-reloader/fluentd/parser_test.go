@@ -31,6 +31,55 @@ func TestParseErrors(t *testing.T) {
 	}
 }
 
+func TestParseReportsAllErrors(t *testing.T) {
+	s := `
+dangling value
+<match **>
+  @type null
+</filter>
+<filter **>
+  @type stdout
+</filter>
+<match a.**>
+  @type null
+`
+	_, err := ParseFile("ns.conf", s)
+	assert.NotNil(t, err)
+
+	errs, ok := err.(ParseErrors)
+	assert.True(t, ok)
+	assert.Equal(t, 3, len(errs))
+	assert.Equal(t, "ns.conf, line 2: syntax error: dangling parameter dangling", errs[0].Error())
+	assert.Equal(t, "ns.conf, line 5: mismatched tags: </filter> closes <match> of line 3", errs[1].Error())
+	assert.Equal(t, "ns.conf, line 9: syntax error: incomplete directive <match>", errs[2].Error())
+}
+
+func TestParseRecordsPositions(t *testing.T) {
+	s := `# comment
+<match **>
+  @type copy
+  <store>
+    @type null
+  </store>
+</match>
+`
+	fragment, err := ParseString(s)
+	assert.Nil(t, err)
+
+	d := fragment[0]
+	assert.Equal(t, Position{Line: 2, Column: 1}, d.Pos)
+	assert.Equal(t, Position{Line: 3, Column: 3}, d.Params["@type"].Pos)
+	assert.Equal(t, Position{Line: 4, Column: 3}, d.Nested[0].Pos)
+	assert.Equal(t, d.Pos, d.Clone().Pos)
+
+	err = d.Nested[0].Errorf("cannot use '@type %s'", "null")
+	assert.Equal(t, "line 4: cannot use '@type null'", err.Error())
+	// the first position is kept
+	assert.Equal(t, err, ErrorAt(d.Pos, err))
+	// the directives created by the processors have no position
+	assert.Equal(t, "no position", (&Directive{}).Errorf("no position").Error())
+}
+
 func TestParse1(t *testing.T) {
 	var s1 = `
 	# hello
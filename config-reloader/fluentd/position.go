@@ -0,0 +1,76 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package fluentd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Position is where a directive or a param starts in the parsed config. The directives
+// added by the processors have no position.
+type Position struct {
+	// File is empty for ParseString
+	File   string
+	Line   int
+	Column int
+}
+
+// IsValid tells whether the position is known
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	if p.File != "" {
+		return fmt.Sprintf("%s, line %d", p.File, p.Line)
+	}
+	return fmt.Sprintf("line %d", p.Line)
+}
+
+// PositionError is an error at a position of the config
+type PositionError struct {
+	Pos Position
+	Err error
+}
+
+func (e *PositionError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Err)
+}
+
+func (e *PositionError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorAt adds the position to the error, unless the position is unknown or the error
+// already has a position
+func ErrorAt(pos Position, err error) error {
+	if err == nil || !pos.IsValid() {
+		return err
+	}
+
+	var perr *PositionError
+	if errors.As(err, &perr) {
+		return err
+	}
+
+	return &PositionError{Pos: pos, Err: err}
+}
+
+// Errorf returns an error at the position of the directive
+func (d *Directive) Errorf(format string, args ...interface{}) error {
+	return ErrorAt(d.Pos, fmt.Errorf(format, args...))
+}
+
+// ParseErrors are all the syntax errors of a config, in the order of the lines
+type ParseErrors []*PositionError
+
+func (e ParseErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
@@ -5,11 +5,14 @@ package fluentd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/sirupsen/logrus"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/metrics"
 )
 
 type RPCMethod string
@@ -17,25 +20,50 @@ type RPCMethod string
 const (
 	gracefulReloadConf RPCMethod = "config.gracefulReload"
 	reloadConf         RPCMethod = "config.reload"
+	getDump            RPCMethod = "config.getDump"
 )
 
+// verifyInterval is how often the monitor_agent is polled after a reload
+const verifyInterval = 500 * time.Millisecond
+
+// pingInterval is how often the RPC endpoint is polled while waiting for fluentd to start
+const pingInterval = time.Second
+
+var pingClient = &http.Client{Timeout: 2 * time.Second}
+
 // Reloader sends a reload signal to fluentd
 type Reloader struct {
-	port int
+	port          int
+	monitorPort   int
+	verifyTimeout time.Duration
 }
 
-// NewReloader will notify on the given rpc port
-func NewReloader(ctx context.Context, port int) *Reloader {
+// NewReloader will notify on the given rpc port. The monitor_agent on monitorPort
+// is used to verify the generated config was loaded, 0 disables the verification
+func NewReloader(ctx context.Context, port int, monitorPort int, verifyTimeout time.Duration) *Reloader {
 	return &Reloader{
-		port: port,
+		port:          port,
+		monitorPort:   monitorPort,
+		verifyTimeout: verifyTimeout,
 	}
 }
 
+// MonitorAgentID is the @id of the monitor_agent of the generated config. It changes
+// with every generation so that a running fluentd tells which config it loaded.
+func MonitorAgentID(generation string) string {
+	return "config_reloader_" + generation
+}
+
+// CanVerify tells if VerifyConfiguration can detect a config fluentd did not load
+func (r *Reloader) CanVerify() bool {
+	return r != nil && r.monitorPort > 0
+}
+
 // ReloadConfiguration talks to fluentd's RPC endpoint. If r is nil does nothing
-func (r *Reloader) ReloadConfiguration() {
+func (r *Reloader) ReloadConfiguration() error {
 	if r == nil {
 		logrus.Infof("Not reloading fluentd (fake or filesystem datasource used)")
-		return
+		return nil
 	}
 
 	logrus.Infof("Reloading fluentd configuration via /api/%s", gracefulReloadConf)
@@ -44,21 +72,147 @@ func (r *Reloader) ReloadConfiguration() {
 		logrus.Infof("Reloading fluentd configuration via /api/%s", reloadConf)
 		if err := r.rpc(reloadConf); err != nil {
 			logrus.Error(err.Error())
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Ping tells if fluentd's RPC endpoint answers. If r is nil does nothing
+func (r *Reloader) Ping() error {
+	if r == nil {
+		return nil
+	}
+
+	resp, err := pingClient.Get(fmt.Sprintf("http://127.0.0.1:%d/api/%s", r.port, getDump))
+	if err != nil {
+		return fmt.Errorf("fluentd RPC endpoint is not reachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("fluentd %s endpoint returned statuscode %v", getDump, resp.StatusCode)
+	}
+	return nil
+}
+
+// WaitUntilReachable waits until fluentd's RPC endpoint answers, at most timeout.
+// If r is nil does nothing
+func (r *Reloader) WaitUntilReachable(ctx context.Context, timeout time.Duration) error {
+	if r == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		err := r.Ping()
+		if err == nil {
+			logrus.Infof("Fluentd RPC endpoint is reachable")
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("fluentd did not start within %s: %w", timeout, err)
+		case <-ticker.C:
+		}
+	}
+}
+
+// VerifyConfiguration waits until fluentd runs the generated config of the given generation.
+// It fails if the monitor_agent of the generation does not show up before the timeout,
+// for example because fluentd rejected the config. If r is nil does nothing
+func (r *Reloader) VerifyConfiguration(generation string) error {
+	if !r.CanVerify() {
+		return nil
+	}
+
+	id := MonitorAgentID(generation)
+	deadline := time.Now().Add(r.verifyTimeout)
+	// a fluentd that does not answer must not block past the deadline
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	var err error
+	for {
+		var loaded bool
+		loaded, err = r.hasPlugin(ctx, id)
+		if loaded {
+			logrus.Infof("Fluentd is running the generated config %s", generation)
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(verifyInterval)
+	}
+
+	if err != nil {
+		return fmt.Errorf("fluentd did not load the generated config within %s: %w", r.verifyTimeout, err)
+	}
+	return fmt.Errorf("fluentd did not load the generated config within %s", r.verifyTimeout)
+}
+
+// hasPlugin tells if the running fluentd has a plugin with the given @id
+func (r *Reloader) hasPlugin(ctx context.Context, id string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://127.0.0.1:%d/api/plugins.json", r.monitorPort), nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("fluentd monitor_agent request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return false, fmt.Errorf("fluentd monitor_agent returned statuscode %v", resp.StatusCode)
+	}
+
+	plugins := struct {
+		Plugins []struct {
+			PluginID string `json:"plugin_id"`
+		} `json:"plugins"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&plugins); err != nil {
+		return false, fmt.Errorf("cannot decode the fluentd monitor_agent response: %w", err)
+	}
+
+	for _, p := range plugins.Plugins {
+		if p.PluginID == id {
+			return true, nil
 		}
 	}
+	return false, nil
 }
 
 // rpc calls the given fluentd HTTP RPC endpoint
 // for more details see: https://docs.fluentd.org/deployment/rpc
-func (r *Reloader) rpc(method RPCMethod) error {
+func (r *Reloader) rpc(method RPCMethod) (err error) {
+	defer func() {
+		result := metrics.ResultSuccess
+		if err != nil {
+			result = metrics.ResultFailure
+		}
+		metrics.IncFluentdReloads(string(method), result)
+	}()
+
 	resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/api/%s", r.port, method))
 	if err != nil {
 		return fmt.Errorf("fluentd %s request failed: %w", method, err)
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
 		body, _ := io.ReadAll(resp.Body)
-		resp.Body.Close()
 		return fmt.Errorf("fluentd %s endpoint returned statuscode %v; response: %v", method, resp.StatusCode, string(body))
 	}
 
@@ -7,6 +7,9 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"testing"
 	"time"
 
@@ -16,7 +19,9 @@ import (
 func TestNullReloader(t *testing.T) {
 	var r *Reloader
 	r.ReloadConfiguration()
+	assert.Nil(t, r.VerifyConfiguration("abc"))
 }
+
 func TestReloaderCalls(t *testing.T) {
 	ctx := context.Background()
 	port := 24444
@@ -38,7 +43,7 @@ func TestReloaderCalls(t *testing.T) {
 	go server.ListenAndServe()
 	defer server.Close()
 
-	r := NewReloader(ctx, port)
+	r := NewReloader(ctx, port, 0, time.Second)
 
 	var err error
 	for i := 10; i >= 0; i-- {
@@ -58,3 +63,83 @@ func TestReloaderCalls(t *testing.T) {
 
 	assert.Equal(t, 3, counter)
 }
+
+func TestVerifyConfiguration(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/plugins.json" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"plugins":[{"plugin_id":"object:3fe","type":"tail"},{"plugin_id":"%s","type":"monitor_agent"}]}`, MonitorAgentID("abc"))
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	assert.Nil(t, err)
+	monitorPort, err := strconv.Atoi(u.Port())
+	assert.Nil(t, err)
+
+	r := NewReloader(context.Background(), 24444, monitorPort, 100*time.Millisecond)
+	assert.True(t, r.CanVerify())
+	assert.Nil(t, r.VerifyConfiguration("abc"))
+
+	// fluentd still runs the previous generation
+	err = r.VerifyConfiguration("def")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "fluentd did not load the generated config within 100ms")
+
+	// verification is disabled without a monitor port
+	r = NewReloader(context.Background(), 24444, 0, 100*time.Millisecond)
+	assert.False(t, r.CanVerify())
+	assert.Nil(t, r.VerifyConfiguration("def"))
+}
+
+func TestVerifyConfigurationHungFluentd(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	u, err := url.Parse(server.URL)
+	assert.Nil(t, err)
+	monitorPort, err := strconv.Atoi(u.Port())
+	assert.Nil(t, err)
+
+	// the request is given up at the deadline
+	r := NewReloader(context.Background(), 24444, monitorPort, 100*time.Millisecond)
+	start := time.Now()
+	err = r.VerifyConfiguration("abc")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "fluentd did not load the generated config within 100ms")
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestWaitUntilReachable(t *testing.T) {
+	var r *Reloader
+	assert.Nil(t, r.Ping())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/config.getDump" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"ok":true}`)
+	}))
+	u, err := url.Parse(server.URL)
+	assert.Nil(t, err)
+	port, err := strconv.Atoi(u.Port())
+	assert.Nil(t, err)
+
+	r = NewReloader(context.Background(), port, 0, time.Second)
+	assert.Nil(t, r.Ping())
+	assert.Nil(t, r.WaitUntilReachable(context.Background(), time.Second))
+
+	// fluentd is gone
+	server.Close()
+	assert.NotNil(t, r.Ping())
+	err = r.WaitUntilReachable(context.Background(), 100*time.Millisecond)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "fluentd did not start within 100ms")
+}
@@ -47,3 +47,13 @@ func (s *Stack) Push(value interface{}) {
 	s.top = n
 	s.length++
 }
+
+// Contains tells whether one of the values matches
+func (s *Stack) Contains(match func(interface{}) bool) bool {
+	for n := s.top; n != nil; n = n.prev {
+		if match(n.value) {
+			return true
+		}
+	}
+	return false
+}
@@ -12,6 +12,7 @@ import (
 	"time"
 	"unicode"
 
+	"github.com/vmware/kube-fluentd-operator/config-reloader/metrics"
 	"github.com/vmware/kube-fluentd-operator/config-reloader/util"
 
 	"github.com/sirupsen/logrus"
@@ -86,6 +87,7 @@ func (v *validatorState) ValidateConfigExtremely(config string, namespace string
 	logrus.Debugf("Checked config for namespace %s with fluentd and got: %s", namespace, out)
 	if err != nil {
 		logrus.Errorf("error running validation command for namespace %s: %s", namespace, err.Error())
+		countValidatorFailure(err)
 		return errors.New(out)
 	}
 
@@ -129,12 +131,21 @@ func (v *validatorState) ValidateConfig(config string, namespace string) error {
 	logrus.Debugf("Checked config for namespace %s with fluentd and got: %s", namespace, out)
 	if err != nil {
 		logrus.Errorf("error running command in namespace %s: %s", namespace, err.Error())
+		countValidatorFailure(err)
 		return errors.New(out)
 	}
 
 	return nil
 }
 
+func countValidatorFailure(err error) {
+	if errors.Is(err, util.ErrTimeout) {
+		metrics.IncValidatorFailures(metrics.ReasonTimeout)
+		return
+	}
+	metrics.IncValidatorFailures(metrics.ReasonError)
+}
+
 func (v *validatorState) EnsureUsable() error {
 	if v == nil {
 		return nil
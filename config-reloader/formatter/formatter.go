@@ -0,0 +1,127 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package formatter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/vmware/kube-fluentd-operator/config-reloader/config"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
+)
+
+// Run formats the files in cfg.FmtPaths like gofmt does: the result is printed to out,
+// or with cfg.FmtWrite the files are rewritten in place. With cfg.FmtList only the names
+// of the files whose layout differs are printed. Files that cannot be parsed are left
+// untouched and reported in the returned error.
+func Run(cfg *config.Config, out io.Writer) error {
+	files, err := expandPaths(cfg.FmtPaths)
+	if err != nil {
+		return err
+	}
+
+	failed := []string{}
+	for _, f := range files {
+		if err := formatFile(cfg, f, out); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("cannot format %d file(s):\n%s", len(failed), strings.Join(failed, "\n"))
+}
+
+// expandPaths replaces the dirs with the *.conf files they contain
+func expandPaths(paths []string) ([]string, error) {
+	res := []string{}
+
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			res = append(res, p)
+			continue
+		}
+
+		files, err := filepath.Glob(filepath.Join(p, "*.conf"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+		res = append(res, files...)
+	}
+
+	return res, nil
+}
+
+func formatFile(cfg *config.Config, filename string, out io.Writer) error {
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	formatted, err := Format(filename, string(contents))
+	if err != nil {
+		return err
+	}
+
+	changed := !bytes.Equal(contents, []byte(formatted))
+
+	if cfg.FmtList {
+		if changed {
+			_, err = fmt.Fprintln(out, filename)
+		}
+		return err
+	}
+
+	if cfg.FmtWrite {
+		if !changed {
+			return nil
+		}
+
+		info, err := os.Stat(filename)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filename, []byte(formatted), info.Mode().Perm())
+	}
+
+	_, err = io.WriteString(out, formatted)
+	return err
+}
+
+// Format normalizes the layout of a fluentd config. Go templates, the lines the parser
+// does not understand and the repeated params of a directive are rejected rather than
+// dropped from the output.
+func Format(filename string, contents string) (string, error) {
+	if strings.Contains(contents, "{{") {
+		return "", fmt.Errorf("%s: templated configs are not supported", filename)
+	}
+
+	doc, err := fluentd.ParseDocument(filename, contents)
+	if err != nil {
+		return "", err
+	}
+
+	if len(doc.Skipped) > 0 {
+		return "", &fluentd.PositionError{Pos: doc.Skipped[0], Err: fmt.Errorf("cannot format a line the parser does not understand")}
+	}
+
+	if len(doc.Duplicates) > 0 {
+		return "", &fluentd.PositionError{Pos: doc.Duplicates[0], Err: fmt.Errorf("cannot format a param repeated in its directive")}
+	}
+
+	return doc.Format(), nil
+}
@@ -0,0 +1,94 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package formatter
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/config"
+)
+
+const unformatted = `# catch-all
+<match **>
+    @type null
+</match>
+`
+
+const formatted = `# catch-all
+<match **>
+  @type null
+</match>
+`
+
+func writeConfigs(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, contents := range files {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o644))
+	}
+	return dir
+}
+
+func TestRunPrintsFormattedFiles(t *testing.T) {
+	dir := writeConfigs(t, map[string]string{
+		"demo.conf": unformatted,
+	})
+
+	out := &bytes.Buffer{}
+	err := Run(&config.Config{FmtPaths: []string{dir}}, out)
+	assert.Nil(t, err)
+	assert.Equal(t, formatted, out.String())
+
+	contents, _ := os.ReadFile(filepath.Join(dir, "demo.conf"))
+	assert.Equal(t, unformatted, string(contents))
+}
+
+func TestRunWritesAndLists(t *testing.T) {
+	dir := writeConfigs(t, map[string]string{
+		"demo.conf":  unformatted,
+		"other.conf": formatted,
+	})
+
+	out := &bytes.Buffer{}
+	err := Run(&config.Config{FmtPaths: []string{dir}, FmtList: true}, out)
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(dir, "demo.conf")+"\n", out.String())
+
+	out.Reset()
+	err = Run(&config.Config{FmtPaths: []string{dir}, FmtWrite: true}, out)
+	assert.Nil(t, err)
+	assert.Equal(t, "", out.String())
+
+	contents, _ := os.ReadFile(filepath.Join(dir, "demo.conf"))
+	assert.Equal(t, formatted, string(contents))
+}
+
+func TestRunReportsUnformattableFiles(t *testing.T) {
+	dir := writeConfigs(t, map[string]string{
+		"bad.conf":      "<match **>\n  @type null\n</filter>\n",
+		"templ.conf":    "{{ if true }}\n<match **>\n  @type null\n</match>\n{{ end }}\n",
+		"unclosed.conf": "<match **>\n  @type null\n  <buffer\n</match>\n",
+		"repeated.conf": "<match **>\n  @type forward\n  host a.example.com\n  host b.example.com\n</match>\n",
+		"demo.conf":     unformatted,
+	})
+
+	err := Run(&config.Config{FmtPaths: []string{dir}, FmtWrite: true}, &bytes.Buffer{})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "cannot format 4 file(s)")
+	assert.Contains(t, err.Error(), "bad.conf, line 3: mismatched tags: </filter> closes <match> of line 1")
+	assert.Contains(t, err.Error(), "templ.conf: templated configs are not supported")
+	assert.Contains(t, err.Error(), "unclosed.conf, line 3: cannot format a line the parser does not understand")
+	assert.Contains(t, err.Error(), "repeated.conf, line 4: cannot format a param repeated in its directive")
+
+	// the rejected files are left untouched
+	contents, _ := os.ReadFile(filepath.Join(dir, "repeated.conf"))
+	assert.Contains(t, string(contents), "host a.example.com")
+
+	// the valid files are still formatted
+	contents, _ = os.ReadFile(filepath.Join(dir, "demo.conf"))
+	assert.Equal(t, formatted, string(contents))
+}
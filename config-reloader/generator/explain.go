@@ -0,0 +1,108 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package generator
+
+import (
+	"net/http"
+	"path/filepath"
+	"sync"
+
+	"github.com/vmware/kube-fluentd-operator/config-reloader/config"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/processors"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/util"
+
+	"github.com/sirupsen/logrus"
+)
+
+const explainPath = "/debug/explain"
+
+// explainer keeps the last explanation of the namespaces selected with --explain-namespace.
+// A nil explainer explains nothing.
+type explainer struct {
+	namespaces map[string]bool
+	outputDir  string
+
+	lock sync.Mutex
+	// namespace -> phase -> explanation
+	explanations map[string]map[string]string
+}
+
+func newExplainer(cfg *config.Config) *explainer {
+	if len(cfg.ExplainNamespaces) == 0 {
+		return nil
+	}
+
+	e := &explainer{
+		namespaces:   map[string]bool{},
+		outputDir:    cfg.ExplainOutputDir,
+		explanations: map[string]map[string]string{},
+	}
+	for _, ns := range cfg.ExplainNamespaces {
+		e.namespaces[ns] = true
+	}
+
+	return e
+}
+
+// newTrace returns a trace if the namespace is explained, nil otherwise
+func (e *explainer) newTrace(namespace string) *processors.Trace {
+	if e == nil || !e.namespaces[namespace] {
+		return nil
+	}
+
+	return processors.NewTrace(namespace)
+}
+
+// record stores the explanation of a phase and emits the explanation of the namespace
+func (e *explainer) record(trace *processors.Trace) {
+	if e == nil || trace == nil || len(trace.Steps) == 0 {
+		return
+	}
+
+	e.lock.Lock()
+	byPhase := e.explanations[trace.Namespace]
+	if byPhase == nil {
+		byPhase = map[string]string{}
+		e.explanations[trace.Namespace] = byPhase
+	}
+	phase := trace.Steps[0].Phase
+	text := trace.Explain()
+	byPhase[phase] = text
+	e.lock.Unlock()
+
+	if e.outputDir == "" {
+		logrus.Infof("Explaining %s of namespace %s:\n%s", phase, trace.Namespace, text)
+		return
+	}
+
+	fname := filepath.Join(e.outputDir, trace.Namespace+".explain")
+	err := util.EnsureDirExists(e.outputDir)
+	if err == nil {
+		err = util.WriteStringToFile(fname, e.explanation(trace.Namespace))
+	}
+	if err != nil {
+		logrus.Warnf("Cannot write explanation for namespace %s: %+v", trace.Namespace, err)
+	}
+}
+
+// explanation returns the last prepare and process phases of the namespace
+func (e *explainer) explanation(namespace string) string {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	byPhase := e.explanations[namespace]
+	return byPhase[processors.PhasePrepare] + "\n" + byPhase[processors.PhaseProcess]
+}
+
+// ServeHTTP returns the explanation of the namespace given in the query
+func (e *explainer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ns := r.URL.Query().Get("namespace")
+	if !e.namespaces[ns] {
+		http.Error(w, "namespace is not explained, use --explain-namespace", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(e.explanation(ns)))
+}
@@ -5,11 +5,16 @@ package generator
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/vmware/kube-fluentd-operator/config-reloader/config"
@@ -17,6 +22,7 @@ import (
 	"github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
 	"github.com/vmware/kube-fluentd-operator/config-reloader/metrics"
 	"github.com/vmware/kube-fluentd-operator/config-reloader/processors"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/schema"
 	"github.com/vmware/kube-fluentd-operator/config-reloader/template"
 	"github.com/vmware/kube-fluentd-operator/config-reloader/util"
 
@@ -35,6 +41,11 @@ type Generator interface {
 	SetStatusUpdater(ctx context.Context, su datasource.StatusUpdater)
 	CleanupUnusedFiles(outputDir string, namespaces map[string]string)
 	RenderToDisk(ctx context.Context, outputDir string) (map[string]string, error)
+	// Generation identifies the config last written by RenderToDisk
+	Generation() string
+	SaveSnapshot(outputDir string) error
+	RestoreSnapshot(outputDir string) (string, error)
+	RejectConfigs(ctx context.Context, configHashes map[string]string, err error)
 }
 
 // Generator produces fluentd config files
@@ -44,12 +55,51 @@ type generatorInstance struct {
 	cfg          *config.Config
 	validator    fluentd.Validator
 	su           datasource.StatusUpdater
+	prepCache    map[string]*prepareResult
+	renderCache  map[string]*renderResult
+	explainer    *explainer
+	generation   string
+	snapshot     *snapshot
+	// namespace configs fluentd did not load, left out until they change
+	rejected map[string]*renderResult
+	// the last status reported for every ClusterFluentdConfig
+	clusterStatuses map[string]datasource.ConfigStatus
+	// where the secret files are written and the ones the last generation reads
+	secretsDir    string
+	secretFiles   map[string]bool
+	secretNameKey []byte
+	// schema checks the params of the plugins, nil without --plugin-schema-validation
+	schema *schema.Catalog
+}
+
+// prepareResult is the cached outcome of the prepare phase for a namespace
+type prepareResult struct {
+	inputHash       string
+	prepConfig      string
+	err             error
+	bridges         []string
+	needsProcessing bool
+}
+
+// renderResult is the cached outcome of processing and validating a namespace
+type renderResult struct {
+	namespace         string
+	inputHash         string
+	renderedConfig    string
+	validationTrailer string
+	configHash        string
+	err               error
+	needsValidation   bool
+	// number of top-level directives in renderedConfig
+	directives int
+	// secret files read by renderedConfig, by name
+	secretFiles map[string][]byte
 }
 
 var _ Generator = &generatorInstance{}
 
 // New creates a default implementation
-func New(ctx context.Context, cfg *config.Config) Generator {
+func New(ctx context.Context, cfg *config.Config) (Generator, error) {
 	templatesDir, _ := filepath.Abs(cfg.TemplatesDir)
 	var validator fluentd.Validator
 
@@ -57,11 +107,42 @@ func New(ctx context.Context, cfg *config.Config) Generator {
 		validator = fluentd.NewValidator(ctx, cfg.FluentdValidateCommand, time.Second*time.Duration(cfg.ExecTimeoutSeconds))
 	}
 
+	explainer := newExplainer(cfg)
+	if explainer != nil {
+		metrics.RegisterDebugHandler(explainPath, explainer)
+	}
+
+	var catalog *schema.Catalog
+	if cfg.PluginSchema {
+		var err error
+		catalog, err = schema.Load(cfg.PluginSchemaFile)
+		if err != nil {
+			logrus.Errorf("Plugin schema validation is disabled: %+v", err)
+		}
+	}
+
+	var secretNameKey []byte
+	if cfg.AllowSecrets {
+		var err error
+		secretNameKey, err = loadSecretNameKey(filepath.Join(cfg.OutputDir, secretsDirName))
+		if err != nil {
+			return nil, fmt.Errorf("cannot load the key of the secret file names: %w", err)
+		}
+	}
+
 	return &generatorInstance{
 		templatesDir: templatesDir,
 		cfg:          cfg,
 		validator:    validator,
-	}
+		schema:       catalog,
+		prepCache:    map[string]*prepareResult{},
+		renderCache:  map[string]*renderResult{},
+		explainer:    explainer,
+		rejected:     map[string]*renderResult{},
+
+		clusterStatuses: map[string]datasource.ConfigStatus{},
+		secretNameKey:   secretNameKey,
+	}, nil
 }
 
 // SetModel stores the model for later
@@ -74,24 +155,25 @@ func (g *generatorInstance) SetStatusUpdater(ctx context.Context, su datasource.
 	g.su = su
 }
 
-func (g *generatorInstance) makeNamespaceConfiguration(ns *datasource.NamespaceConfig, genCtx *processors.GenerationContext, mode int) (string, string, error) {
+func (g *generatorInstance) makeNamespaceConfiguration(ns *datasource.NamespaceConfig, ctx *processors.ProcessorContext, mode int) (string, string, error) {
 	// unconfigured namespace
 	if ns.FluentdConfig == "" {
 		return "", "", nil
 	}
 
-	fragment, err := fluentd.ParseString(ns.FluentdConfig)
+	fragment, err := fluentd.ParseWithIncludes("", ns.FluentdConfig, ns.IncludeFiles)
 	if err != nil {
 		logrus.Errorf("Error parsing config for namespace %s: %v", ns.Name, err)
-		return "", "", err
+		return "", "", &conditionError{condition: datasource.ConditionParsed, err: err}
 	}
 
-	ctx := g.makeContext(ns, genCtx)
+	ctx.Trace = g.explainer.newTrace(ns.Name)
+	defer g.explainer.record(ctx.Trace)
 
 	if mode == onlyPrepare {
 		prep, err := processors.Prepare(fragment, ctx, processors.DefaultProcessors()...)
 		if err != nil {
-			return "", "", err
+			return "", "", &conditionError{condition: datasource.ConditionProcessed, err: err}
 		}
 
 		return "", prep.String(), nil
@@ -100,14 +182,46 @@ func (g *generatorInstance) makeNamespaceConfiguration(ns *datasource.NamespaceC
 	if mode == onlyProcess {
 		fragment, err = processors.Process(fragment, ctx, processors.DefaultProcessors()...)
 		if err != nil {
-			return "", "", err
+			return "", "", &conditionError{condition: datasource.ConditionProcessed, err: err}
 		}
-		return fragment.String(), "", nil
+		return g.print(fragment), "", nil
 	}
 
 	return "", "", fmt.Errorf("bad mode: %d", mode)
 }
 
+// print renders a processed config, keeping the layout of the input with --preserve-formatting
+func (g *generatorInstance) print(fragment fluentd.Fragment) string {
+	if g.cfg.PreserveFormatting {
+		return fragment.Format()
+	}
+	return fragment.String()
+}
+
+// conditionError records which condition a namespace config failed to meet
+type conditionError struct {
+	condition string
+	err       error
+}
+
+func (e *conditionError) Error() string {
+	return e.err.Error()
+}
+
+func (e *conditionError) Unwrap() error {
+	return e.err
+}
+
+// failedCondition returns the condition the error refers to, by default the error
+// is assumed to have happened while processing
+func failedCondition(err error) string {
+	var ce *conditionError
+	if errors.As(err, &ce) {
+		return ce.condition
+	}
+	return datasource.ConditionProcessed
+}
+
 func extractPrepConfig(ns string, prepareConfigs map[string]interface{}) (string, error) {
 	what, ok := prepareConfigs[ns]
 
@@ -143,6 +257,8 @@ func (g *generatorInstance) renderMainFile(ctx context.Context, mainFile string,
 		FluentdLogLevel         string
 		BufferMountFolder       string
 		PreprocessingDirectives []string
+		MonitorPort             int
+		MonitorAgentID          string
 	}{}
 
 	if g.cfg.MetaKey != "" {
@@ -172,16 +288,18 @@ func (g *generatorInstance) renderMainFile(ctx context.Context, mainFile string,
 
 		model.AdminNamespace = true
 
-		fragment, err := fluentd.ParseString(nsConf.FluentdConfig)
+		fragment, err := fluentd.ParseWithIncludes("", nsConf.FluentdConfig, nsConf.IncludeFiles)
 		if err != nil {
 			logrus.Errorf("Error parsing config for namespace %s: %v", nsConf.Name, err)
 			return nil, err
 		}
 
+		fragment = append(fragment, g.mergeClusterConfigs(ctx, nsConf.ClusterConfigs)...)
 		fragment = processors.ExtractPlugins(genCtx, fragment)
+		fragment = processors.ExtractQuotas(genCtx, fragment)
 
 		// normalize system config
-		renderedConfig := fragment.String()
+		renderedConfig := g.print(fragment)
 		fileHashesByNs[nsConf.Name] = util.Hash("", renderedConfig)
 		// don't validate the admin namespace, just render it
 		err = util.WriteStringToFile(filepath.Join(outputDir, "admin-ns.conf"), renderedConfig)
@@ -192,85 +310,99 @@ func (g *generatorInstance) renderMainFile(ctx context.Context, mainFile string,
 		break
 	}
 
+	globalHash := g.hashGenerationContext(genCtx, fileHashesByNs[g.cfg.AdminNamespace])
+
+	results := map[string]*renderResult{}
+	pending := []*renderResult{}
 	for _, nsConf := range g.model {
 		if nsConf.Name == g.cfg.AdminNamespace {
 			continue
 		}
 
-		var renderedConfig, configHash string
+		res := g.renderNamespace(nsConf, genCtx, prepareConfigs, globalHash)
+		results[nsConf.Name] = res
+		if res.needsValidation {
+			pending = append(pending, res)
+		}
+	}
 
-		prepConfig, err := extractPrepConfig(nsConf.Name, prepareConfigs)
+	g.validateNamespaces(pending)
 
-		if err == nil {
-			// render config
-			renderedConfig, _, err = g.makeNamespaceConfiguration(nsConf, genCtx, onlyProcess)
-			configHash = util.Hash("", renderedConfig+prepConfig)
+	// merge the results in model order so that the output is deterministic
+	secretFiles := map[string]bool{}
+	for _, nsConf := range g.model {
+		if nsConf.Name == g.cfg.AdminNamespace {
+			continue
 		}
 
-		if err != nil {
-			configHash = util.Hash("ERROR", err.Error())
-			logrus.Infof("Configuration for namespace %s cannot be validated: %+v", nsConf.Name, err)
-			if nsConf.PreviousConfigHash != configHash {
-				g.updateStatus(ctx, nsConf.Name, err.Error())
+		prepConfig, _ := extractPrepConfig(nsConf.Name, prepareConfigs)
+		res := results[nsConf.Name]
+		if rejected, ok := g.rejected[nsConf.Name]; ok {
+			if res.err == nil && rejected.configHash == res.configHash {
+				res = rejected
+			} else {
+				delete(g.rejected, nsConf.Name)
 			}
-			fileHashesByNs[nsConf.Name] = configHash
-			continue
+		}
+		if res.err != nil {
+			metrics.SetNamespaceGeneratedDirectivesMetric(nsConf.Name, 0)
+		} else {
+			metrics.SetNamespaceGeneratedDirectivesMetric(nsConf.Name, res.directives)
 		}
 
-		// namespace is not configured
-		if renderedConfig == "" {
-			fileHashesByNs[nsConf.Name] = configHash
-			if nsConf.PreviousConfigHash != configHash {
-				// empty config is a valid input, clear error status
-				g.updateStatus(ctx, nsConf.Name, "")
-			}
-			// If a config file had been created, remove it
-			unusedFile := filepath.Join(outputDir, fmt.Sprintf("ns-%s.conf", nsConf.Name))
-			err := os.Remove(unusedFile)
-			if err != nil && !os.IsNotExist(err) {
-				logrus.Warnf("Error removing unused file %s: %+v", unusedFile, err)
+		if res.err != nil {
+			if nsConf.PreviousConfigHash != res.configHash {
+				// only update status if error caused by different input
+				g.updateStatus(ctx, nsConf.Name, res)
 			}
+			fileHashesByNs[nsConf.Name] = res.configHash
 			continue
 		}
 
-		var validationTrailer string
-
-		if g.validator != nil {
-			validationTrailer = g.makeValidationTrailer(nsConf, genCtx).String()
-			err = g.validator.ValidateConfigExtremely(renderedConfig+"\n# validation  trailer:\n"+validationTrailer, nsConf.Name)
-
-			if err != nil {
-				logrus.Infof("Configuration for namespace %s cannot be validated with fluentd validator", nsConf.Name)
-				if nsConf.PreviousConfigHash != configHash {
-					// only update status if error caused by different input
-					g.updateStatus(ctx, nsConf.Name, err.Error())
-				}
-				fileHashesByNs[nsConf.Name] = configHash
-				continue
+		// namespace is not configured
+		if res.renderedConfig == "" {
+			fileHashesByNs[nsConf.Name] = res.configHash
+			if nsConf.PreviousConfigHash != res.configHash {
+				// empty config is a valid input, clear error status
+				g.updateStatus(ctx, nsConf.Name, res)
 			}
+			// the file of a previous generation is unlinked when the output dir is published
+			continue
 		}
 
 		filename := fmt.Sprintf("ns-%s.conf", nsConf.Name)
 		newFiles = append(newFiles, filename)
+		for name := range res.secretFiles {
+			secretFiles[name] = true
+		}
 		model.PreprocessingDirectives = append(model.PreprocessingDirectives, prepConfig)
-		fileHashesByNs[nsConf.Name] = configHash
+		fileHashesByNs[nsConf.Name] = res.configHash
+		renderedConfig := res.renderedConfig
 		if g.cfg.FsDatasourceDir != "" {
 			// if the source is the filesystem, preserve the validation trailer
 			// so that generated files are valid in isolation
-			renderedConfig = renderedConfig + "\n# validation  trailer:\n" + validationTrailer
+			renderedConfig = renderedConfig + "\n# validation  trailer:\n" + res.validationTrailer
 		}
 		err = util.WriteStringToFile(filepath.Join(outputDir, filename), renderedConfig)
 		if err != nil {
 			logrus.Infof("Cannot store config file for namespace %s", nsConf.Name)
 		}
 
-		if nsConf.PreviousConfigHash != configHash {
+		if nsConf.PreviousConfigHash != res.configHash {
 			// clear error
-			g.updateStatus(ctx, nsConf.Name, "")
+			g.updateStatus(ctx, nsConf.Name, res)
 		}
 	}
 
+	g.pruneCaches()
+	g.secretFiles = secretFiles
+
 	model.Namespaces = newFiles
+	g.generation = hashGeneration(fileHashesByNs, newFiles)
+	if g.cfg.FluentdMonitorPort > 0 {
+		model.MonitorPort = g.cfg.FluentdMonitorPort
+		model.MonitorAgentID = fluentd.MonitorAgentID(g.generation)
+	}
 
 	err = util.TemplateAndWriteFile(tmpl, model, dest)
 	if err != nil {
@@ -280,6 +412,235 @@ func (g *generatorInstance) renderMainFile(ctx context.Context, mainFile string,
 	return fileHashesByNs, nil
 }
 
+// renderNamespace processes and validates the config of a single namespace. The result
+// is reused for as long as neither the namespace inputs nor the shared generation state change
+func (g *generatorInstance) renderNamespace(nsConf *datasource.NamespaceConfig, genCtx *processors.GenerationContext, prepareConfigs map[string]interface{}, globalHash string) *renderResult {
+	inputHash := util.Hash(globalHash, hashNamespaceInputs(nsConf))
+	if cached, ok := g.renderCache[nsConf.Name]; ok && cached.inputHash == inputHash {
+		logrus.Debugf("Reusing rendered config for namespace %s", nsConf.Name)
+		return cached
+	}
+
+	res := &renderResult{
+		namespace: nsConf.Name,
+		inputHash: inputHash,
+	}
+
+	prepConfig, err := extractPrepConfig(nsConf.Name, prepareConfigs)
+	if err == nil {
+		// render config
+		start := time.Now()
+		procCtx := g.makeContext(nsConf, genCtx)
+		res.renderedConfig, _, err = g.makeNamespaceConfiguration(nsConf, procCtx, onlyProcess)
+		metrics.ObserveNamespaceRenderDuration(nsConf.Name, time.Since(start))
+		res.configHash = util.Hash("", res.renderedConfig+prepConfig)
+		res.directives = countDirectives(res.renderedConfig)
+		res.secretFiles = procCtx.SecretFiles
+	}
+
+	if err == nil {
+		// fluentd reads the secrets when validating the config
+		err = g.writeSecretFiles(res.secretFiles)
+	}
+
+	if err != nil {
+		logrus.Infof("Configuration for namespace %s cannot be validated: %+v", nsConf.Name, err)
+		res.configHash = util.Hash("ERROR", err.Error())
+		res.err = err
+		g.renderCache[nsConf.Name] = res
+		return res
+	}
+
+	if res.renderedConfig != "" && g.validator != nil {
+		res.validationTrailer = g.makeValidationTrailer(nsConf, genCtx).String()
+		// validation is expensive, it is done for all namespaces at once
+		res.needsValidation = true
+		return res
+	}
+
+	g.renderCache[nsConf.Name] = res
+	return res
+}
+
+// validateNamespaces runs the fluentd validator on the given results using a bounded
+// pool of workers. Results are updated in place and cached once validated.
+func (g *generatorInstance) validateNamespaces(pending []*renderResult) {
+	if len(pending) == 0 {
+		return
+	}
+
+	workers := g.cfg.ValidationWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(pending) {
+		workers = len(pending)
+	}
+
+	jobs := make(chan *renderResult)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for res := range jobs {
+				start := time.Now()
+				err := g.validator.ValidateConfigExtremely(res.renderedConfig+"\n# validation  trailer:\n"+res.validationTrailer, res.namespace)
+				metrics.ObserveNamespaceValidationDuration(res.namespace, time.Since(start))
+				if err != nil {
+					logrus.Infof("Configuration for namespace %s cannot be validated with fluentd validator", res.namespace)
+					res.err = &conditionError{condition: datasource.ConditionValidated, err: err}
+				}
+			}
+		}()
+	}
+
+	for _, res := range pending {
+		jobs <- res
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, res := range pending {
+		res.needsValidation = false
+		if res.err != nil {
+			// validation failures are not cached: the validator may fail for
+			// transient reasons like timeouts so it is retried on every run
+			delete(g.renderCache, res.namespace)
+			continue
+		}
+		g.renderCache[res.namespace] = res
+	}
+}
+
+// pruneCaches forgets namespaces that are no longer part of the model
+func (g *generatorInstance) pruneCaches() {
+	current := map[string]bool{}
+	for _, nsConf := range g.model {
+		current[nsConf.Name] = true
+	}
+
+	for ns := range g.prepCache {
+		if !current[ns] {
+			delete(g.prepCache, ns)
+		}
+	}
+
+	for ns := range g.renderCache {
+		if !current[ns] {
+			delete(g.renderCache, ns)
+		}
+	}
+
+	for ns := range g.rejected {
+		if !current[ns] {
+			delete(g.rejected, ns)
+		}
+	}
+}
+
+// hashGenerationContext summarizes the state shared by all namespaces, any change
+// to it invalidates all rendered namespaces
+func (g *generatorInstance) hashGenerationContext(genCtx *processors.GenerationContext, adminHash string) string {
+	bridges := make([]string, 0, len(genCtx.ReferencedBridges))
+	for b := range genCtx.ReferencedBridges {
+		bridges = append(bridges, b)
+	}
+	sort.Strings(bridges)
+
+	// the admin hash covers neither the plugins nor the quotas, they are extracted first
+	shared := &strings.Builder{}
+	for _, directives := range []map[string]*fluentd.Directive{genCtx.Plugins, genCtx.Quotas} {
+		keys := make([]string, 0, len(directives))
+		for k := range directives {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(shared, "%s=%s\n", k, directives[k].String())
+		}
+	}
+
+	return util.Hash(adminHash, fmt.Sprintf("%v:%v:%s", genCtx.NeedsProcessing, bridges, shared.String()))
+}
+
+// countDirectives counts the top-level directives of a config rendered by Fragment.String()
+// or Fragment.Format()
+func countDirectives(config string) int {
+	count := 0
+	for _, line := range strings.Split(config, "\n") {
+		if strings.HasPrefix(line, "<") && !strings.HasPrefix(line, "</") {
+			count++
+		}
+	}
+
+	return count
+}
+
+// hashGeneration identifies the generated files by the configs of all namespaces
+// and the namespace files included in the main file
+func hashGeneration(fileHashesByNs map[string]string, files []string) string {
+	buf := &strings.Builder{}
+	for _, ns := range util.SortedKeys(fileHashesByNs) {
+		fmt.Fprintf(buf, "%s=%s\n", ns, fileHashesByNs[ns])
+	}
+	for _, f := range files {
+		fmt.Fprintf(buf, "%s\n", f)
+	}
+
+	return util.Hash("", buf.String())
+}
+
+// hashNamespaceInputs produces a digest of everything the processors read about a namespace
+func hashNamespaceInputs(nsConf *datasource.NamespaceConfig) string {
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "%s\n%s\n", nsConf.Name, util.ToRubyMapLiteral(nsConf.Labels))
+
+	containers := make([]string, 0, len(nsConf.MiniContainers))
+	for _, mc := range nsConf.MiniContainers {
+		mounts := make([]string, 0, len(mc.HostMounts))
+		for _, hm := range mc.HostMounts {
+			mounts = append(mounts, fmt.Sprintf("%s|%s|%s", hm.Path, hm.VolumeName, hm.SubPath))
+		}
+		containers = append(containers, fmt.Sprintf("%s|%s|%s|%s|%s|%s|%s|%s|%s/%s|%v",
+			mc.PodID, mc.PodName, mc.Name, mc.Image, mc.ContainerID, mc.NodeName, util.ToRubyMapLiteral(mc.Labels),
+			util.ToRubyMapLiteral(mc.Annotations), mc.OwnerKind, mc.OwnerName, mounts))
+	}
+	// the informer does not guarantee the order of pods
+	sort.Strings(containers)
+
+	for _, c := range containers {
+		buf.WriteString(c)
+		buf.WriteString("\n")
+	}
+	for _, p := range nsConf.Policies {
+		spec, _ := json.Marshal(p.Spec)
+		fmt.Fprintf(buf, "%s|%s\n", p.Name, spec)
+	}
+	secretNames := make([]string, 0, len(nsConf.Secrets))
+	for name := range nsConf.Secrets {
+		secretNames = append(secretNames, name)
+	}
+	sort.Strings(secretNames)
+	for _, name := range secretNames {
+		data := nsConf.Secrets[name]
+		keys := make([]string, 0, len(data))
+		for k := range data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(buf, "%s|%s|%s\n", name, k, util.Hash("", string(data[k])))
+		}
+	}
+	for _, name := range util.SortedKeys(nsConf.IncludeFiles) {
+		fmt.Fprintf(buf, "%s|%s\n", name, util.Hash("", nsConf.IncludeFiles[name]))
+	}
+	buf.WriteString(nsConf.FluentdConfig)
+
+	return util.Hash("", buf.String())
+}
+
 func (g *generatorInstance) generatePrepareConfigs(genCtx *processors.GenerationContext) map[string]interface{} {
 	prepareConfigs := map[string]interface{}{}
 	for _, nsConf := range g.model {
@@ -287,18 +648,52 @@ func (g *generatorInstance) generatePrepareConfigs(genCtx *processors.Generation
 			continue
 		}
 
-		_, prep, err := g.makeNamespaceConfiguration(nsConf, genCtx, onlyPrepare)
-		if err != nil {
-			prepareConfigs[nsConf.Name] = err
+		inputHash := hashNamespaceInputs(nsConf)
+		prep, ok := g.prepCache[nsConf.Name]
+		if !ok || prep.inputHash != inputHash {
+			prep = g.prepareNamespace(nsConf, inputHash)
+			g.prepCache[nsConf.Name] = prep
+		}
+
+		// merge the shared state the namespace contributed
+		for _, b := range prep.bridges {
+			genCtx.ReferencedBridges[b] = true
+		}
+		if prep.needsProcessing {
+			genCtx.NeedsProcessing = true
+		}
+
+		if prep.err != nil {
+			prepareConfigs[nsConf.Name] = prep.err
 		} else {
-			prepareConfigs[nsConf.Name] = prep
+			prepareConfigs[nsConf.Name] = prep.prepConfig
 		}
 	}
 	return prepareConfigs
 }
 
+// prepareNamespace runs the prepare phase in isolation so that the contribution
+// of the namespace to the shared GenerationContext can be cached along with it
+func (g *generatorInstance) prepareNamespace(nsConf *datasource.NamespaceConfig, inputHash string) *prepareResult {
+	nsGenCtx := &processors.GenerationContext{
+		ReferencedBridges: map[string]bool{},
+	}
+
+	res := &prepareResult{
+		inputHash: inputHash,
+	}
+
+	_, res.prepConfig, res.err = g.makeNamespaceConfiguration(nsConf, g.makeContext(nsConf, nsGenCtx), onlyPrepare)
+	for b := range nsGenCtx.ReferencedBridges {
+		res.bridges = append(res.bridges, b)
+	}
+	res.needsProcessing = nsGenCtx.NeedsProcessing
+
+	return res
+}
+
 func (g *generatorInstance) makeValidationTrailer(ns *datasource.NamespaceConfig, genCtx *processors.GenerationContext) fluentd.Fragment {
-	fragment, err := fluentd.ParseString(ns.FluentdConfig)
+	fragment, err := fluentd.ParseWithIncludes("", ns.FluentdConfig, ns.IncludeFiles)
 	if err != nil {
 		logrus.Errorf("Error parsing config for namespace %s: %v", ns.Name, err)
 		return nil
@@ -320,13 +715,82 @@ func (g *generatorInstance) makeContext(ns *datasource.NamespaceConfig, genCtx *
 		BufferMountFolder: g.cfg.BufferMountFolder,
 		GenerationContext: genCtx,
 		AllowTagExpansion: g.cfg.AllowTagExpansion,
+		Policies:          ns.Policies,
+		AllowSecrets:      g.cfg.AllowSecrets,
+		AllowEmbeddedRuby: g.cfg.AllowEmbeddedRuby,
+		RecordAnnotations: g.cfg.RecordAnnotations,
+		Secrets:           ns.Secrets,
+		SecretsDir:        g.secretsDir,
+		SecretNameKey:     g.secretNameKey,
+		Schema:            g.schema,
 	}
 	return ctx
 }
 
-func (g *generatorInstance) updateStatus(ctx context.Context, namespace string, status string) {
-	metrics.SetNamespaceConfigStatusMetric(namespace, status == "")
-	g.su.UpdateStatus(ctx, namespace, status)
+func (g *generatorInstance) updateStatus(ctx context.Context, namespace string, res *renderResult) {
+	status := &datasource.ConfigStatus{
+		ConfigHash: res.configHash,
+	}
+	if res.err != nil {
+		status.FailedCondition = failedCondition(res.err)
+		status.Message = res.err.Error()
+	}
+
+	metrics.SetNamespaceConfigStatusMetric(namespace, status.Message == "")
+	if csu, ok := g.su.(datasource.ConfigStatusUpdater); ok {
+		csu.UpdateConfigStatus(ctx, namespace, status)
+		return
+	}
+	g.su.UpdateStatus(ctx, namespace, status.Message)
+}
+
+// mergeClusterConfigs parses the ClusterFluentdConfigs in order. A config that cannot be
+// parsed is left out so that it does not break the others.
+func (g *generatorInstance) mergeClusterConfigs(ctx context.Context, clusterConfigs []*datasource.ClusterConfig) fluentd.Fragment {
+	res := fluentd.Fragment{}
+	current := map[string]bool{}
+	for _, cc := range clusterConfigs {
+		current[cc.Name] = true
+		status := datasource.ConfigStatus{
+			ConfigHash: util.Hash("", cc.FluentdConfig),
+		}
+
+		// a config whose template failed is only partially rendered, none of it is merged
+		var fragment fluentd.Fragment
+		err := cc.RenderError
+		if err == nil {
+			fragment, err = fluentd.ParseString(cc.FluentdConfig)
+		}
+		if err != nil {
+			logrus.Errorf("Error parsing clusterfluentdconfig %s: %v", cc.Name, err)
+			status.FailedCondition = datasource.ConditionParsed
+			status.Message = err.Error()
+		} else {
+			res = append(res, fragment...)
+		}
+
+		g.updateClusterStatus(ctx, cc.Name, status)
+	}
+
+	for name := range g.clusterStatuses {
+		if !current[name] {
+			delete(g.clusterStatuses, name)
+		}
+	}
+
+	return res
+}
+
+// updateClusterStatus reports the status of a ClusterFluentdConfig when it changes
+func (g *generatorInstance) updateClusterStatus(ctx context.Context, name string, status datasource.ConfigStatus) {
+	if previous, ok := g.clusterStatuses[name]; ok && previous == status {
+		return
+	}
+	g.clusterStatuses[name] = status
+
+	if csu, ok := g.su.(datasource.ClusterConfigStatusUpdater); ok {
+		csu.UpdateClusterConfigStatus(ctx, name, &status)
+	}
 }
 
 func (g *generatorInstance) renderIncludableFile(templateFile string, dest string) (err error) {
@@ -341,10 +805,16 @@ func (g *generatorInstance) renderIncludableFile(templateFile string, dest strin
 		ID                string
 		PrometheusEnabled bool
 		ReadBytesLimit    int
+		AnnotationMatch   string
+		PodOwner          string
 	}{
 		ID:                util.MakeFluentdSafeName(g.cfg.ID),
 		PrometheusEnabled: g.cfg.PrometheusEnabled,
 		ReadBytesLimit:    g.cfg.ReadBytesLimit,
+		AnnotationMatch:   annotationMatch(g.cfg.RecordAnnotations),
+	}
+	if g.usesOwnerLabels() {
+		model.PodOwner = util.RubyPodOwner
 	}
 
 	err = util.TemplateAndWriteFile(tmpl, model, dest)
@@ -354,6 +824,48 @@ func (g *generatorInstance) renderIncludableFile(templateFile string, dest strin
 	return nil
 }
 
+// usesOwnerLabels tells if a config may select on _owner_kind or _owner_name, the records
+// only get the owner of their pod then. A mention in a comment is enough.
+func (g *generatorInstance) usesOwnerLabels() bool {
+	uses := func(conf string) bool {
+		return strings.Contains(conf, util.OwnerKindLabel) || strings.Contains(conf, util.OwnerNameLabel)
+	}
+
+	for _, nsConf := range g.model {
+		if uses(nsConf.FluentdConfig) {
+			return true
+		}
+		for _, include := range nsConf.IncludeFiles {
+			if uses(include) {
+				return true
+			}
+		}
+		for _, cc := range nsConf.ClusterConfigs {
+			if uses(cc.FluentdConfig) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// annotationMatch is the annotation_match of the kubernetes_metadata filter that adds exactly
+// the annotations to the records
+func annotationMatch(annotations []string) string {
+	if len(annotations) == 0 {
+		return ""
+	}
+
+	patterns := make([]string, 0, len(annotations))
+	for _, a := range annotations {
+		patterns = append(patterns, "^"+regexp.QuoteMeta(a)+"$")
+	}
+	res, _ := json.Marshal(patterns)
+
+	return string(res)
+}
+
 // CleanupUnusedFiles removes "ns-*.conf" files of namespaces that are no more existent
 func (g *generatorInstance) CleanupUnusedFiles(outputDir string, namespaces map[string]string) {
 	files, err := filepath.Glob(fmt.Sprintf("%s/ns-*.conf", outputDir))
@@ -368,12 +880,13 @@ func (g *generatorInstance) CleanupUnusedFiles(outputDir string, namespaces map[
 			if err := os.Remove(f); err != nil {
 				logrus.Warnf("Error removing unused file %s: %+v", f, err)
 			}
-			metrics.DeleteNamespaceConfigStatusMetric(ns)
+			metrics.DeleteNamespaceMetrics(ns)
 		}
 	}
 }
 
-// RenderToDisk write only valid configurations to disk
+// RenderToDisk write only valid configurations to disk. The files are rendered
+// into a staging dir which then replaces the content of outputDir at once
 func (g *generatorInstance) RenderToDisk(ctx context.Context, outputDir string) (map[string]string, error) {
 	err := util.EnsureDirExists(outputDir)
 	if err != nil {
@@ -381,30 +894,46 @@ func (g *generatorInstance) RenderToDisk(ctx context.Context, outputDir string)
 	}
 	outputDir, _ = filepath.Abs(outputDir)
 	res := map[string]string{}
+	g.secretsDir = filepath.Join(outputDir, secretsDirName)
 
 	files, err := filepath.Glob(fmt.Sprintf("%s/*.conf", g.templatesDir))
 	if err != nil {
 		return nil, err
 	}
 
+	stagingDir, err := newStagingDir(outputDir)
+	if err != nil {
+		return nil, err
+	}
+
 	for _, f := range files {
 		base := filepath.Base(f)
-		targetDest := path.Join(outputDir, base)
+		targetDest := path.Join(stagingDir, base)
 
 		if base != mainConfigFile {
 			err = g.renderIncludableFile(f, targetDest)
 			if err != nil {
 				logrus.Warnf("Cannot write auxiliar file %s: %+v", f, err)
+				os.RemoveAll(stagingDir)
 				return nil, err
 			}
 		} else {
-			res, err = g.renderMainFile(ctx, f, outputDir, targetDest)
+			res, err = g.renderMainFile(ctx, f, stagingDir, targetDest)
 			if err != nil {
 				logrus.Warnf("Cannot write main file %s: %+v", f, err)
+				os.RemoveAll(stagingDir)
 				return nil, err
 			}
 		}
 	}
 
+	if err := publishDir(outputDir, stagingDir); err != nil {
+		logrus.Warnf("Cannot publish the generated files to %s: %+v", outputDir, err)
+		os.RemoveAll(stagingDir)
+		return nil, err
+	}
+
+	g.pruneSecretFiles()
+
 	return res, nil
 }
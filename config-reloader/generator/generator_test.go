@@ -0,0 +1,660 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/config"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/datasource"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/util"
+)
+
+type countingValidator struct {
+	lock  sync.Mutex
+	calls map[string]int
+}
+
+func (v *countingValidator) ValidateConfig(config string, namespace string) error {
+	return nil
+}
+
+func (v *countingValidator) ValidateConfigExtremely(config string, namespace string) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	v.calls[namespace]++
+	if strings.Contains(config, "invalid") {
+		return fmt.Errorf("bad config for %s", namespace)
+	}
+	return nil
+}
+
+func (v *countingValidator) EnsureUsable() error {
+	return nil
+}
+
+type nullStatusUpdater struct{}
+
+func (n *nullStatusUpdater) UpdateStatus(ctx context.Context, namespace string, status string) {}
+
+func makeTestGenerator(t *testing.T) (*generatorInstance, *countingValidator, string) {
+	outputDir, err := os.MkdirTemp("", "generator-test")
+	assert.Nil(t, err)
+
+	cfg := &config.Config{
+		TemplatesDir:   "../templates",
+		ID:             "default",
+		AdminNamespace: "kube-system",
+	}
+
+	ctx := context.Background()
+	g, err := New(ctx, cfg)
+	assert.Nil(t, err)
+	gen := g.(*generatorInstance)
+	validator := &countingValidator{calls: map[string]int{}}
+	gen.validator = validator
+	gen.SetStatusUpdater(ctx, &nullStatusUpdater{})
+
+	return gen, validator, outputDir
+}
+
+func TestRenderToDiskReusesUnchangedNamespaces(t *testing.T) {
+	gen, validator, outputDir := makeTestGenerator(t)
+	defer os.RemoveAll(outputDir)
+	ctx := context.Background()
+
+	model := []*datasource.NamespaceConfig{
+		{
+			Name:          "ns-a",
+			FluentdConfig: "<match **>\n  @type null\n</match>",
+		},
+		{
+			Name:          "ns-b",
+			FluentdConfig: "<match **>\n  @type null\n</match>",
+		},
+	}
+
+	gen.SetModel(model)
+	first, err := gen.RenderToDisk(ctx, outputDir)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"ns-a": 1, "ns-b": 1}, validator.calls)
+
+	gen.SetModel(model)
+	second, err := gen.RenderToDisk(ctx, outputDir)
+	assert.Nil(t, err)
+	assert.Equal(t, first, second)
+	assert.Equal(t, map[string]int{"ns-a": 1, "ns-b": 1}, validator.calls)
+
+	model[1] = &datasource.NamespaceConfig{
+		Name:          "ns-b",
+		FluentdConfig: "<match **>\n  @type null\n</match>",
+		Labels:        map[string]string{"team": "b"},
+	}
+	gen.SetModel(model)
+	_, err = gen.RenderToDisk(ctx, outputDir)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"ns-a": 1, "ns-b": 2}, validator.calls)
+
+	_, err = os.Stat(outputDir + "/ns-ns-a.conf")
+	assert.Nil(t, err)
+}
+
+func TestRenderToDiskInvalidatesOnSharedState(t *testing.T) {
+	gen, validator, outputDir := makeTestGenerator(t)
+	defer os.RemoveAll(outputDir)
+	ctx := context.Background()
+
+	sender := &datasource.NamespaceConfig{
+		Name: "ns-a",
+		FluentdConfig: `
+<match **>
+  @type copy
+  <store>
+    @type share
+    with_namespace ns-b
+  </store>
+</match>`,
+	}
+
+	gen.SetModel([]*datasource.NamespaceConfig{sender})
+	_, err := gen.RenderToDisk(ctx, outputDir)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, validator.calls["ns-a"])
+
+	receiver := &datasource.NamespaceConfig{
+		Name: "ns-b",
+		FluentdConfig: `
+<label @$from(ns-a)>
+  <match **>
+    @type null
+  </match>
+</label>`,
+	}
+
+	// a new bridge is referenced so the sender must be regenerated
+	gen.SetModel([]*datasource.NamespaceConfig{sender, receiver})
+	_, err = gen.RenderToDisk(ctx, outputDir)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, validator.calls["ns-a"])
+	assert.Equal(t, 1, validator.calls["ns-b"])
+
+	gen.SetModel([]*datasource.NamespaceConfig{receiver})
+	_, err = gen.RenderToDisk(ctx, outputDir)
+	assert.Nil(t, err)
+	assert.Nil(t, gen.renderCache["ns-a"])
+}
+
+func TestRenderToDiskInvalidatesOnAdminPlugins(t *testing.T) {
+	gen, validator, outputDir := makeTestGenerator(t)
+	defer os.RemoveAll(outputDir)
+	ctx := context.Background()
+
+	tenant := &datasource.NamespaceConfig{
+		Name:          "ns-a",
+		FluentdConfig: "<match **>\n  @type central\n</match>",
+	}
+	admin := &datasource.NamespaceConfig{
+		Name:          "kube-system",
+		FluentdConfig: "<plugin central>\n  @type null\n</plugin>",
+	}
+
+	gen.SetModel([]*datasource.NamespaceConfig{admin, tenant})
+	_, err := gen.RenderToDisk(ctx, outputDir)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, validator.calls["ns-a"])
+
+	// only the <plugin> changes, the rest of the admin config is the same
+	admin = &datasource.NamespaceConfig{
+		Name:          "kube-system",
+		FluentdConfig: "<plugin central>\n  @type elasticsearch\n  host es.logging.svc\n</plugin>",
+	}
+	gen.SetModel([]*datasource.NamespaceConfig{admin, tenant})
+	_, err = gen.RenderToDisk(ctx, outputDir)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, validator.calls["ns-a"])
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "ns-ns-a.conf"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), "@type elasticsearch")
+}
+
+type recordingStatusUpdater struct {
+	statuses map[string]string
+}
+
+func (r *recordingStatusUpdater) UpdateStatus(ctx context.Context, namespace string, status string) {
+	r.statuses[namespace] = status
+}
+
+func TestRenderToDiskValidatesInParallel(t *testing.T) {
+	gen, validator, outputDir := makeTestGenerator(t)
+	defer os.RemoveAll(outputDir)
+	ctx := context.Background()
+
+	gen.cfg.ValidationWorkers = 4
+	su := &recordingStatusUpdater{statuses: map[string]string{}}
+	gen.SetStatusUpdater(ctx, su)
+
+	model := []*datasource.NamespaceConfig{}
+	for i := 0; i < 10; i++ {
+		tag := "valid"
+		if i%3 == 0 {
+			tag = "invalid"
+		}
+		model = append(model, &datasource.NamespaceConfig{
+			Name:          fmt.Sprintf("ns-%d", i),
+			FluentdConfig: fmt.Sprintf("<match **>\n  @type null\n  @id %s\n</match>", tag),
+		})
+	}
+
+	gen.SetModel(model)
+	hashes, err := gen.RenderToDisk(ctx, outputDir)
+	assert.Nil(t, err)
+	assert.Len(t, hashes, 10)
+
+	for i, ns := range model {
+		assert.Equal(t, 1, validator.calls[ns.Name])
+		_, statErr := os.Stat(fmt.Sprintf("%s/ns-%s.conf", outputDir, ns.Name))
+		if i%3 == 0 {
+			assert.Equal(t, "bad config for "+ns.Name, su.statuses[ns.Name])
+			assert.True(t, os.IsNotExist(statErr))
+		} else {
+			assert.Equal(t, "", su.statuses[ns.Name])
+			assert.Nil(t, statErr)
+		}
+	}
+
+	main, err := os.ReadFile(outputDir + "/fluent.conf")
+	assert.Nil(t, err)
+	assert.True(t, strings.Index(string(main), "ns-ns-1.conf") < strings.Index(string(main), "ns-ns-8.conf"))
+}
+
+type recordingConfigStatusUpdater struct {
+	nullStatusUpdater
+	statuses map[string]*datasource.ConfigStatus
+}
+
+func (r *recordingConfigStatusUpdater) UpdateConfigStatus(ctx context.Context, namespace string, status *datasource.ConfigStatus) {
+	r.statuses[namespace] = status
+}
+
+func TestRenderToDiskReportsFailedCondition(t *testing.T) {
+	gen, _, outputDir := makeTestGenerator(t)
+	defer os.RemoveAll(outputDir)
+	ctx := context.Background()
+
+	su := &recordingConfigStatusUpdater{statuses: map[string]*datasource.ConfigStatus{}}
+	gen.SetStatusUpdater(ctx, su)
+
+	gen.SetModel([]*datasource.NamespaceConfig{
+		{
+			Name:          "bad-syntax",
+			FluentdConfig: "<match **>\n  @type null\n</filter>",
+		},
+		{
+			Name:          "bad-source",
+			FluentdConfig: "<source>\n  @type tail\n</source>",
+		},
+		{
+			Name:          "bad-validation",
+			FluentdConfig: "<match **>\n  @type null\n  @id invalid\n</match>",
+		},
+		{
+			Name:          "good",
+			FluentdConfig: "<match **>\n  @type null\n</match>",
+		},
+	})
+
+	hashes, err := gen.RenderToDisk(ctx, outputDir)
+	assert.Nil(t, err)
+
+	assert.Equal(t, datasource.ConditionParsed, su.statuses["bad-syntax"].FailedCondition)
+	assert.Contains(t, su.statuses["bad-syntax"].Message, "line 3: mismatched tags")
+	assert.Equal(t, datasource.ConditionProcessed, su.statuses["bad-source"].FailedCondition)
+	assert.Equal(t, "line 1: cannot use <source> directive", su.statuses["bad-source"].Message)
+	assert.Equal(t, datasource.ConditionValidated, su.statuses["bad-validation"].FailedCondition)
+	assert.Equal(t, "", su.statuses["good"].FailedCondition)
+	assert.Equal(t, hashes["good"], su.statuses["good"].ConfigHash)
+}
+
+func TestRenderToDiskExplainsNamespace(t *testing.T) {
+	gen, _, outputDir := makeTestGenerator(t)
+	defer os.RemoveAll(outputDir)
+	ctx := context.Background()
+
+	explainDir := filepath.Join(outputDir, "explain")
+	gen.explainer = newExplainer(&config.Config{
+		ExplainNamespaces: []string{"ns-a"},
+		ExplainOutputDir:  explainDir,
+	})
+
+	gen.SetModel([]*datasource.NamespaceConfig{
+		{
+			Name:          "ns-a",
+			FluentdConfig: "<match **>\n  @type null\n</match>",
+		},
+		{
+			Name:          "ns-b",
+			FluentdConfig: "<match **>\n  @type null\n</match>",
+		},
+	})
+	_, err := gen.RenderToDisk(ctx, outputDir)
+	assert.Nil(t, err)
+
+	explanation, err := os.ReadFile(filepath.Join(explainDir, "ns-a.explain"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(explanation), "## prepare: input\n")
+	assert.Contains(t, string(explanation), "-<match **>\n+<match kube.ns-a.**>\n")
+	assert.Equal(t, string(explanation), gen.explainer.explanation("ns-a"))
+
+	_, err = os.Stat(filepath.Join(explainDir, "ns-b.explain"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRenderToDiskPublishesGenerations(t *testing.T) {
+	gen, _, outputDir := makeTestGenerator(t)
+	defer os.RemoveAll(outputDir)
+	ctx := context.Background()
+
+	// written before the output dir was published through ..data
+	assert.Nil(t, os.WriteFile(filepath.Join(outputDir, "fluent.conf"), []byte("# stale"), 0o644))
+
+	gen.SetModel([]*datasource.NamespaceConfig{
+		{
+			Name:          "ns-a",
+			FluentdConfig: "<match **>\n  @type null\n</match>",
+		},
+		{
+			Name:          "ns-b",
+			FluentdConfig: "<match **>\n  @type null\n</match>",
+		},
+	})
+	_, err := gen.RenderToDisk(ctx, outputDir)
+	assert.Nil(t, err)
+
+	target, err := os.Readlink(filepath.Join(outputDir, "fluent.conf"))
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join("..data", "fluent.conf"), target)
+	main, err := os.ReadFile(filepath.Join(outputDir, "fluent.conf"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(main), "@include ns-ns-b.conf")
+	first, err := os.Readlink(filepath.Join(outputDir, "..data"))
+	assert.Nil(t, err)
+
+	gen.SetModel([]*datasource.NamespaceConfig{
+		{
+			Name:          "ns-a",
+			FluentdConfig: "<match **>\n  @type null\n</match>",
+		},
+	})
+	_, err = gen.RenderToDisk(ctx, outputDir)
+	assert.Nil(t, err)
+
+	second, err := os.Readlink(filepath.Join(outputDir, "..data"))
+	assert.Nil(t, err)
+	assert.NotEqual(t, first, second)
+
+	// the previous generation and the files it alone had are gone
+	_, err = os.Stat(filepath.Join(outputDir, first))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Lstat(filepath.Join(outputDir, "ns-ns-b.conf"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(outputDir, "ns-ns-a.conf"))
+	assert.Nil(t, err)
+
+	generations, err := filepath.Glob(filepath.Join(outputDir, "..gen-*"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(generations))
+}
+
+type recordingClusterStatusUpdater struct {
+	nullStatusUpdater
+	statuses map[string][]*datasource.ConfigStatus
+}
+
+func (r *recordingClusterStatusUpdater) UpdateClusterConfigStatus(ctx context.Context, name string, status *datasource.ConfigStatus) {
+	r.statuses[name] = append(r.statuses[name], status)
+}
+
+func TestRenderToDiskMergesClusterConfigs(t *testing.T) {
+	gen, _, outputDir := makeTestGenerator(t)
+	defer os.RemoveAll(outputDir)
+	ctx := context.Background()
+
+	su := &recordingClusterStatusUpdater{statuses: map[string][]*datasource.ConfigStatus{}}
+	gen.SetStatusUpdater(ctx, su)
+
+	model := []*datasource.NamespaceConfig{
+		{
+			Name:          "kube-system",
+			FluentdConfig: "<match systemd.**>\n  @type null\n</match>",
+			ClusterConfigs: []*datasource.ClusterConfig{
+				{
+					Name:          "outputs",
+					FluentdConfig: "<plugin central>\n  @type elasticsearch\n</plugin>",
+				},
+				{
+					Name:          "broken",
+					FluentdConfig: "<match **>\n  @type null\n</filter>",
+				},
+				{
+					Name:          "audit",
+					FluentdConfig: "<match kube.audit.**>\n  @type null\n</match>",
+				},
+				{
+					Name:          "templated",
+					FluentdConfig: "<match kube.partial.**>\n  @type null\n</match>",
+					RenderError:   errors.New("template: 3: function \"k8sLookup\" failed"),
+				},
+			},
+		},
+		{
+			Name:          "ns-a",
+			FluentdConfig: "<match **>\n  @type central\n</match>",
+		},
+	}
+
+	gen.SetModel(model)
+	_, err := gen.RenderToDisk(ctx, outputDir)
+	assert.Nil(t, err)
+
+	admin, err := os.ReadFile(filepath.Join(outputDir, "admin-ns.conf"))
+	assert.Nil(t, err)
+	assert.True(t, strings.Index(string(admin), "systemd.**") < strings.Index(string(admin), "kube.audit.**"))
+	assert.NotContains(t, string(admin), "kube.partial.**")
+	assert.NotContains(t, string(admin), "central")
+
+	nsA, err := os.ReadFile(filepath.Join(outputDir, "ns-ns-a.conf"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(nsA), "@type elasticsearch")
+
+	assert.Equal(t, "", su.statuses["outputs"][0].FailedCondition)
+	assert.Equal(t, "", su.statuses["audit"][0].FailedCondition)
+	assert.Equal(t, datasource.ConditionParsed, su.statuses["broken"][0].FailedCondition)
+	assert.Equal(t, datasource.ConditionParsed, su.statuses["templated"][0].FailedCondition)
+	assert.Equal(t, "template: 3: function \"k8sLookup\" failed", su.statuses["templated"][0].Message)
+
+	// the statuses are reported again only when they change
+	gen.SetModel(model)
+	_, err = gen.RenderToDisk(ctx, outputDir)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(su.statuses["outputs"]))
+	assert.Equal(t, 1, len(su.statuses["broken"]))
+}
+
+func TestRenderToDiskWritesSecretFiles(t *testing.T) {
+	gen, _, outputDir := makeTestGenerator(t)
+	defer os.RemoveAll(outputDir)
+	gen.cfg.AllowSecrets = true
+	gen.cfg.OutputDir = outputDir
+	ctx := context.Background()
+
+	key, err := loadSecretNameKey(filepath.Join(outputDir, secretsDirName))
+	assert.Nil(t, err)
+	gen.secretNameKey = key
+
+	model := []*datasource.NamespaceConfig{
+		{
+			Name:          "ns-a",
+			FluentdConfig: "<match **>\n  @type null\n  password $secret(creds, password)\n</match>",
+			Secrets: map[string]map[string][]byte{
+				"creds": {"password": []byte("hunter2")},
+			},
+		},
+	}
+
+	gen.SetModel(model)
+	_, err = gen.RenderToDisk(ctx, outputDir)
+	assert.Nil(t, err)
+
+	nsA, err := os.ReadFile(filepath.Join(outputDir, "ns-ns-a.conf"))
+	assert.Nil(t, err)
+	assert.NotContains(t, string(nsA), "hunter2")
+	assert.Contains(t, string(nsA), filepath.Join(outputDir, secretsDirName, "ns-a-creds-password-"))
+
+	entries, err := os.ReadDir(filepath.Join(outputDir, secretsDirName))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, secretNameKeyFile, entries[0].Name())
+	first := entries[1].Name()
+
+	info, err := os.Stat(filepath.Join(outputDir, secretsDirName, secretNameKeyFile))
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// a restarted generator reads the same key and names the files the same
+	restarted, err := New(ctx, gen.cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, key, restarted.(*generatorInstance).secretNameKey)
+
+	info, err = os.Stat(filepath.Join(outputDir, secretsDirName, first))
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	content, err := os.ReadFile(filepath.Join(outputDir, secretsDirName, first))
+	assert.Nil(t, err)
+	assert.Equal(t, "hunter2", string(content))
+
+	// a changed secret gets a new file and the old one is removed
+	model[0].Secrets["creds"]["password"] = []byte("hunter3")
+	gen.SetModel(model)
+	_, err = gen.RenderToDisk(ctx, outputDir)
+	assert.Nil(t, err)
+
+	entries, err = os.ReadDir(filepath.Join(outputDir, secretsDirName))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, secretNameKeyFile, entries[0].Name())
+	assert.NotEqual(t, first, entries[1].Name())
+}
+
+func TestRenderToDiskRevalidatesChangedIncludes(t *testing.T) {
+	gen, validator, outputDir := makeTestGenerator(t)
+	defer os.RemoveAll(outputDir)
+	ctx := context.Background()
+
+	model := []*datasource.NamespaceConfig{
+		{
+			Name:          "ns-a",
+			FluentdConfig: "<match **>\n  @include output.conf\n</match>",
+			IncludeFiles:  map[string]string{"output.conf": "@type null"},
+		},
+	}
+
+	gen.SetModel(model)
+	_, err := gen.RenderToDisk(ctx, outputDir)
+	assert.Nil(t, err)
+
+	nsA, err := os.ReadFile(filepath.Join(outputDir, "ns-ns-a.conf"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(nsA), "@type null")
+
+	model[0].IncludeFiles = map[string]string{"output.conf": "@type invalid"}
+	gen.SetModel(model)
+	_, err = gen.RenderToDisk(ctx, outputDir)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"ns-a": 2}, validator.calls)
+}
+
+func TestRenderToDiskPreservesFormatting(t *testing.T) {
+	gen, _, outputDir := makeTestGenerator(t)
+	defer os.RemoveAll(outputDir)
+	ctx := context.Background()
+	gen.cfg.PreserveFormatting = true
+
+	gen.SetModel([]*datasource.NamespaceConfig{
+		{
+			Name: "ns-a",
+			FluentdConfig: `
+# drop everything
+<match **>
+  @type null
+  # checked last
+  b_param 2
+  a_param 1
+</match>
+`,
+		},
+	})
+	_, err := gen.RenderToDisk(ctx, outputDir)
+	assert.Nil(t, err)
+
+	nsA, err := os.ReadFile(filepath.Join(outputDir, "ns-ns-a.conf"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(nsA), "# drop everything\n<match kube.ns-a.**>\n  @type null\n  # checked last\n  b_param 2\n  a_param 1\n</match>\n")
+}
+
+func TestCountDirectives(t *testing.T) {
+	gen, _, outputDir := makeTestGenerator(t)
+	defer os.RemoveAll(outputDir)
+	ctx := context.Background()
+
+	gen.SetModel([]*datasource.NamespaceConfig{
+		{
+			Name: "ns-a",
+			FluentdConfig: `
+<filter **>
+  @type record_transformer
+</filter>
+
+<match **>
+  @type null
+  <buffer>
+    flush_interval 1s
+  </buffer>
+</match>`,
+		},
+	})
+	_, err := gen.RenderToDisk(ctx, outputDir)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 2, gen.renderCache["ns-a"].directives)
+}
+
+func TestRenderToDiskAddsRecordAnnotations(t *testing.T) {
+	gen, _, outputDir := makeTestGenerator(t)
+	defer os.RemoveAll(outputDir)
+	ctx := context.Background()
+
+	gen.SetModel([]*datasource.NamespaceConfig{})
+	_, err := gen.RenderToDisk(ctx, outputDir)
+	assert.Nil(t, err)
+
+	contents, err := os.ReadFile(filepath.Join(outputDir, "kubernetes-postprocess.conf"))
+	assert.Nil(t, err)
+	assert.NotContains(t, string(contents), "annotation_match")
+	assert.NotContains(t, string(contents), "owner_kind")
+
+	// only the listed annotations are added to the records
+	gen.cfg.RecordAnnotations = []string{"example.com/team"}
+	_, err = gen.RenderToDisk(ctx, outputDir)
+	assert.Nil(t, err)
+
+	contents, err = os.ReadFile(filepath.Join(outputDir, "kubernetes-postprocess.conf"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(contents), "  cache_size 10000\n  annotation_match [\"^example\\\\.com/team$\"]\n</filter>")
+}
+
+func TestRenderToDiskAddsPodOwnerWhenSelected(t *testing.T) {
+	gen, _, outputDir := makeTestGenerator(t)
+	defer os.RemoveAll(outputDir)
+	ctx := context.Background()
+
+	gen.SetModel([]*datasource.NamespaceConfig{
+		{
+			Name:          "ns-a",
+			FluentdConfig: "<match $labels(app=web)>\n  @type null\n</match>",
+		},
+	})
+	_, err := gen.RenderToDisk(ctx, outputDir)
+	assert.Nil(t, err)
+
+	contents, err := os.ReadFile(filepath.Join(outputDir, "kubernetes-postprocess.conf"))
+	assert.Nil(t, err)
+	assert.NotContains(t, string(contents), "owner_kind")
+
+	// the owner is only computed for every record when a config selects on it
+	gen.SetModel([]*datasource.NamespaceConfig{
+		{
+			Name:          "ns-a",
+			FluentdConfig: "<match $labels(_owner_kind=StatefulSet)>\n  @type null\n</match>",
+		},
+	})
+	_, err = gen.RenderToDisk(ctx, outputDir)
+	assert.Nil(t, err)
+
+	contents, err = os.ReadFile(filepath.Join(outputDir, "kubernetes-postprocess.conf"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(contents), "</match>\n\n# Add the controller of the pod")
+	assert.Contains(t, string(contents), "    dummy_ ${"+util.RubyPodOwner+"}\n")
+}
@@ -0,0 +1,108 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// dataLink points to the directory of the current generation, like in a ConfigMap volume
+	dataLink = "..data"
+	// the files of every generation are rendered into a new directory with this prefix
+	generationDirPrefix = "..gen-"
+)
+
+// newStagingDir creates an empty directory in outputDir to render a generation into
+func newStagingDir(outputDir string) (string, error) {
+	return os.MkdirTemp(outputDir, generationDirPrefix)
+}
+
+// publishDir makes the files rendered in stagingDir the content of outputDir in a single step.
+// Every generated file in outputDir is a symlink through ..data, which is flipped to stagingDir
+// with a rename: fluentd never reads files of two different generations.
+func publishDir(outputDir string, stagingDir string) error {
+	entries, err := os.ReadDir(stagingDir)
+	if err != nil {
+		return err
+	}
+
+	names := map[string]bool{}
+	for _, e := range entries {
+		if !e.IsDir() {
+			names[e.Name()] = true
+		}
+	}
+
+	// link the new files first: the current generation does not include them yet
+	for name := range names {
+		if _, err := os.Lstat(filepath.Join(outputDir, name)); os.IsNotExist(err) {
+			if err := replaceWithSymlink(filepath.Join(dataLink, name), filepath.Join(outputDir, name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	current := filepath.Base(stagingDir)
+	if err := replaceWithSymlink(current, filepath.Join(outputDir, dataLink)); err != nil {
+		return err
+	}
+
+	entries, err = os.ReadDir(outputDir)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		name := e.Name()
+		path := filepath.Join(outputDir, name)
+
+		if strings.HasPrefix(name, generationDirPrefix) {
+			if name != current {
+				if err := os.RemoveAll(path); err != nil {
+					logrus.Warnf("Error removing previous generation %s: %+v", path, err)
+				}
+			}
+			continue
+		}
+
+		if name == dataLink {
+			continue
+		}
+
+		target, err := os.Readlink(path)
+		linked := err == nil && target == filepath.Join(dataLink, name)
+		switch {
+		case names[name] && !linked:
+			// a regular file written before the output dir was managed this way
+			if err := replaceWithSymlink(filepath.Join(dataLink, name), path); err != nil {
+				return err
+			}
+		case !names[name] && linked:
+			if err := os.Remove(path); err != nil {
+				logrus.Warnf("Error removing unused file %s: %+v", path, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// replaceWithSymlink atomically replaces path with a symlink to target
+func replaceWithSymlink(target string, path string) error {
+	tmp := path + ".tmp"
+	if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.Symlink(target, tmp); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,91 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package generator
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/vmware/kube-fluentd-operator/config-reloader/processors"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/util"
+
+	"github.com/sirupsen/logrus"
+)
+
+// the secret files are written to this dir of the output dir, outside of the generations:
+// fluentd reads them when validating a config, before the config is published
+const secretsDirName = "secrets"
+
+// secretNameKeyFile holds the key of the secret file names in the secrets dir, so that
+// the names, and the generated configs, do not change when the config-reloader restarts
+const secretNameKeyFile = ".name-key"
+
+// loadSecretNameKey reads the key of the secret file names from the dir, creating it
+// the first time
+func loadSecretNameKey(dir string) ([]byte, error) {
+	filename := filepath.Join(dir, secretNameKeyFile)
+	key, err := os.ReadFile(filename)
+	if err == nil && len(key) > 0 {
+		return key, nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key, err = processors.NewSecretNameKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	if err := util.WriteSecretFile(filename, key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// writeSecretFiles writes the files missing from the secrets dir. A file name depends on
+// its content so an existing file is never rewritten.
+func (g *generatorInstance) writeSecretFiles(files map[string][]byte) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(g.secretsDir, 0o700); err != nil {
+		return err
+	}
+
+	for name, content := range files {
+		filename := filepath.Join(g.secretsDir, name)
+		if _, err := os.Stat(filename); err == nil {
+			continue
+		}
+		if err := util.WriteSecretFile(filename, content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pruneSecretFiles removes the secret files neither the last generation nor the snapshot read
+func (g *generatorInstance) pruneSecretFiles() {
+	entries, err := os.ReadDir(g.secretsDir)
+	if err != nil {
+		return
+	}
+
+	for _, e := range entries {
+		name := e.Name()
+		if name == secretNameKeyFile || g.secretFiles[name] || (g.snapshot != nil && g.snapshot.secretFiles[name]) {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(g.secretsDir, name)); err != nil {
+			logrus.Warnf("Error removing unused secret file %s: %+v", name, err)
+		}
+	}
+}
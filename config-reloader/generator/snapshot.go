@@ -0,0 +1,96 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/vmware/kube-fluentd-operator/config-reloader/datasource"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/util"
+)
+
+// snapshot is a copy of generated files fluentd is known to run
+type snapshot struct {
+	generation string
+	// file name -> content
+	files map[string]string
+	// the secret files the config reads, they are kept until the snapshot is replaced
+	secretFiles map[string]bool
+}
+
+// Generation identifies the config last written by RenderToDisk
+func (g *generatorInstance) Generation() string {
+	return g.generation
+}
+
+// SaveSnapshot keeps a copy of the current generation in outputDir as the last known good config
+func (g *generatorInstance) SaveSnapshot(outputDir string) error {
+	files, err := filepath.Glob(filepath.Join(outputDir, dataLink, "*.conf"))
+	if err != nil {
+		return err
+	}
+
+	s := &snapshot{
+		generation:  g.generation,
+		files:       map[string]string{},
+		secretFiles: g.secretFiles,
+	}
+	for _, f := range files {
+		content, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		s.files[filepath.Base(f)] = string(content)
+	}
+
+	g.snapshot = s
+	return nil
+}
+
+// RestoreSnapshot replaces the content of outputDir with the last known good config.
+// It returns the generation of the restored config
+func (g *generatorInstance) RestoreSnapshot(outputDir string) (string, error) {
+	if g.snapshot == nil {
+		return "", errors.New("no known good config to restore")
+	}
+
+	stagingDir, err := newStagingDir(outputDir)
+	if err != nil {
+		return "", err
+	}
+
+	for name, content := range g.snapshot.files {
+		err = util.WriteStringToFile(filepath.Join(stagingDir, name), content)
+		if err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = publishDir(outputDir, stagingDir)
+	}
+	if err != nil {
+		os.RemoveAll(stagingDir)
+		return "", err
+	}
+
+	return g.snapshot.generation, nil
+}
+
+// RejectConfigs marks the namespace configs fluentd did not load as failed. They are
+// left out of the generated files until they change
+func (g *generatorInstance) RejectConfigs(ctx context.Context, configHashes map[string]string, err error) {
+	for ns, hash := range configHashes {
+		res := &renderResult{
+			namespace:  ns,
+			configHash: hash,
+			err:        &conditionError{condition: datasource.ConditionApplied, err: fmt.Errorf("fluentd did not load the config: %w", err)},
+		}
+		g.rejected[ns] = res
+		g.updateStatus(ctx, ns, res)
+	}
+}
@@ -5,8 +5,10 @@ package main
 
 import (
 	"context"
+	"errors"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -14,7 +16,10 @@ import (
 	"github.com/vmware/kube-fluentd-operator/config-reloader/controller"
 	"github.com/vmware/kube-fluentd-operator/config-reloader/datasource"
 	"github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/formatter"
 	"github.com/vmware/kube-fluentd-operator/config-reloader/metrics"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/render"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/webhook"
 
 	"github.com/sirupsen/logrus"
 )
@@ -44,6 +49,39 @@ func main() {
 
 	logrus.SetLevel(cfg.GetLogLevel())
 
+	if cfg.Command == config.CommandFmt {
+		if err := formatter.Run(cfg, os.Stdout); err != nil {
+			logrus.Fatalf("Formatting failed: %+v", err)
+		}
+		return
+	}
+
+	if cfg.Command == config.CommandRender {
+		if err := render.Run(ctx, cfg, os.Stdout); err != nil {
+			logrus.Fatalf("Render failed: %+v", err)
+		}
+		return
+	}
+
+	// the health checks are served while the informers sync
+	var started atomic.Bool
+	if cfg.IntervalSeconds != 0 {
+		startHTTP := metrics.InitHealth
+		if cfg.PrometheusEnabled {
+			startHTTP = metrics.InitMetrics
+		}
+		if err := startHTTP(cfg.MetricsPort); err != nil {
+			logrus.Fatalf("Cannot serve health checks %+v", err)
+		}
+
+		metrics.AddReadinessCheck("informers", func() error {
+			if !started.Load() {
+				return errors.New("the informers are not synced yet")
+			}
+			return nil
+		})
+	}
+
 	// Create datasource and updater base in config
 	var ds datasource.Datasource
 	var up controller.Updater
@@ -63,7 +101,8 @@ func main() {
 		if err != nil {
 			logrus.Fatalf("Cannot start informer %+v", err)
 		}
-		up = controller.NewOnDemandUpdater(ctx, updateChan)
+		up = controller.NewOnDemandUpdater(ctx, updateChan,
+			time.Second*time.Duration(cfg.UpdateDebounce), time.Second*time.Duration(cfg.UpdateMaxLatency))
 	}
 
 	ctrl, err := controller.New(ctx, cfg, ds, up)
@@ -71,11 +110,15 @@ func main() {
 		logrus.Fatalf("Cannot start control loop %+v", err)
 	}
 
-	// Add this for a timeout between 0-120 seconds (default: 30 (ExecTimeoutSeconds))
-	// This is for golang/fluentd race condition when KFO starts/restarts:
-	if cfg.ExecTimeoutSeconds > 0 && cfg.ExecTimeoutSeconds <= 120 {
-		logrus.Infof("Sleeping for %v seconds in order for fluentd to be ready.", cfg.ExecTimeoutSeconds)
-		time.Sleep(time.Second * time.Duration(cfg.ExecTimeoutSeconds))
+	metrics.AddReadinessCheck("control-loop", ctrl.Ready)
+	started.Store(true)
+
+	// fluentd and the config-reloader start together, the first reload fails if fluentd is not up yet
+	if cfg.StartupTimeout > 0 {
+		logrus.Infof("Waiting up to %v seconds for fluentd to be ready", cfg.StartupTimeout)
+		if err := ctrl.WaitForFluentd(ctx, time.Second*time.Duration(cfg.StartupTimeout)); err != nil {
+			logrus.Warnf("Running without fluentd: %+v", err)
+		}
 	}
 
 	if cfg.IntervalSeconds == 0 {
@@ -86,8 +129,12 @@ func main() {
 	stopChan := make(chan struct{}, 1)
 	go handleSigterm(stopChan)
 
-	if cfg.PrometheusEnabled {
-		metrics.InitMetrics(cfg.MetricsPort)
+	if cfg.WebhookPort > 0 {
+		// the fake and fs datasources don't know the admin namespace plugins
+		source, _ := ds.(webhook.ConfigSource)
+		if err := webhook.New(ctx, cfg, source).Start(ctx); err != nil {
+			logrus.Fatalf("Cannot start admission webhook %+v", err)
+		}
 	}
 
 	ctrl.Run(ctx, stopChan)
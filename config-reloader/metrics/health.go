@@ -0,0 +1,60 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+type readinessCheck struct {
+	name  string
+	check func() error
+}
+
+var readiness = struct {
+	sync.Mutex
+	checks []readinessCheck
+}{}
+
+// AddReadinessCheck adds a condition to /readyz. The config-reloader is ready when
+// every check returns nil
+func AddReadinessCheck(name string, check func() error) {
+	readiness.Lock()
+	defer readiness.Unlock()
+
+	readiness.checks = append(readiness.checks, readinessCheck{name: name, check: check})
+}
+
+// notReady runs the readiness checks and returns the failures, one per line
+func notReady() []string {
+	readiness.Lock()
+	checks := append([]readinessCheck{}, readiness.checks...)
+	readiness.Unlock()
+
+	var failures []string
+	for _, c := range checks {
+		if err := c.check(); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", c.name, err))
+		}
+	}
+	return failures
+}
+
+// serveHealthz answers as long as the config-reloader is running
+func serveHealthz(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+// serveReadyz answers 503 with the failed checks until the config-reloader is ready
+func serveReadyz(w http.ResponseWriter, r *http.Request) {
+	if failures := notReady(); len(failures) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, strings.Join(failures, "\n"))
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
@@ -5,6 +5,7 @@ import (
 	"net"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -12,6 +13,22 @@ import (
 
 const (
 	LabelTargetNamespace = "target_namespace"
+	LabelReason          = "reason"
+	LabelMethod          = "method"
+	LabelResult          = "result"
+	LabelResource        = "resource"
+)
+
+// Values of the result label
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+// Values of the reason label of validator failures
+const (
+	ReasonTimeout = "timeout"
+	ReasonError   = "error"
 )
 
 var namespaceConfigStatus = prometheus.NewGaugeVec(prometheus.GaugeOpts{
@@ -30,33 +47,149 @@ func SetNamespaceConfigStatusMetric(namespace string, valid bool) {
 	namespaceConfigStatus.With(prometheus.Labels{LabelTargetNamespace: namespace}).Set(value)
 }
 
-// DeleteNamespaceConfigStatusMetric deletes the metric value for a given namespace
-func DeleteNamespaceConfigStatusMetric(namespace string) {
-	namespaceConfigStatus.Delete(prometheus.Labels{LabelTargetNamespace: namespace})
+var runOnceDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+	Namespace: "kube_fluentd_operator",
+	Name:      "run_once_duration_seconds",
+	Help:      "Duration of a run of the control loop, from reading the datasource to reloading fluentd",
+	Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+})
+
+var namespaceRenderDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "kube_fluentd_operator",
+	Name:      "namespace_render_duration_seconds",
+	Help:      "Duration of the processing of the fluentd config of a namespace",
+	Buckets:   []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1},
+}, []string{LabelTargetNamespace})
+
+var namespaceValidationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "kube_fluentd_operator",
+	Name:      "namespace_validation_duration_seconds",
+	Help:      "Duration of the validation of the fluentd config of a namespace with the fluentd binary",
+	Buckets:   []float64{0.5, 1, 2.5, 5, 10, 20, 30, 60},
+}, []string{LabelTargetNamespace})
+
+var namespaceGeneratedDirectives = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "kube_fluentd_operator",
+	Name:      "namespace_generated_directives",
+	Help:      "Number of top-level directives generated for the namespace",
+}, []string{LabelTargetNamespace})
+
+var validatorFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "kube_fluentd_operator",
+	Name:      "validator_failures_total",
+	Help:      "Runs of the fluentd validator that did not succeed. The reason is timeout or error",
+}, []string{LabelReason})
+
+var fluentdReloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "kube_fluentd_operator",
+	Name:      "fluentd_reloads_total",
+	Help:      "Calls to the fluentd RPC endpoint to reload the config. The method is config.gracefulReload or config.reload when falling back",
+}, []string{LabelMethod, LabelResult})
+
+var informerUpdates = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "kube_fluentd_operator",
+	Name:      "informer_updates_total",
+	Help:      "Changes of Kubernetes resources that triggered a run of the control loop",
+}, []string{LabelResource})
+
+// ObserveRunOnceDuration records the duration of a run of the control loop
+func ObserveRunOnceDuration(d time.Duration) {
+	runOnceDuration.Observe(d.Seconds())
+}
+
+// ObserveNamespaceRenderDuration records how long processing the config of a namespace took
+func ObserveNamespaceRenderDuration(namespace string, d time.Duration) {
+	namespaceRenderDuration.With(prometheus.Labels{LabelTargetNamespace: namespace}).Observe(d.Seconds())
+}
+
+// ObserveNamespaceValidationDuration records how long validating the config of a namespace took
+func ObserveNamespaceValidationDuration(namespace string, d time.Duration) {
+	namespaceValidationDuration.With(prometheus.Labels{LabelTargetNamespace: namespace}).Observe(d.Seconds())
+}
+
+// SetNamespaceGeneratedDirectivesMetric sets the number of directives generated for a namespace
+func SetNamespaceGeneratedDirectivesMetric(namespace string, directives int) {
+	namespaceGeneratedDirectives.With(prometheus.Labels{LabelTargetNamespace: namespace}).Set(float64(directives))
+}
+
+// IncValidatorFailures counts a failed run of the fluentd validator
+func IncValidatorFailures(reason string) {
+	validatorFailures.With(prometheus.Labels{LabelReason: reason}).Inc()
+}
+
+// IncFluentdReloads counts a call to the fluentd RPC endpoint
+func IncFluentdReloads(method string, result string) {
+	fluentdReloads.With(prometheus.Labels{LabelMethod: method, LabelResult: result}).Inc()
+}
+
+// IncInformerUpdates counts a change of a resource that triggered a run
+func IncInformerUpdates(resource string) {
+	informerUpdates.With(prometheus.Labels{LabelResource: resource}).Inc()
+}
+
+// DeleteNamespaceMetrics deletes the metric values for a given namespace
+func DeleteNamespaceMetrics(namespace string) {
+	labels := prometheus.Labels{LabelTargetNamespace: namespace}
+	namespaceConfigStatus.Delete(labels)
+	namespaceGeneratedDirectives.Delete(labels)
+	namespaceRenderDuration.Delete(labels)
+	namespaceValidationDuration.Delete(labels)
+}
+
+// mux serves the metrics, the health checks and the debug endpoints
+var mux = newServeMux()
+
+func newServeMux() *http.ServeMux {
+	m := http.NewServeMux()
+	m.HandleFunc("/healthz", serveHealthz)
+	m.HandleFunc("/readyz", serveReadyz)
+	return m
+}
+
+// RegisterDebugHandler serves a debug endpoint next to the metrics
+func RegisterDebugHandler(pattern string, handler http.Handler) {
+	mux.Handle(pattern, handler)
 }
 
 // InitMetrics should be called to initialize metrics and start the HTTP handler
 func InitMetrics(port int) error {
-	if err := serveMetrics(port); err != nil {
+	registerMetrics()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	if err := serve(port); err != nil {
 		return fmt.Errorf("Failed to start metrics handler: %s", err)
 	}
+	return nil
+}
 
-	registerMetrics()
+// InitHealth starts the HTTP handler without the metrics, only /healthz, /readyz
+// and the debug endpoints are served
+func InitHealth(port int) error {
+	if err := serve(port); err != nil {
+		return fmt.Errorf("Failed to start health handler: %s", err)
+	}
 	return nil
 }
 
 func registerMetrics() {
-	prometheus.MustRegister(namespaceConfigStatus)
+	prometheus.MustRegister(
+		namespaceConfigStatus,
+		runOnceDuration,
+		namespaceRenderDuration,
+		namespaceValidationDuration,
+		namespaceGeneratedDirectives,
+		validatorFailures,
+		fluentdReloads,
+		informerUpdates,
+	)
 }
 
-func serveMetrics(port int) error {
+func serve(port int) error {
 	ln, err := net.Listen("tcp", ":"+strconv.Itoa(port))
 	if err != nil {
 		return err
 	}
 
-	mux := http.NewServeMux()
-	mux.Handle("/metrics", promhttp.Handler())
 	srv := &http.Server{Handler: mux}
 	go func() {
 		srv.Serve(ln)
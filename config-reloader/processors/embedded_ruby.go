@@ -0,0 +1,60 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package processors
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
+)
+
+// rejectEmbeddedRubyState refuses the "#{...}" Ruby code that fluentd would run when it
+// loads or validates the config, unless --allow-embedded-ruby or a FluentdPolicy of the
+// namespace allows it. It runs first so that the virtual plugins of the admin namespace
+// and the files of $secret can still use embedded Ruby.
+type rejectEmbeddedRubyState struct {
+	BaseProcessorState
+}
+
+func (p *rejectEmbeddedRubyState) Prepare(input fluentd.Fragment) (fluentd.Fragment, error) {
+	return nil, p.check(input)
+}
+
+func (p *rejectEmbeddedRubyState) Process(input fluentd.Fragment) (fluentd.Fragment, error) {
+	if err := p.check(input); err != nil {
+		return nil, err
+	}
+
+	return input, nil
+}
+
+func (p *rejectEmbeddedRubyState) check(input fluentd.Fragment) error {
+	if p.Context.AllowEmbeddedRuby {
+		return nil
+	}
+
+	for _, policy := range p.Context.Policies {
+		if policy.Spec.AllowEmbeddedRuby {
+			return nil
+		}
+	}
+
+	check := func(d *fluentd.Directive, ctx *ProcessorContext) error {
+		names := make([]string, 0, len(d.Params))
+		for name := range d.Params {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if param := d.Params[name]; param.EmbeddedRuby() {
+				return fluentd.ErrorAt(param.Pos, fmt.Errorf("cannot use embedded Ruby code in %s", name))
+			}
+		}
+		return nil
+	}
+
+	return applyRecursivelyInPlace(input, p.Context, check)
+}
@@ -0,0 +1,105 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package processors
+
+import (
+	"testing"
+
+	kfo "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/apis/logs.vdp.vmware.com/v1beta1"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRejectEmbeddedRuby(t *testing.T) {
+	s := `
+<match **>
+  @type elasticsearch
+  <buffer>
+    path "/var/log/#{File.read('/etc/passwd')}"
+  </buffer>
+</match>
+`
+	fragment, err := fluentd.ParseString(s)
+	assert.Nil(t, err)
+
+	ctx := &ProcessorContext{
+		Namespace: "monitoring",
+	}
+
+	_, err = Process(fragment, ctx, &rejectEmbeddedRubyState{})
+	assert.NotNil(t, err)
+	assert.Equal(t, "line 5: cannot use embedded Ruby code in path", err.Error())
+
+	// the directives of fluent.conf are checked too
+	_, err = Prepare(fragment, ctx, &rejectEmbeddedRubyState{})
+	assert.NotNil(t, err)
+
+	// single quotes and placeholders are not evaluated
+	s = `
+<filter **>
+  @type record_transformer
+  <record>
+    literal '#{not Ruby}'
+    host ${hostname}
+  </record>
+</filter>
+`
+	fragment, err = fluentd.ParseString(s)
+	assert.Nil(t, err)
+
+	_, err = Process(fragment, ctx, &rejectEmbeddedRubyState{})
+	assert.Nil(t, err)
+}
+
+func TestAllowEmbeddedRubyByFlag(t *testing.T) {
+	fragment, err := fluentd.ParseString("<match **>\n  @type forward\n  host \"#{ENV['FORWARD_HOST']}\"\n</match>")
+	assert.Nil(t, err)
+
+	ctx := &ProcessorContext{
+		Namespace:         "monitoring",
+		AllowEmbeddedRuby: true,
+	}
+
+	processed, err := Process(fragment, ctx, &rejectEmbeddedRubyState{})
+	assert.Nil(t, err)
+	assert.Equal(t, `"#{ENV['FORWARD_HOST']}"`, processed[0].ParamVerbatim("host"))
+}
+
+func TestAllowEmbeddedRubyByPolicy(t *testing.T) {
+	s := `
+<match **>
+  @type forward
+  host "#{ENV['FORWARD_HOST']}"
+  password $secret(creds, password)
+</match>
+`
+	fragment, err := fluentd.ParseString(s)
+	assert.Nil(t, err)
+
+	ctx := makePolicyContext(kfo.FluentdPolicySpec{AllowEmbeddedRuby: true})
+	ctx.AllowSecrets = true
+	ctx.SecretNameKey = []byte("key")
+	ctx.Secrets = map[string]map[string][]byte{
+		"creds": {"password": []byte("hunter2")},
+	}
+
+	processed, err := Process(fragment, ctx, &rejectEmbeddedRubyState{}, &expandSecretsState{})
+	assert.Nil(t, err)
+	assert.Equal(t, `"#{ENV['FORWARD_HOST']}"`, processed[0].ParamVerbatim("host"))
+
+	// $secret is expanded to embedded Ruby after the check
+	fragment, err = fluentd.ParseString("<match **>\n  @type forward\n  password $secret(creds, password)\n</match>")
+	assert.Nil(t, err)
+
+	ctx = makePolicyContext(kfo.FluentdPolicySpec{})
+	ctx.AllowSecrets = true
+	ctx.SecretNameKey = []byte("key")
+	ctx.Secrets = map[string]map[string][]byte{
+		"creds": {"password": []byte("hunter2")},
+	}
+	processed, err = Process(fragment, ctx, &rejectEmbeddedRubyState{}, &expandSecretsState{})
+	assert.Nil(t, err)
+	assert.True(t, processed[0].Params["password"].EmbeddedRuby())
+}
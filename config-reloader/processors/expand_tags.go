@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/util"
 )
 
 const (
@@ -32,6 +33,11 @@ func (p *expandTagsState) ProcessExpandingTags(input fluentd.Fragment) (fluentd.
 			return []*fluentd.Directive{d}, nil
 		}
 
+		if strings.HasPrefix(d.Tag, util.MacroLabels) {
+			// the selectors of $labels may nest parentheses, there is nothing to expand
+			return []*fluentd.Directive{d}, nil
+		}
+
 		if p.tagMatcher == nil {
 			p.tagMatcher = regexp.MustCompile(tagRegex)
 		}
@@ -119,7 +125,7 @@ func applyRecursivelyWithState(directives fluentd.Fragment, ctx *ProcessorContex
 	for _, d := range directives {
 		output, err := callback(d, ctx)
 		if err != nil {
-			return nil, err
+			return nil, fluentd.ErrorAt(d.Pos, err)
 		}
 		newDirectives = append(newDirectives, output...)
 	}
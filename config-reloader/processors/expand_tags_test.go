@@ -93,6 +93,21 @@ func TestNestedTagsExpandOk(t *testing.T) {
 	assert.True(t, !strings.Contains(fragment.String(), "}"))
 }
 
+func TestTagsExpandSkipsLabels(t *testing.T) {
+	fragment, err := fluentd.ParseString("<match $labels(tier in (front, back), app)>\n  @type null\n</match>")
+	assert.Nil(t, err)
+
+	ctx := &ProcessorContext{
+		Namespace:         "monitoring",
+		GenerationContext: &GenerationContext{},
+		AllowTagExpansion: true,
+	}
+	fragment, err = Process(fragment, ctx, &expandTagsState{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(fragment))
+	assert.Equal(t, "$labels(tier in (front, back), app)", fragment[0].Tag)
+}
+
 func TestTagsExpandBadConfig(t *testing.T) {
 	ctx := &ProcessorContext{
 		Namespace:         "monitoring",
@@ -0,0 +1,102 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package processors
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
+)
+
+// Phases recorded in a Trace
+const (
+	PhasePrepare = "prepare"
+	PhaseProcess = "process"
+)
+
+// Step is the config as left by a single processor
+type Step struct {
+	Phase     string
+	Processor string
+	Config    string
+	Err       error
+}
+
+// Trace records the output of every processor when set in a ProcessorContext.
+// It is used to explain how a namespace config was transformed.
+type Trace struct {
+	Namespace string
+	Steps     []*Step
+}
+
+// NewTrace creates an empty trace for the namespace
+func NewTrace(namespace string) *Trace {
+	return &Trace{
+		Namespace: namespace,
+	}
+}
+
+// ProcessorName returns the name of the processor as shown in a Trace
+func ProcessorName(proc FragmentProcessor) string {
+	t := reflect.TypeOf(proc)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t.Name()
+}
+
+func (t *Trace) record(phase string, name string, f fluentd.Fragment, err error) {
+	if t == nil {
+		return
+	}
+
+	t.Steps = append(t.Steps, &Step{
+		Phase:     phase,
+		Processor: name,
+		Config:    f.String(),
+		Err:       err,
+	})
+}
+
+// Explain renders the trace as the input of every phase followed by the unified
+// diff introduced by each processor
+func (t *Trace) Explain() string {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "# explain namespace %s\n", t.Namespace)
+
+	var prev *Step
+	for _, step := range t.Steps {
+		if prev == nil || prev.Phase != step.Phase {
+			// the first step of a phase is its input
+			fmt.Fprintf(buf, "\n## %s: %s\n%s", step.Phase, step.Processor, step.Config)
+			prev = step
+			continue
+		}
+
+		fmt.Fprintf(buf, "\n## %s: %s\n", step.Phase, step.Processor)
+		if step.Err != nil {
+			fmt.Fprintf(buf, "error: %s\n", step.Err.Error())
+			continue
+		}
+
+		diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(prev.Config),
+			B:        difflib.SplitLines(step.Config),
+			FromFile: prev.Processor,
+			ToFile:   step.Processor,
+			Context:  3,
+		})
+		if diff == "" {
+			diff = "no changes\n"
+		}
+		buf.WriteString(diff)
+		prev = step
+	}
+
+	return buf.String()
+}
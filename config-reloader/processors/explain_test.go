@@ -0,0 +1,79 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package processors
+
+import (
+	"testing"
+
+	"github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraceRecordsEveryProcessor(t *testing.T) {
+	var s = `
+	<match $thisns.**>
+	  @type null
+	</match>
+	`
+
+	fragment, err := fluentd.ParseString(s)
+	assert.Nil(t, err)
+
+	ctx := &ProcessorContext{
+		Namespace:         "monitoring",
+		GenerationContext: &GenerationContext{},
+		Trace:             NewTrace("monitoring"),
+	}
+	_, err = Process(fragment, ctx, &expandThisnsMacroState{}, &detectExceptionsState{})
+	assert.Nil(t, err)
+
+	assert.Equal(t, 3, len(ctx.Trace.Steps))
+	assert.Equal(t, "input", ctx.Trace.Steps[0].Processor)
+	assert.Equal(t, "expandThisnsMacroState", ctx.Trace.Steps[1].Processor)
+	assert.Equal(t, "detectExceptionsState", ctx.Trace.Steps[2].Processor)
+
+	explanation := ctx.Trace.Explain()
+	assert.Contains(t, explanation, "## process: input\n<match $thisns.**>")
+	assert.Contains(t, explanation, "--- input\n+++ expandThisnsMacroState\n")
+	assert.Contains(t, explanation, "-<match $thisns.**>\n+<match kube.monitoring.**>\n")
+	assert.Contains(t, explanation, "## process: detectExceptionsState\nno changes\n")
+}
+
+func TestTraceRecordsFailingProcessor(t *testing.T) {
+	var s = `
+	<match other.**>
+	  @type null
+	</match>
+	`
+
+	fragment, err := fluentd.ParseString(s)
+	assert.Nil(t, err)
+
+	ctx := &ProcessorContext{
+		Namespace:         "monitoring",
+		GenerationContext: &GenerationContext{},
+		Trace:             NewTrace("monitoring"),
+	}
+	_, err = Process(fragment, ctx, &expandThisnsMacroState{}, &detectExceptionsState{})
+	assert.NotNil(t, err)
+
+	// the processors after the failing one don't run
+	assert.Equal(t, 2, len(ctx.Trace.Steps))
+	assert.Contains(t, ctx.Trace.Explain(), "## process: expandThisnsMacroState\nerror: line 2: bad tag for <match>")
+}
+
+func TestNilTraceIsIgnored(t *testing.T) {
+	fragment, err := fluentd.ParseString("<match **>\n  @type null\n</match>\n")
+	assert.Nil(t, err)
+
+	ctx := &ProcessorContext{
+		Namespace:         "monitoring",
+		GenerationContext: &GenerationContext{},
+	}
+	_, err = Prepare(fragment, ctx, DefaultProcessors()...)
+	assert.Nil(t, err)
+	_, err = Process(fragment, ctx, DefaultProcessors()...)
+	assert.Nil(t, err)
+}
@@ -7,6 +7,7 @@ import (
 	"bytes"
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
@@ -32,7 +33,7 @@ var retagTemplate = template.Must(template.New("retagTemplate").Parse(
   @type record_transformer
   enable_ruby true
   <record>
-    kubernetes_pod_label_values {{range $i, $e := .Labels -}}${record.dig('kubernetes','labels','{{$e}}')&.gsub(/[.-]/, '_') || '_'}{{if isLast $i $.Labels }}{{else}}.{{end}}{{- end}}
+    kubernetes_pod_label_values {{range $i, $e := .Values -}}${ {{- $e}}}{{if isLast $i $.Values }}{{else}}.{{end}}{{- end}}
   </record>
 </filter>
 
@@ -51,7 +52,9 @@ var retagTemplate = template.Must(template.New("retagTemplate").Parse(
 </filter>
 `))
 
-func makeTagFromFilter(ns string, sortedLabelNames []string, labelNames map[string]string) string {
+// the parts of the tag after _labels are the values of the labels, then 1 or 0 for every
+// selector the tag cannot express
+func makeTagFromFilter(ns string, sortedLabelNames []string, labelNames map[string]string, selectors int) string {
 	buf := &bytes.Buffer{}
 
 	if cont, ok := labelNames[util.ContainerLabel]; ok {
@@ -79,6 +82,126 @@ func makeTagFromFilter(ns string, sortedLabelNames []string, labelNames map[stri
 		}
 	}
 
+	for i := 0; i < selectors; i++ {
+		if i > 0 || len(sortedLabelNames) > 0 {
+			buf.WriteString(".")
+		}
+		buf.WriteString("*")
+	}
+
+	return buf.String()
+}
+
+// makeTagFromSelector matches the records for which the selector at index i of the sorted
+// selectors holds, whatever the values of the labels
+func makeTagFromSelector(ns string, sortedLabelNames []string, selectors int, index int) string {
+	parts := make([]string, 0, len(sortedLabelNames)+selectors)
+	for range sortedLabelNames {
+		parts = append(parts, "*")
+	}
+	for i := 0; i < selectors; i++ {
+		if i == index {
+			parts = append(parts, "1")
+		} else {
+			parts = append(parts, "*")
+		}
+	}
+
+	return fmt.Sprintf("kube.%s.*.*._labels.%s", ns, strings.Join(parts, "."))
+}
+
+// rubySelector evaluates the selector on the kubernetes metadata of a record, it matches the
+// containers util.Match does
+func rubySelector(selector util.Selector) string {
+	terms := make([]string, 0, len(selector))
+	for _, req := range selector {
+		terms = append(terms, "("+rubyRequirement(req)+")")
+	}
+
+	return fmt.Sprintf("(%s) ? '1' : '0'", strings.Join(terms, " && "))
+}
+
+func rubyRequirement(req *util.Requirement) string {
+	value := rubyLookup(req.Key)
+
+	values := make([]string, 0, len(req.Values))
+	for _, v := range req.Values {
+		values = append(values, rubyString(v))
+	}
+	list := "[" + strings.Join(values, ", ") + "]"
+
+	// the value of the record is in the list
+	has := func(v string) string {
+		return fmt.Sprintf("%s.include?(%s)", list, v)
+	}
+	if req.Key == util.ImageLabel {
+		// the image without digest, and without tag and digest, is selected too
+		has = func(v string) string {
+			return fmt.Sprintf("!(%s & [%s.to_s, %s.to_s.sub(/@.*/, ''), %s.to_s.sub(/@.*/, '').sub(/:[^:\\/]*\\z/, '')]).empty?", list, v, v, v)
+		}
+	}
+
+	switch req.Operator {
+	case util.OpExists:
+		return fmt.Sprintf("!%s.nil?", value)
+	case util.OpDoesNotExist:
+		return fmt.Sprintf("%s.nil?", value)
+	case util.OpEquals:
+		// like util.Match, a missing label equals the empty string
+		if req.Key == util.ImageLabel {
+			return has(value)
+		}
+		return has(fmt.Sprintf("%s.to_s", value))
+	case util.OpIn:
+		return fmt.Sprintf("!%s.nil? && %s", value, has(value))
+	default:
+		// != and notin
+		return fmt.Sprintf("%s.nil? || !%s", value, has(value))
+	}
+}
+
+// rubyLookup reads the value of a key of a selector from the kubernetes metadata of a record.
+// The labels and annotations are also looked up with their dots replaced by _, as the
+// de_dot option of the kubernetes_metadata filter does.
+func rubyLookup(key string) string {
+	switch key {
+	case util.ContainerLabel:
+		return "record.dig('kubernetes','container_name')"
+	case util.ImageLabel:
+		return "record.dig('kubernetes','container_image')"
+	case util.OwnerKindLabel:
+		return "record.dig('kubernetes','owner_kind')"
+	case util.OwnerNameLabel:
+		return "record.dig('kubernetes','owner_name')"
+	}
+
+	field, name := "labels", key
+	if strings.HasPrefix(key, util.AnnotationPrefix) {
+		field, name = "annotations", key[len(util.AnnotationPrefix):]
+	}
+
+	res := fmt.Sprintf("record.dig('kubernetes','%s','%s')", field, name)
+	if strings.Contains(name, ".") {
+		res = fmt.Sprintf("(%s || record.dig('kubernetes','%s','%s'))", res, field, strings.ReplaceAll(name, ".", "_"))
+	}
+	return res
+}
+
+// rubyString quotes a string for Ruby, the bytes other than letters, digits and ._/:@-
+// are escaped so that neither Ruby nor the fluentd config parser interprets them
+func rubyString(s string) string {
+	buf := &strings.Builder{}
+	buf.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("._/:@-", c) >= 0 {
+			buf.WriteByte(c)
+		} else {
+			fmt.Fprintf(buf, "\\x%02X", c)
+		}
+	}
+	buf.WriteByte('"')
+
 	return buf.String()
 }
 
@@ -90,6 +213,7 @@ func safeLabelValue(s string) string {
 
 func (p *expandLabelsMacroState) Process(input fluentd.Fragment) (fluentd.Fragment, error) {
 	allReferencedLabels := map[string]string{}
+	resolvedSelectors := map[string]util.Selector{}
 	collectLabels := func(d *fluentd.Directive, ctx *ProcessorContext) error {
 		if d.Name != "filter" && d.Name != "match" {
 			return nil
@@ -99,11 +223,20 @@ func (p *expandLabelsMacroState) Process(input fluentd.Fragment) (fluentd.Fragme
 			return nil
 		}
 
-		labelNames, err := util.ParseTagToLabels(d.Tag)
+		selector, err := util.ParseTagToLabels(d.Tag)
 		if err != nil {
 			return err
 		}
 
+		labelNames, ok := selector.Equalities()
+		if !ok {
+			if err := checkRecordAnnotations(selector, ctx.RecordAnnotations); err != nil {
+				return fluentd.ErrorAt(d.Pos, err)
+			}
+			resolvedSelectors[d.Tag] = selector
+			return nil
+		}
+
 		for lb := range labelNames {
 			allReferencedLabels[lb] = ""
 		}
@@ -114,12 +247,19 @@ func (p *expandLabelsMacroState) Process(input fluentd.Fragment) (fluentd.Fragme
 	if e != nil {
 		return nil, e
 	}
-	if len(allReferencedLabels) == 0 {
+	if len(allReferencedLabels) == 0 && len(resolvedSelectors) == 0 {
 		return input, nil
 	}
 
 	delete(allReferencedLabels, util.ContainerLabel)
 	sortedLabelNames := util.SortedKeys(allReferencedLabels)
+	// the selectors that the tag cannot express are evaluated on the records, each one
+	// adds a part to the tag
+	sortedSelectors := make([]string, 0, len(resolvedSelectors))
+	for tag := range resolvedSelectors {
+		sortedSelectors = append(sortedSelectors, tag)
+	}
+	sort.Strings(sortedSelectors)
 
 	replaceLabels := func(d *fluentd.Directive, ctx *ProcessorContext) error {
 		if d.Name != "filter" && d.Name != "match" {
@@ -130,25 +270,38 @@ func (p *expandLabelsMacroState) Process(input fluentd.Fragment) (fluentd.Fragme
 			return nil
 		}
 
-		labelNames, err := util.ParseTagToLabels(d.Tag)
+		selector, err := util.ParseTagToLabels(d.Tag)
 		if err != nil {
 			// should never happen as the error should be caught beforehand
 			return nil
 		}
 
-		d.Tag = makeTagFromFilter(ctx.Namespace, sortedLabelNames, labelNames)
+		if labelNames, ok := selector.Equalities(); ok {
+			d.Tag = makeTagFromFilter(ctx.Namespace, sortedLabelNames, labelNames, len(sortedSelectors))
+		} else {
+			index := sort.SearchStrings(sortedSelectors, d.Tag)
+			d.Tag = makeTagFromSelector(ctx.Namespace, sortedLabelNames, len(sortedSelectors), index)
+		}
 		ctx.GenerationContext.augmentTag(d)
 		return nil
 	}
 	applyRecursivelyInPlace(input, p.Context, replaceLabels)
 
 	// prepare extra directives
+	values := make([]string, 0, len(sortedLabelNames)+len(sortedSelectors))
+	for _, lb := range sortedLabelNames {
+		values = append(values, fmt.Sprintf("record.dig('kubernetes','labels','%s')&.gsub(/[.-]/, '_') || '_'", lb))
+	}
+	for _, tag := range sortedSelectors {
+		values = append(values, rubySelector(resolvedSelectors[tag]))
+	}
+
 	model := struct {
 		Pattern string
-		Labels  []string
+		Values  []string
 	}{
 		fmt.Sprintf("kube.%s.*.*", p.Context.Namespace),
-		sortedLabelNames,
+		values,
 	}
 	writer := &bytes.Buffer{}
 	retagTemplate.Execute(writer, model)
@@ -162,3 +315,26 @@ func (p *expandLabelsMacroState) Process(input fluentd.Fragment) (fluentd.Fragme
 
 	return extraDirectives, nil
 }
+
+// checkRecordAnnotations refuses the annotations the kubernetes_metadata filter does not
+// add to the records
+func checkRecordAnnotations(selector util.Selector, recorded []string) error {
+	for _, req := range selector {
+		if !strings.HasPrefix(req.Key, util.AnnotationPrefix) {
+			continue
+		}
+
+		name := req.Key[len(util.AnnotationPrefix):]
+		found := false
+		for _, r := range recorded {
+			if r == name {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("cannot select on annotation %s, the records do not carry it", name)
+		}
+	}
+
+	return nil
+}
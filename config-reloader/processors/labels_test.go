@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/util"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -237,3 +238,72 @@ func TestLabelWithLabelsAndContainer(t *testing.T) {
 		assert.Equal(t, "kube.monitoring.*.*._labels.prom.helm_12.*", dir.Tag)
 	}
 }
+
+func TestLabelWithSetBasedSelectors(t *testing.T) {
+	s := `
+<match $labels(tier in (front, back), _owner_kind=Deployment)>
+  @type logzio
+</match>
+
+<match $labels(_annotation.example.com/team=payments)>
+  @type logzio
+</match>
+
+<match $labels(_image=redis)>
+  @type logzio
+</match>
+
+<match $labels(app=web)>
+  @type logzio
+</match>
+	`
+
+	fragment, err := fluentd.ParseString(s)
+	assert.Nil(t, err)
+
+	ctx := &ProcessorContext{
+		Namespace: "monitoring",
+		GenerationContext: &GenerationContext{
+			ReferencedBridges: map[string]bool{},
+		},
+		RecordAnnotations: []string{"example.com/team"},
+	}
+
+	fragment, err = Process(fragment, ctx, &expandLabelsMacroState{})
+	assert.Nil(t, err)
+
+	// the records are retagged with the values of the labels, then whether each selector holds
+	assert.Equal(t, 7, len(fragment))
+	record := fragment[0].Nested[0]
+	assert.Equal(t, "${record.dig('kubernetes','labels','app')&.gsub(/[.-]/, '_') || '_'}."+
+		`${((["payments"].include?((record.dig('kubernetes','annotations','example.com/team') || record.dig('kubernetes','annotations','example_com/team')).to_s))) ? '1' : '0'}.`+
+		`${((!(["redis"] & [record.dig('kubernetes','container_image').to_s, record.dig('kubernetes','container_image').to_s.sub(/@.*/, ''), record.dig('kubernetes','container_image').to_s.sub(/@.*/, '').sub(/:[^:\/]*\z/, '')]).empty?)) ? '1' : '0'}.`+
+		`${((!record.dig('kubernetes','labels','tier').nil? && ["front", "back"].include?(record.dig('kubernetes','labels','tier'))) && (["Deployment"].include?(record.dig('kubernetes','owner_kind').to_s))) ? '1' : '0'}`,
+		record.Param("kubernetes_pod_label_values"))
+
+	// the selectors are sorted by their macro
+	assert.Equal(t, "kube.monitoring.*.*._labels.*.*.*.1", fragment[3].Tag)
+	assert.Equal(t, "kube.monitoring.*.*._labels.*.1.*.*", fragment[4].Tag)
+	assert.Equal(t, "kube.monitoring.*.*._labels.*.*.1.*", fragment[5].Tag)
+	assert.Equal(t, "kube.monitoring.*.*._labels.web.*.*.*", fragment[6].Tag)
+
+	// the annotations must be added to the records to be selected
+	fragment, err = fluentd.ParseString("<match $labels(_annotation.example.com/owner)>\n  @type null\n</match>")
+	assert.Nil(t, err)
+
+	_, err = Process(fragment, ctx, &expandLabelsMacroState{})
+	assert.NotNil(t, err)
+	assert.Equal(t, "line 1: cannot select on annotation example.com/owner, the records do not carry it", err.Error())
+}
+
+func TestRubySelector(t *testing.T) {
+	selector, err := util.ParseSelector("!canary, tier notin (front), _container")
+	assert.Nil(t, err)
+
+	assert.Equal(t, "((record.dig('kubernetes','labels','canary').nil?) && "+
+		`(record.dig('kubernetes','labels','tier').nil? || !["front"].include?(record.dig('kubernetes','labels','tier'))) && `+
+		"(!record.dig('kubernetes','container_name').nil?)) ? '1' : '0'", rubySelector(selector))
+
+	// the values other than label values are escaped
+	assert.Equal(t, `"a\x27b\x7D\x23"`, rubyString("a'b}#"))
+}
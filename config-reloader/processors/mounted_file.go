@@ -7,6 +7,7 @@ import (
 	"bytes"
 	"fmt"
 	"path"
+	"sort"
 	"strings"
 
 	"github.com/vmware/kube-fluentd-operator/config-reloader/datasource"
@@ -18,7 +19,7 @@ const mountedFileSourceType = "mounted-file"
 
 // ContainerFile stores parsed info from a <source> @type mounted-file...
 type ContainerFile struct {
-	Labels      map[string]string
+	Labels      util.Selector
 	AddedLabels map[string]string
 	Path        string
 	Parse       *fluentd.Directive
@@ -39,13 +40,13 @@ func (state *mountedFileState) Prepare(input fluentd.Fragment) (fluentd.Fragment
 		if isRelevant(frag) {
 			paramLabels := frag.Param("labels")
 			if paramLabels == "" {
-				return nil, fmt.Errorf("'labels' is required when using @type %s", mountedFileSourceType)
+				return nil, frag.Errorf("'labels' is required when using @type %s", mountedFileSourceType)
 			}
 			paramLabels = util.TrimTrailingComment(paramLabels)
 
 			labels, err := util.ParseTagToLabels(fmt.Sprintf("$labels(%s)", paramLabels))
 			if err != nil {
-				return nil, err
+				return nil, fluentd.ErrorAt(frag.Pos, err)
 			}
 
 			paramAddedLabels := frag.Param("add_labels")
@@ -53,9 +54,13 @@ func (state *mountedFileState) Prepare(input fluentd.Fragment) (fluentd.Fragment
 			var addedLabels map[string]string
 			if paramAddedLabels != "" {
 				// no added labels is just fine
-				addedLabels, err = util.ParseTagToLabels(fmt.Sprintf("$labels(%s)", paramAddedLabels))
+				added, err := util.ParseTagToLabels(fmt.Sprintf("$labels(%s)", paramAddedLabels))
 				if err != nil {
-					return nil, err
+					return nil, fluentd.ErrorAt(frag.Pos, err)
+				}
+				var ok bool
+				if addedLabels, ok = added.Equalities(); !ok {
+					return nil, frag.Errorf("'add_labels' only takes label=value pairs")
 				}
 			}
 
@@ -65,14 +70,14 @@ func (state *mountedFileState) Prepare(input fluentd.Fragment) (fluentd.Fragment
 
 			paramPath := frag.Param("path")
 			if paramPath == "" {
-				return nil, fmt.Errorf("'path' is required when using @type %s", mountedFileSourceType)
+				return nil, frag.Errorf("'path' is required when using @type %s", mountedFileSourceType)
 			}
 			cf.Path = paramPath
 
 			if len(frag.Nested) == 1 {
 				cf.Parse = frag.Nested[0]
 			} else if len(frag.Nested) >= 2 {
-				return nil, fmt.Errorf("One or zero <parse> directives required when using @type %s, found %d", mountedFileSourceType, len(frag.Nested))
+				return nil, frag.Errorf("One or zero <parse> directives required when using @type %s, found %d", mountedFileSourceType, len(frag.Nested))
 			}
 
 			newFrag := state.convertToFragement(cf)
@@ -86,7 +91,7 @@ func (state *mountedFileState) Prepare(input fluentd.Fragment) (fluentd.Fragment
 }
 
 func matches(spec *ContainerFile, mini *datasource.MiniContainer) bool {
-	return util.Match(spec.Labels, mini.Labels, mini.Name)
+	return util.Match(spec.Labels, mini.Target())
 }
 
 func (state *mountedFileState) convertToFragement(cf *ContainerFile) fluentd.Fragment {
@@ -150,14 +155,20 @@ func (state *mountedFileState) makeAttachK8sMetadataDirective(tag string, mc *da
 
 	buf := &bytes.Buffer{}
 	fmt.Fprintf(buf, "record['stream']='%s'; ", cf.Path)
-	fmt.Fprintf(buf, "record['kubernetes']=%s; ", util.ToRubyMapLiteral(map[string]string{
+	kubernetes := map[string]string{
 		"container_name":  mc.Name,
 		"container_image": mc.Image,
 		"namespace_name":  state.Context.Namespace,
 		"pod_name":        mc.PodName,
 		"pod_id":          mc.PodID,
 		"host":            mc.NodeName,
-	}))
+	}
+	if mc.OwnerKind != "" {
+		// like the records of the containers, for the selectors of $labels
+		kubernetes["owner_kind"] = mc.OwnerKind
+		kubernetes["owner_name"] = mc.OwnerName
+	}
+	fmt.Fprintf(buf, "record['kubernetes']=%s; ", util.ToRubyMapLiteral(kubernetes))
 
 	fmt.Fprintf(buf, "record['docker']=%s; ", util.ToRubyMapLiteral(map[string]string{
 		"container_id": mc.ContainerID,
@@ -165,6 +176,9 @@ func (state *mountedFileState) makeAttachK8sMetadataDirective(tag string, mc *da
 
 	fmt.Fprintf(buf, "record['container_info']='%s'; ", util.Hash(mc.PodID, cf.Path))
 	fmt.Fprintf(buf, "record['kubernetes']['labels']=%s; ", util.ToRubyMapLiteral(mergeMaps(mc.Labels, cf.AddedLabels)))
+	if annotations := recordAnnotations(mc.Annotations, state.Context.RecordAnnotations); len(annotations) > 0 {
+		fmt.Fprintf(buf, "record['kubernetes']['annotations']=%s; ", annotations)
+	}
 	fmt.Fprintf(buf, "record['kubernetes']['namespace_labels']=%s", util.ToRubyMapLiteral(state.Context.NamespaceLabels))
 
 	res.Nested[0].SetParam("dummy_", fmt.Sprintf("${%s}", buf.String()))
@@ -172,6 +186,23 @@ func (state *mountedFileState) makeAttachK8sMetadataDirective(tag string, mc *da
 	return res
 }
 
+// recordAnnotations is a Ruby map of the annotations of the pod the records carry, their
+// values are not restricted like the values of the labels
+func recordAnnotations(annotations map[string]string, recorded []string) string {
+	entries := []string{}
+	for _, name := range recorded {
+		if v, ok := annotations[name]; ok {
+			entries = append(entries, fmt.Sprintf("%s=>%s", rubyString(name), rubyString(v)))
+		}
+	}
+	if len(entries) == 0 {
+		return ""
+	}
+	sort.Strings(entries)
+
+	return "{" + strings.Join(entries, ",") + "}"
+}
+
 func mergeMaps(base, more map[string]string) map[string]string {
 	res := map[string]string{}
 
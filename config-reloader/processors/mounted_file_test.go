@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/vmware/kube-fluentd-operator/config-reloader/datasource"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/util"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
@@ -108,6 +109,14 @@ func TestMountedFileCatchesMissingLabels(t *testing.T) {
 	assert.NotNil(t, err, "Must have failed, instead parsed to %+v", fragment)
 }
 
+func mustSelector(s string) util.Selector {
+	selector, err := util.ParseSelector(s)
+	if err != nil {
+		panic(err)
+	}
+	return selector
+}
+
 func TestMatches(t *testing.T) {
 	spec := &ContainerFile{
 		Path: "/var/log/https.log",
@@ -120,29 +129,29 @@ func TestMatches(t *testing.T) {
 
 	assert.True(t, matches(spec, mini))
 
-	spec.Labels = map[string]string{"_container": "hello"}
+	spec.Labels = mustSelector("_container=hello")
 	assert.False(t, matches(spec, mini))
 
-	spec.Labels = map[string]string{"_container": mini.Name}
+	spec.Labels = mustSelector("_container=" + mini.Name)
 	assert.True(t, matches(spec, mini))
 
-	spec.Labels = map[string]string{"a": "a"}
+	spec.Labels = mustSelector("a=a")
 	assert.False(t, matches(spec, mini))
 
-	spec.Labels = map[string]string{"key": "value"}
+	spec.Labels = mustSelector("key=value")
 	assert.True(t, matches(spec, mini))
 
-	spec.Labels = map[string]string{"key": "value", "_container": "container-name"}
+	spec.Labels = mustSelector("key=value, _container=container-name")
 	assert.True(t, matches(spec, mini))
 
-	spec.Labels = map[string]string{"a": "a", "key": "value", "_container": "container-name"}
+	spec.Labels = mustSelector("a=a, key=value, _container=container-name")
 	assert.False(t, matches(spec, mini))
 }
 
 func TestConvertToFragment(t *testing.T) {
 	specC1 := &ContainerFile{
 		Path:   "/var/log/redis.log",
-		Labels: map[string]string{"key": "value", "_container": "container-name"},
+		Labels: mustSelector("key=value, _container=container-name"),
 		AddedLabels: map[string]string{
 			"good": "morning",
 			"key":  "new_value", // this will not make it into the final records
@@ -164,7 +173,7 @@ func TestConvertToFragment(t *testing.T) {
 
 	specC2 := &ContainerFile{
 		Path:   "/var/log/nginx.log",
-		Labels: map[string]string{"app": "nginx"},
+		Labels: mustSelector("app=nginx"),
 	}
 	c2 := &datasource.MiniContainer{
 		PodID:   "abc-id",
@@ -185,7 +194,7 @@ func TestConvertToFragment(t *testing.T) {
 
 	specC3 := &ContainerFile{
 		Path:   "/var/log/nginx.log",
-		Labels: map[string]string{"app": "nginx-sub"},
+		Labels: mustSelector("app=nginx-sub"),
 	}
 	c3 := &datasource.MiniContainer{
 		PodID:   "abcd-id",
@@ -379,3 +388,51 @@ func TestProcessMountedFile(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, 1, len(main))
 }
+
+func TestMountedFileSetBasedSelector(t *testing.T) {
+	mount := []*datasource.Mount{
+		{
+			Path:       "/var/log",
+			VolumeName: "logs",
+		},
+	}
+	ctx := &ProcessorContext{
+		Namespace:   "monitoring",
+		KubeletRoot: "/kubelet-root",
+		MiniContainers: []*datasource.MiniContainer{
+			{PodID: "web-id", PodName: "web", Name: "main", Labels: map[string]string{"tier": "front"}, Annotations: map[string]string{"example.com/team": "it's"}, OwnerKind: "Deployment", OwnerName: "web", HostMounts: mount},
+			{PodID: "db-id", PodName: "db-0", Name: "main", Labels: map[string]string{"tier": "back"}, OwnerKind: "StatefulSet", HostMounts: mount},
+			{PodID: "job-id", PodName: "job", Name: "main", Labels: map[string]string{"tier": "front"}, OwnerKind: "Job", HostMounts: mount},
+		},
+	}
+
+	s := `
+<source>
+  @type mounted-file
+  path /var/log/app.log
+  labels tier in (front, back), _owner_kind notin (Job)
+</source>
+`
+	input, err := fluentd.ParseString(s)
+	assert.Nil(t, err)
+
+	prep, err := Prepare(input, ctx, &mountedFileState{})
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(prep))
+	assert.Equal(t, "/kubelet-root/pods/web-id/volumes/kubernetes.io~empty-dir/logs/app.log", prep[0].Param("path"))
+	assert.Equal(t, "/kubelet-root/pods/db-id/volumes/kubernetes.io~empty-dir/logs/app.log", prep[2].Param("path"))
+
+	// the owner and the annotations the records carry are added like for the container logs
+	assert.Contains(t, prep[1].String(), "'owner_kind'=>'Deployment','owner_name'=>'web'")
+	assert.NotContains(t, prep[1].String(), "annotations")
+	ctx.RecordAnnotations = []string{"example.com/team"}
+	prep, err = Prepare(input, ctx, &mountedFileState{})
+	assert.Nil(t, err)
+	assert.Contains(t, prep[1].String(), `record['kubernetes']['annotations']={"example.com/team"=>"it\x27s"}`)
+
+	// the labels added to the records are plain values
+	input, err = fluentd.ParseString("<source>\n  @type mounted-file\n  path /var/log/app.log\n  labels tier\n  add_labels team in (a, b)\n</source>")
+	assert.Nil(t, err)
+	_, err = Prepare(input, ctx, &mountedFileState{})
+	assert.Equal(t, "line 1: 'add_labels' only takes label=value pairs", err.Error())
+}
@@ -0,0 +1,32 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package processors
+
+import (
+	"github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
+)
+
+// checkPluginSchemaState rejects the unknown params and the invalid values of the plugins
+// described by the schema catalog. fluentd only warns about them and the config silently
+// does something else. It runs after the plugins of the admin namespace are expanded and
+// before the processors add params of their own.
+type checkPluginSchemaState struct {
+	BaseProcessorState
+}
+
+func (p *checkPluginSchemaState) Process(input fluentd.Fragment) (fluentd.Fragment, error) {
+	if p.Context.Schema == nil {
+		return input, nil
+	}
+
+	check := func(d *fluentd.Directive, ctx *ProcessorContext) error {
+		return ctx.Schema.Check(d)
+	}
+
+	if err := applyRecursivelyInPlace(input, p.Context, check); err != nil {
+		return nil, err
+	}
+
+	return input, nil
+}
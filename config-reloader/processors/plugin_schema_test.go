@@ -0,0 +1,58 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package processors
+
+import (
+	"testing"
+
+	"github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/schema"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckPluginSchema(t *testing.T) {
+	s := `
+<label @EVENTS>
+  <match **>
+    @type elasticsearch
+    hots es.logging.svc
+  </match>
+</label>
+`
+	fragment, err := fluentd.ParseString(s)
+	assert.Nil(t, err)
+
+	// disabled without a catalog
+	ctx := &ProcessorContext{
+		Namespace:         "monitoring",
+		GenerationContext: &GenerationContext{},
+	}
+	_, err = Process(fragment, ctx, &checkPluginSchemaState{})
+	assert.Nil(t, err)
+
+	ctx.Schema = schema.Default()
+	_, err = Process(fragment, ctx, &checkPluginSchemaState{})
+	assert.NotNil(t, err)
+	assert.Equal(t, "line 5: unknown parameter 'hots' for elasticsearch, did you mean 'host'?", err.Error())
+}
+
+func TestCheckPluginSchemaOfExpandedPlugins(t *testing.T) {
+	plugins, err := fluentd.ParseString("<plugin es>\n  @type elasticsearch\n  host es.logging.svc\n</plugin>")
+	assert.Nil(t, err)
+
+	fragment, err := fluentd.ParseString("<match **>\n  @type es\n  port 92OO\n</match>")
+	assert.Nil(t, err)
+
+	ctx := &ProcessorContext{
+		Namespace: "monitoring",
+		GenerationContext: &GenerationContext{
+			Plugins: map[string]*fluentd.Directive{"es": plugins[0]},
+		},
+		Schema: schema.Default(),
+	}
+	_, err = Process(fragment, ctx, &expandPluginsState{}, &checkPluginSchemaState{})
+	assert.NotNil(t, err)
+	assert.Equal(t, "line 3: invalid value of 'port' for elasticsearch: '92OO' is not a valid integer", err.Error())
+}
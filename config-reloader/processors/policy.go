@@ -0,0 +1,124 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package processors
+
+import (
+	"fmt"
+	"path"
+
+	kfo "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/apis/logs.vdp.vmware.com/v1beta1"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/util"
+)
+
+// enforcePolicyState refuses the configs that break a FluentdPolicy of the namespace.
+// The policies add to the restrictions of fixDestinations, they cannot lift them.
+type enforcePolicyState struct {
+	BaseProcessorState
+}
+
+func (p *enforcePolicyState) Process(input fluentd.Fragment) (fluentd.Fragment, error) {
+	for _, policy := range p.Context.Policies {
+		if err := checkPolicy(policy, input, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	return input, nil
+}
+
+// checkPolicy checks the directives and their sections, pluginType is the @type of
+// the plugin the sections belong to
+func checkPolicy(policy *kfo.FluentdPolicy, directives fluentd.Fragment, pluginType string) error {
+	for _, d := range directives {
+		typ := pluginType
+		if isPlugin(d) {
+			typ = d.Type()
+			if err := checkPluginRules(policy, d); err != nil {
+				return err
+			}
+		}
+
+		if err := checkParamRules(policy, d, typ); err != nil {
+			return err
+		}
+
+		if err := checkPolicy(policy, d.Nested, typ); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func isPlugin(d *fluentd.Directive) bool {
+	switch d.Name {
+	case "match", "filter", "store", "secondary", "source":
+		return true
+	}
+	return false
+}
+
+// ruleApplies tells if a plugin rule for directive checks d. The <store> of copy and
+// <secondary> are outputs too, a rule for <match> must not be bypassed with them.
+func ruleApplies(directive string, d *fluentd.Directive) bool {
+	switch {
+	case directive == "" || directive == d.Name:
+		return true
+	case directive == "match":
+		return d.Name == "store" || d.Name == "secondary"
+	}
+	return false
+}
+
+func checkPluginRules(policy *kfo.FluentdPolicy, d *fluentd.Directive) error {
+	for _, rule := range policy.Spec.Plugins {
+		if !ruleApplies(rule.Directive, d) {
+			continue
+		}
+
+		if contains(rule.Denied, d.Type()) ||
+			(len(rule.Allowed) > 0 && !contains(rule.Allowed, d.Type())) {
+			return d.Errorf("FluentdPolicy %s does not allow '@type %s' in <%s>", policy.Name, d.Type(), d.Name)
+		}
+	}
+
+	return nil
+}
+
+func checkParamRules(policy *kfo.FluentdPolicy, d *fluentd.Directive, pluginType string) error {
+	for _, rule := range policy.Spec.Params {
+		if len(rule.Types) > 0 && !contains(rule.Types, pluginType) {
+			continue
+		}
+
+		if _, ok := d.Params[rule.Name]; !ok {
+			continue
+		}
+
+		value := d.Param(rule.Name)
+		// the value of a Secret is only known to fluentd, it cannot be checked
+		_, _, isSecret, _ := util.ParseSecretMacro(value)
+		if isSecret || matchesAny(rule.Denied, value) ||
+			(len(rule.Allowed) > 0 && !matchesAny(rule.Allowed, value)) {
+			pos := d.Pos
+			if p := d.Params[rule.Name]; p != nil {
+				pos = p.Pos
+			}
+			return fluentd.ErrorAt(pos, fmt.Errorf("FluentdPolicy %s does not allow '%s %s' in <%s> of '@type %s'", policy.Name, rule.Name, value, d.Name, pluginType))
+		}
+	}
+
+	return nil
+}
+
+// matchesAny tells if the value matches one of the glob patterns
+func matchesAny(patterns []string, value string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, value); ok {
+			return true
+		}
+	}
+	return false
+}
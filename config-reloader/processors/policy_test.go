@@ -0,0 +1,204 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package processors
+
+import (
+	"testing"
+
+	kfo "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/apis/logs.vdp.vmware.com/v1beta1"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func makePolicyContext(spec kfo.FluentdPolicySpec) *ProcessorContext {
+	return &ProcessorContext{
+		Namespace: "monitoring",
+		Policies: []*kfo.FluentdPolicy{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "tenants"},
+				Spec:       spec,
+			},
+		},
+	}
+}
+
+func TestPolicyPluginRules(t *testing.T) {
+	spec := kfo.FluentdPolicySpec{
+		Plugins: []kfo.PluginRule{
+			{Directive: "match", Allowed: []string{"copy", "elasticsearch"}},
+			{Denied: []string{"exec"}},
+		},
+	}
+
+	s := `
+<match **>
+  @type copy
+  <store>
+    @type elasticsearch
+  </store>
+</match>
+`
+	fragment, err := fluentd.ParseString(s)
+	assert.Nil(t, err)
+
+	_, err = Process(fragment, makePolicyContext(spec), &enforcePolicyState{})
+	assert.Nil(t, err)
+
+	s = `
+<match **>
+  @type logzio_buffered
+</match>
+`
+	fragment, err = fluentd.ParseString(s)
+	assert.Nil(t, err)
+
+	_, err = Process(fragment, makePolicyContext(spec), &enforcePolicyState{})
+	assert.NotNil(t, err)
+	assert.Equal(t, "line 2: FluentdPolicy tenants does not allow '@type logzio_buffered' in <match>", err.Error())
+
+	s = `
+<match **>
+  @type copy
+  <store>
+    @type exec
+  </store>
+</match>
+`
+	fragment, err = fluentd.ParseString(s)
+	assert.Nil(t, err)
+
+	_, err = Process(fragment, makePolicyContext(spec), &enforcePolicyState{})
+	assert.NotNil(t, err)
+	assert.Equal(t, "line 4: FluentdPolicy tenants does not allow '@type exec' in <store>", err.Error())
+}
+
+func TestPolicyMatchRulesCheckStores(t *testing.T) {
+	spec := kfo.FluentdPolicySpec{
+		Plugins: []kfo.PluginRule{
+			{Directive: "match", Allowed: []string{"copy", "elasticsearch"}},
+		},
+	}
+
+	s := `
+<match **>
+  @type copy
+  <store>
+    @type logzio_buffered
+  </store>
+</match>
+`
+	fragment, err := fluentd.ParseString(s)
+	assert.Nil(t, err)
+
+	_, err = Process(fragment, makePolicyContext(spec), &enforcePolicyState{})
+	assert.NotNil(t, err)
+	assert.Equal(t, "line 4: FluentdPolicy tenants does not allow '@type logzio_buffered' in <store>", err.Error())
+
+	s = `
+<match **>
+  @type elasticsearch
+  <secondary>
+    @type logzio_buffered
+  </secondary>
+</match>
+`
+	fragment, err = fluentd.ParseString(s)
+	assert.Nil(t, err)
+
+	_, err = Process(fragment, makePolicyContext(spec), &enforcePolicyState{})
+	assert.NotNil(t, err)
+	assert.Equal(t, "line 4: FluentdPolicy tenants does not allow '@type logzio_buffered' in <secondary>", err.Error())
+}
+
+func TestPolicyParamRules(t *testing.T) {
+	spec := kfo.FluentdPolicySpec{
+		Params: []kfo.ParamRule{
+			{Name: "host", Types: []string{"elasticsearch"}, Allowed: []string{"*.logging.svc"}},
+			{Name: "path", Denied: []string{"/etc/*"}},
+		},
+	}
+
+	s := `
+<match **>
+  @type elasticsearch
+  host es.logging.svc
+</match>
+
+<match other.**>
+  @type syslog
+  host example.com
+</match>
+`
+	fragment, err := fluentd.ParseString(s)
+	assert.Nil(t, err)
+
+	_, err = Process(fragment, makePolicyContext(spec), &enforcePolicyState{})
+	assert.Nil(t, err)
+
+	s = `
+<match **>
+  @type elasticsearch
+  host example.com
+</match>
+`
+	fragment, err = fluentd.ParseString(s)
+	assert.Nil(t, err)
+
+	_, err = Process(fragment, makePolicyContext(spec), &enforcePolicyState{})
+	assert.NotNil(t, err)
+	assert.Equal(t, "line 4: FluentdPolicy tenants does not allow 'host example.com' in <match> of '@type elasticsearch'", err.Error())
+
+	// the sections inherit the type of their plugin
+	s = `
+<match **>
+  @type s3
+  <buffer>
+    path /etc/passwd
+  </buffer>
+</match>
+`
+	fragment, err = fluentd.ParseString(s)
+	assert.Nil(t, err)
+
+	_, err = Process(fragment, makePolicyContext(spec), &enforcePolicyState{})
+	assert.NotNil(t, err)
+	assert.Equal(t, "line 5: FluentdPolicy tenants does not allow 'path /etc/passwd' in <buffer> of '@type s3'", err.Error())
+}
+
+func TestPolicyParamRulesRejectSecrets(t *testing.T) {
+	spec := kfo.FluentdPolicySpec{
+		Params: []kfo.ParamRule{
+			{Name: "path", Denied: []string{"/etc/*"}},
+		},
+	}
+
+	// the policy runs before $secret is expanded, the value cannot be checked
+	s := `
+<match **>
+  @type file
+  path $secret(paths, path)
+</match>
+`
+	fragment, err := fluentd.ParseString(s)
+	assert.Nil(t, err)
+
+	_, err = Process(fragment, makePolicyContext(spec), &enforcePolicyState{})
+	assert.NotNil(t, err)
+	assert.Equal(t, "line 4: FluentdPolicy tenants does not allow 'path $secret(paths, path)' in <match> of '@type file'", err.Error())
+
+	// the params without rule can still use it
+	s = `
+<match **>
+  @type file
+  password $secret(paths, path)
+</match>
+`
+	fragment, err = fluentd.ParseString(s)
+	assert.Nil(t, err)
+
+	_, err = Process(fragment, makePolicyContext(spec), &enforcePolicyState{})
+	assert.Nil(t, err)
+}
@@ -8,7 +8,9 @@ import (
 	"fmt"
 
 	"github.com/vmware/kube-fluentd-operator/config-reloader/datasource"
+	kfo "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/apis/logs.vdp.vmware.com/v1beta1"
 	"github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/schema"
 )
 
 const (
@@ -23,6 +25,8 @@ type GenerationContext struct {
 	ReferencedBridges map[string]bool
 	NeedsProcessing   bool
 	Plugins           map[string]*fluentd.Directive
+	// Quotas by namespace, the key "" holds the quota of every other namespace
+	Quotas map[string]*fluentd.Directive
 }
 
 func (g *GenerationContext) augmentTag(d *fluentd.Directive) {
@@ -46,6 +50,26 @@ type ProcessorContext struct {
 	BufferMountFolder string
 	GenerationContext *GenerationContext
 	AllowTagExpansion bool
+	// Policies are the FluentdPolicies the config must follow
+	Policies []*kfo.FluentdPolicy
+	// AllowSecrets lets the config use $secret
+	AllowSecrets bool
+	// AllowEmbeddedRuby lets the config use "#{...}" without a FluentdPolicy allowing it
+	AllowEmbeddedRuby bool
+	// RecordAnnotations are the pod annotations the records carry, $labels can select on them
+	RecordAnnotations []string
+	// Secrets holds the data of the Secrets $secret refers to, by name
+	Secrets map[string]map[string][]byte
+	// SecretsDir is where fluentd reads the secret files from
+	SecretsDir string
+	// SecretNameKey keys the digest in the names of the secret files
+	SecretNameKey []byte
+	// SecretFiles are the files the processed config reads, by name in SecretsDir
+	SecretFiles map[string][]byte
+	// Trace records the output of every processor when not nil
+	Trace *Trace
+	// Schema checks the params of the plugins it knows, nil disables the check
+	Schema *schema.Catalog
 }
 
 type BaseProcessorState struct {
@@ -106,11 +130,13 @@ func copy(dir *fluentd.Directive, parent *fluentd.Fragment) *fluentd.Directive {
 	return clone
 }
 
+// applyRecursivelyInPlace calls back on every directive, the error of the callback is
+// reported at the position of the directive
 func applyRecursivelyInPlace(directives fluentd.Fragment, ctx *ProcessorContext, callback func(*fluentd.Directive, *ProcessorContext) error) error {
 	for _, d := range directives {
 		err := callback(d, ctx)
 		if err != nil {
-			return err
+			return fluentd.ErrorAt(d.Pos, err)
 		}
 	}
 
@@ -133,9 +159,11 @@ func Process(input fluentd.Fragment, ctx *ProcessorContext, processors ...Fragme
 	res := input
 	var err error
 
+	ctx.Trace.record(PhaseProcess, "input", res, nil)
 	for _, proc := range processors {
 		proc.SetContext(ctx)
 		res, err = proc.Process(res)
+		ctx.Trace.record(PhaseProcess, ProcessorName(proc), res, err)
 		if err != nil {
 			return nil, err
 		}
@@ -168,10 +196,12 @@ func Prepare(input fluentd.Fragment, ctx *ProcessorContext, processors ...Fragme
 
 	res := fluentd.Fragment{}
 
+	ctx.Trace.record(PhasePrepare, "input", res, nil)
 	for _, proc := range processors {
 		proc.SetContext(ctx)
 		prepDirectives, err := proc.Prepare(input)
 		res = append(res, prepDirectives...)
+		ctx.Trace.record(PhasePrepare, ProcessorName(proc), res, err)
 		if err != nil {
 			return nil, err
 		}
@@ -193,8 +223,12 @@ func augmentTag(orig string) string {
 // of processors but be aware of dependencies between processors (order matters).
 func DefaultProcessors() []FragmentProcessor {
 	return []FragmentProcessor{
+		&rejectEmbeddedRubyState{},
 		&expandPluginsState{},
+		&checkPluginSchemaState{},
 		&expandTagsState{},
+		&enforcePolicyState{},
+		&enforceQuotaState{},
 		&expandThisnsMacroState{},
 		&fixDestinations{},
 		&expandLabelsMacroState{},
@@ -203,5 +237,6 @@ func DefaultProcessors() []FragmentProcessor {
 		&mountedFileState{},
 		&shareLogsState{},
 		&detectExceptionsState{},
+		&expandSecretsState{},
 	}
 }
@@ -0,0 +1,261 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package processors
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
+)
+
+const (
+	dirQuota = "quota"
+
+	paramMaxOutputs        = "max_outputs"
+	paramMaxTotalLimitSize = "max_total_limit_size"
+	paramThrottleLimit     = "throttle_limit"
+	paramThrottlePeriod    = "throttle_period"
+	paramThrottleGroupKey  = "throttle_group_key"
+
+	paramTotalLimitSize = "total_limit_size"
+
+	defaultThrottlePeriod   = "60"
+	defaultThrottleGroupKey = "kubernetes.container_name"
+)
+
+// ExtractQuotas looks at the top-level directives in the admin namespace, deletes all <quota>
+// and stores them under GenerationContext.Quotas. A <quota> without a tag applies to every
+// namespace, <quota ns1 ns2> overrides it for the listed namespaces
+func ExtractQuotas(g *GenerationContext, input fluentd.Fragment) fluentd.Fragment {
+	quotas := map[string]*fluentd.Directive{}
+	res := fluentd.Fragment{}
+
+	for _, dir := range input {
+		if dir.Name != dirQuota {
+			res = append(res, dir)
+			continue
+		}
+
+		if dir.Tag == "" {
+			quotas[""] = dir
+			continue
+		}
+		for _, ns := range strings.Fields(dir.Tag) {
+			quotas[ns] = dir
+		}
+	}
+
+	g.Quotas = quotas
+
+	return res
+}
+
+// quotaFor returns the quota of the namespace, nil if there is none
+func (g *GenerationContext) quotaFor(namespace string) *fluentd.Directive {
+	if q, ok := g.Quotas[namespace]; ok {
+		return q
+	}
+
+	return g.Quotas[""]
+}
+
+type enforceQuotaState struct {
+	BaseProcessorState
+}
+
+func (p *enforceQuotaState) Process(input fluentd.Fragment) (fluentd.Fragment, error) {
+	quota := p.Context.GenerationContext.quotaFor(p.Context.Namespace)
+	if quota == nil {
+		return input, nil
+	}
+
+	if err := checkMaxOutputs(quota, input); err != nil {
+		return nil, err
+	}
+
+	if err := limitBufferSizes(quota, input, p.Context); err != nil {
+		return nil, err
+	}
+
+	throttle, err := makeThrottleFilter(quota)
+	if err != nil {
+		return nil, err
+	}
+	if throttle == nil {
+		return input, nil
+	}
+
+	// the filter is tagged ** so that the $thisns processor routes it before the namespace outputs
+	return append(fluentd.Fragment{throttle}, input...), nil
+}
+
+func checkMaxOutputs(quota *fluentd.Directive, input fluentd.Fragment) error {
+	maxOutputs, err := quotaInt(quota, paramMaxOutputs)
+	if err != nil || maxOutputs == 0 {
+		return err
+	}
+
+	count := 0
+	countOutputs := func(d *fluentd.Directive, ctx *ProcessorContext) error {
+		if isOutput(d) {
+			count++
+		}
+		return nil
+	}
+	applyRecursivelyInPlace(input, nil, countOutputs)
+
+	if count > maxOutputs {
+		return fmt.Errorf("the config defines %d outputs, the quota allows %d", count, maxOutputs)
+	}
+	return nil
+}
+
+// isOutput tells if d sends events out of fluentd. Routing plugins are not counted.
+func isOutput(d *fluentd.Directive) bool {
+	if d.Name != "match" && d.Name != "store" {
+		return false
+	}
+
+	switch d.Type() {
+	case "copy", "relabel", "null", "share":
+		return false
+	}
+	return true
+}
+
+func limitBufferSizes(quota *fluentd.Directive, input fluentd.Fragment, ctx *ProcessorContext) error {
+	maxSizeParam := quota.Param(paramMaxTotalLimitSize)
+	if maxSizeParam == "" {
+		return nil
+	}
+
+	maxSize, err := parseSize(maxSizeParam)
+	if err != nil {
+		return fmt.Errorf("invalid %s in the quota of the admin namespace: %w", paramMaxTotalLimitSize, err)
+	}
+
+	limit := func(d *fluentd.Directive, ctx *ProcessorContext) error {
+		if d.Name != "buffer" {
+			return nil
+		}
+
+		sizeParam := d.Param(paramTotalLimitSize)
+		if sizeParam == "" {
+			d.SetParam(paramTotalLimitSize, maxSizeParam)
+			return nil
+		}
+
+		size, err := parseSize(sizeParam)
+		if err != nil {
+			return fmt.Errorf("invalid %s in <buffer>: %w", paramTotalLimitSize, err)
+		}
+		if size > maxSize {
+			return fmt.Errorf("%s %s of <buffer> exceeds the quota of %s", paramTotalLimitSize, sizeParam, maxSizeParam)
+		}
+		return nil
+	}
+
+	if err := applyRecursivelyInPlace(input, ctx, limit); err != nil {
+		return err
+	}
+
+	// without a <buffer> the output would use the default total_limit_size of its plugin
+	addBuffer := func(d *fluentd.Directive, ctx *ProcessorContext) error {
+		if !isOutput(d) {
+			return nil
+		}
+		for _, nested := range d.Nested {
+			if nested.Name == "buffer" {
+				return nil
+			}
+		}
+
+		buffer := &fluentd.Directive{
+			Name:   "buffer",
+			Params: fluentd.ParamsFromKV(paramTotalLimitSize, maxSizeParam),
+		}
+		d.Nested = append(d.Nested, buffer)
+		return nil
+	}
+
+	return applyRecursivelyInPlace(input, ctx, addBuffer)
+}
+
+// makeThrottleFilter returns a throttle filter for the namespace, nil if the quota does not limit the rate
+func makeThrottleFilter(quota *fluentd.Directive) (*fluentd.Directive, error) {
+	limit, err := quotaInt(quota, paramThrottleLimit)
+	if err != nil || limit == 0 {
+		return nil, err
+	}
+
+	period := quota.Param(paramThrottlePeriod)
+	if period == "" {
+		period = defaultThrottlePeriod
+	}
+	if _, err := strconv.Atoi(period); err != nil {
+		return nil, fmt.Errorf("invalid %s in the quota of the admin namespace: %s", paramThrottlePeriod, period)
+	}
+
+	groupKey := quota.Param(paramThrottleGroupKey)
+	if groupKey == "" {
+		groupKey = defaultThrottleGroupKey
+	}
+
+	// https://github.com/rubrikinc/fluent-plugin-throttle
+	throttle := &fluentd.Directive{
+		Name:   "filter",
+		Tag:    "**",
+		Params: fluentd.ParamsFromKV("@type", "throttle"),
+	}
+	throttle.SetParam("group_key", groupKey)
+	throttle.SetParam("group_bucket_period_s", period)
+	throttle.SetParam("group_bucket_limit", strconv.Itoa(limit))
+
+	return throttle, nil
+}
+
+func quotaInt(quota *fluentd.Directive, name string) (int, error) {
+	s := quota.Param(name)
+	if s == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s in the quota of the admin namespace: %s", name, s)
+	}
+	return n, nil
+}
+
+// parseSize parses a fluentd size like 512, 8k, 64m or 1g
+func parseSize(size string) (int64, error) {
+	s := strings.ToLower(strings.TrimSpace(size))
+	if s == "" {
+		return 0, errors.New("empty size")
+	}
+
+	multiplier := int64(1)
+	switch s[len(s)-1] {
+	case 'k':
+		multiplier = 1 << 10
+	case 'm':
+		multiplier = 1 << 20
+	case 'g':
+		multiplier = 1 << 30
+	case 't':
+		multiplier = 1 << 40
+	}
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("bad size: %s", size)
+	}
+	return n * multiplier, nil
+}
@@ -0,0 +1,176 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package processors
+
+import (
+	"testing"
+
+	"github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const quotaAdminConfig = `
+<match systemd.**>
+  @type null
+</match>
+
+<quota>
+  max_outputs 2
+  max_total_limit_size 1g
+  throttle_limit 1000
+</quota>
+
+<quota team-a team-b>
+  max_outputs 5
+</quota>
+`
+
+func makeQuotaContext(t *testing.T, namespace string) *ProcessorContext {
+	admin, err := fluentd.ParseString(quotaAdminConfig)
+	assert.Nil(t, err)
+
+	g := &GenerationContext{}
+	remaining := ExtractQuotas(g, admin)
+	assert.Equal(t, 1, len(remaining))
+	assert.Equal(t, 3, len(g.Quotas))
+
+	return &ProcessorContext{
+		Namespace:         namespace,
+		GenerationContext: g,
+	}
+}
+
+func TestQuotaThrottlesAndLimitsBuffers(t *testing.T) {
+	s := `
+<match **>
+  @type copy
+  <store>
+    @type elasticsearch
+    <buffer>
+      @type file
+      path /var/log/es.buffer
+    </buffer>
+  </store>
+  <store>
+    @type logzio_buffered
+    <buffer>
+      total_limit_size 512m
+    </buffer>
+  </store>
+</match>
+`
+	fragment, err := fluentd.ParseString(s)
+	assert.Nil(t, err)
+
+	ctx := makeQuotaContext(t, "monitoring")
+	processed, err := Process(fragment, ctx, &enforceQuotaState{}, &expandThisnsMacroState{})
+	assert.Nil(t, err)
+
+	assert.Equal(t, 2, len(processed))
+	throttle := processed[0]
+	assert.Equal(t, "filter", throttle.Name)
+	assert.Equal(t, "kube.monitoring.**", throttle.Tag)
+	assert.Equal(t, "throttle", throttle.Type())
+	assert.Equal(t, "1000", throttle.Param("group_bucket_limit"))
+	assert.Equal(t, "60", throttle.Param("group_bucket_period_s"))
+	assert.Equal(t, "kubernetes.container_name", throttle.Param("group_key"))
+
+	stores := processed[1].Nested
+	assert.Equal(t, "1g", stores[0].Nested[0].Param("total_limit_size"))
+	assert.Equal(t, "512m", stores[1].Nested[0].Param("total_limit_size"))
+}
+
+func TestQuotaRejectsTooManyOutputs(t *testing.T) {
+	s := `
+<match **>
+  @type copy
+  <store>
+    @type elasticsearch
+  </store>
+  <store>
+    @type logzio_buffered
+  </store>
+  <store>
+    @type loggly
+  </store>
+</match>
+`
+	fragment, err := fluentd.ParseString(s)
+	assert.Nil(t, err)
+
+	_, err = Process(fragment.Clone(), makeQuotaContext(t, "monitoring"), &enforceQuotaState{})
+	assert.NotNil(t, err)
+	assert.Equal(t, "the config defines 3 outputs, the quota allows 2", err.Error())
+
+	// the quota of the namespace overrides the default one
+	processed, err := Process(fragment.Clone(), makeQuotaContext(t, "team-a"), &enforceQuotaState{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(processed))
+}
+
+func TestQuotaAddsMissingBuffers(t *testing.T) {
+	s := `
+<match **>
+  @type copy
+  <store>
+    @type elasticsearch
+  </store>
+  <store>
+    @type share
+    with_namespace other
+  </store>
+</match>
+`
+	fragment, err := fluentd.ParseString(s)
+	assert.Nil(t, err)
+
+	processed, err := Process(fragment, makeQuotaContext(t, "monitoring"), &enforceQuotaState{})
+	assert.Nil(t, err)
+
+	// the copy itself does not get a buffer
+	match := processed[1]
+	assert.Equal(t, 2, len(match.Nested))
+
+	es := match.Nested[0]
+	assert.Equal(t, 1, len(es.Nested))
+	assert.Equal(t, "buffer", es.Nested[0].Name)
+	assert.Equal(t, "1g", es.Nested[0].Param("total_limit_size"))
+
+	// share is not an output, it becomes a relabel store
+	assert.Equal(t, 0, len(match.Nested[1].Nested))
+}
+
+func TestQuotaRejectsLargeBuffers(t *testing.T) {
+	s := `
+<match **>
+  @type elasticsearch
+  <buffer>
+    total_limit_size 2g
+  </buffer>
+</match>
+`
+	fragment, err := fluentd.ParseString(s)
+	assert.Nil(t, err)
+
+	_, err = Process(fragment, makeQuotaContext(t, "monitoring"), &enforceQuotaState{})
+	assert.NotNil(t, err)
+	assert.Equal(t, "line 4: total_limit_size 2g of <buffer> exceeds the quota of 1g", err.Error())
+}
+
+func TestParseSize(t *testing.T) {
+	for s, expected := range map[string]int64{
+		"512": 512,
+		"8k":  8 << 10,
+		"64M": 64 << 20,
+		"1g":  1 << 30,
+	} {
+		size, err := parseSize(s)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, size, s)
+	}
+
+	_, err := parseSize("1.5gb")
+	assert.NotNil(t, err)
+}
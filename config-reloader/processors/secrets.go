@@ -0,0 +1,89 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package processors
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/util"
+)
+
+// expandSecretsState replaces $secret(name, key) with a file read by fluentd when it loads
+// the config, so that the secret is never written to the ns-*.conf files. The files are
+// collected in ProcessorContext.SecretFiles for the generator to write.
+type expandSecretsState struct {
+	BaseProcessorState
+}
+
+func (p *expandSecretsState) Process(input fluentd.Fragment) (fluentd.Fragment, error) {
+	expand := func(d *fluentd.Directive, ctx *ProcessorContext) error {
+		for _, param := range d.Params {
+			name, key, ok, err := util.ParseSecretMacro(param.Value)
+			if !ok {
+				continue
+			}
+			if err != nil {
+				return fluentd.ErrorAt(param.Pos, err)
+			}
+			if !ctx.AllowSecrets {
+				return fluentd.ErrorAt(param.Pos, errors.New("reading Secrets with $secret is disabled"))
+			}
+
+			data, ok := ctx.Secrets[name]
+			if !ok {
+				return fluentd.ErrorAt(param.Pos, fmt.Errorf("secret %s not found in namespace %s", name, ctx.Namespace))
+			}
+			value, ok := data[key]
+			if !ok {
+				return fluentd.ErrorAt(param.Pos, fmt.Errorf("key %s not found in secret %s", key, name))
+			}
+
+			if len(ctx.SecretNameKey) == 0 {
+				return fluentd.ErrorAt(param.Pos, errors.New("no key to name the secret files"))
+			}
+
+			filename := secretFileName(ctx.SecretNameKey, ctx.Namespace, name, key, value)
+			if ctx.SecretFiles == nil {
+				ctx.SecretFiles = map[string][]byte{}
+			}
+			ctx.SecretFiles[filename] = value
+
+			param.Value = fmt.Sprintf(`"#{File.read('%s').chomp}"`, filepath.Join(ctx.SecretsDir, filename))
+		}
+		return nil
+	}
+
+	if err := applyRecursivelyInPlace(input, p.Context, expand); err != nil {
+		return nil, err
+	}
+
+	return input, nil
+}
+
+// NewSecretNameKey returns a random key for ProcessorContext.SecretNameKey
+func NewSecretNameKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// secretFileName depends on the value so that the config changes, and fluentd reloads,
+// when the secret changes. The names end up in the world-readable ns-*.conf files so the
+// digest is keyed, the values cannot be guessed offline.
+func secretFileName(nameKey []byte, namespace string, name string, key string, value []byte) string {
+	mac := hmac.New(sha256.New, nameKey)
+	mac.Write([]byte(namespace + "/" + name + "/" + key + "\x00"))
+	mac.Write(value)
+	digest := hex.EncodeToString(mac.Sum(nil))
+	return fmt.Sprintf("%s-%s-%s-%s", namespace, name, key, digest[:16])
+}
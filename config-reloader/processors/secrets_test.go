@@ -0,0 +1,100 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package processors
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/util"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandSecrets(t *testing.T) {
+	s := `
+<match **>
+  @type elasticsearch
+  user elastic
+  password $secret(es-creds, password)
+</match>
+`
+	fragment, err := fluentd.ParseString(s)
+	assert.Nil(t, err)
+
+	ctx := &ProcessorContext{
+		Namespace:     "monitoring",
+		SecretsDir:    "/fluentd/etc/secrets",
+		AllowSecrets:  true,
+		SecretNameKey: []byte("key"),
+		Secrets: map[string]map[string][]byte{
+			"es-creds": {"password": []byte("hunter2")},
+		},
+	}
+
+	processed, err := Process(fragment, ctx, &expandSecretsState{})
+	assert.Nil(t, err)
+
+	filename := secretFileName([]byte("key"), "monitoring", "es-creds", "password", []byte("hunter2"))
+	assert.True(t, strings.HasPrefix(filename, "monitoring-es-creds-password-"))
+	assert.Equal(t, []byte("hunter2"), ctx.SecretFiles[filename])
+
+	value := processed[0].ParamVerbatim("password")
+	assert.Equal(t, `"#{File.read('/fluentd/etc/secrets/`+filename+`').chomp}"`, value)
+	assert.NotContains(t, processed.String(), "hunter2")
+
+	// a new value is a new file
+	assert.NotEqual(t, filename, secretFileName([]byte("key"), "monitoring", "es-creds", "password", []byte("hunter3")))
+	assert.Equal(t, filename, secretFileName([]byte("key"), "monitoring", "es-creds", "password", []byte("hunter2")))
+
+	// the name cannot be computed without the key
+	assert.NotEqual(t, filename, secretFileName([]byte("other"), "monitoring", "es-creds", "password", []byte("hunter2")))
+	assert.NotContains(t, filename, util.Hash("monitoring/es-creds/password", "hunter2")[:16])
+}
+
+func TestExpandSecretsErrors(t *testing.T) {
+	tests := map[string]string{
+		"password $secret(missing, password)":  "line 3: secret missing not found in namespace monitoring",
+		"password $secret(es-creds, nokey)":    "line 3: key nokey not found in secret es-creds",
+		"password $secret(es-creds password)":  "line 3: bad $secret macro use: $secret(es-creds password)",
+		"password $secret(Bad_Name, password)": "line 3: bad $secret macro use: $secret(Bad_Name, password)",
+	}
+
+	for param, msg := range tests {
+		fragment, err := fluentd.ParseString("<match **>\n  @type null\n  " + param + "\n</match>")
+		assert.Nil(t, err)
+
+		ctx := &ProcessorContext{
+			Namespace:     "monitoring",
+			AllowSecrets:  true,
+			SecretNameKey: []byte("key"),
+			Secrets: map[string]map[string][]byte{
+				"es-creds": {"password": []byte("hunter2")},
+			},
+		}
+
+		_, err = Process(fragment, ctx, &expandSecretsState{})
+		assert.NotNil(t, err, param)
+		if err != nil {
+			assert.Equal(t, msg, err.Error(), param)
+		}
+	}
+}
+
+func TestExpandSecretsDisabled(t *testing.T) {
+	fragment, err := fluentd.ParseString("<match **>\n  @type null\n  password $secret(es-creds, password)\n</match>")
+	assert.Nil(t, err)
+
+	ctx := &ProcessorContext{
+		Namespace: "monitoring",
+		Secrets: map[string]map[string][]byte{
+			"es-creds": {"password": []byte("hunter2")},
+		},
+	}
+
+	_, err = Process(fragment, ctx, &expandSecretsState{})
+	assert.NotNil(t, err)
+	assert.Equal(t, "line 3: reading Secrets with $secret is disabled", err.Error())
+}
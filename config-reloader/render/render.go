@@ -0,0 +1,140 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package render
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/vmware/kube-fluentd-operator/config-reloader/config"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/datasource"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/generator"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/util"
+)
+
+// statusCollector records the errors reported by the generator
+type statusCollector struct {
+	errors map[string]string
+}
+
+func (s *statusCollector) UpdateStatus(ctx context.Context, namespace string, status string) {
+	if status == "" {
+		delete(s.errors, namespace)
+		return
+	}
+
+	s.errors[namespace] = status
+}
+
+// Run generates the fluentd config for the <namespace>.conf files in cfg.RenderDir exactly
+// like the in-cluster generator does. The files are written to cfg.RenderOutputDir or,
+// if empty, printed to out. An error is returned if any namespace config is invalid.
+func Run(ctx context.Context, cfg *config.Config, out io.Writer) error {
+	outputDir, err := os.MkdirTemp("", "kfo-render")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(outputDir)
+
+	ds, err := datasource.NewFileSystemDatasourceWithManifests(ctx, cfg.RenderDir, outputDir, cfg.RenderManifests)
+	if err != nil {
+		return err
+	}
+
+	namespaces, err := ds.GetNamespaces(ctx)
+	if err != nil {
+		return err
+	}
+
+	status := &statusCollector{
+		errors: map[string]string{},
+	}
+
+	// the key of the secret file names goes with the files, in the temporary dir
+	genCfg := *cfg
+	genCfg.OutputDir = outputDir
+	gen, err := generator.New(ctx, &genCfg)
+	if err != nil {
+		return err
+	}
+	gen.SetStatusUpdater(ctx, status)
+	gen.SetModel(namespaces)
+	if _, err := gen.RenderToDisk(ctx, outputDir); err != nil {
+		return err
+	}
+
+	if cfg.RenderOutputDir == "" {
+		err = printFiles(out, outputDir)
+	} else {
+		err = copyFiles(cfg.RenderOutputDir, outputDir)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(status.errors) == 0 {
+		return nil
+	}
+
+	failed := make([]string, 0, len(status.errors))
+	for ns, msg := range status.errors {
+		failed = append(failed, fmt.Sprintf("namespace %s: %s", ns, msg))
+	}
+	sort.Strings(failed)
+
+	return fmt.Errorf("invalid config in %d namespace(s):\n%s", len(failed), strings.Join(failed, "\n"))
+}
+
+// printFiles writes every generated file to out, preceded by its name
+func printFiles(out io.Writer, dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.conf"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	for _, f := range files {
+		contents, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprintf(out, "# ==> %s <==\n%s\n", filepath.Base(f), contents); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyFiles writes every generated file to dir as a regular file, without the
+// symlinks of the in-cluster output dir
+func copyFiles(dir string, generatedDir string) error {
+	if err := util.EnsureDirExists(dir); err != nil {
+		return err
+	}
+
+	files, err := filepath.Glob(filepath.Join(generatedDir, "*.conf"))
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		contents, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+
+		if err := util.WriteStringToFile(filepath.Join(dir, filepath.Base(f)), string(contents)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
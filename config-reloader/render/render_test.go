@@ -0,0 +1,159 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package render
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/config"
+)
+
+const testManifest = `apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Namespace
+  metadata:
+    name: demo
+    labels:
+      team: logging
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: welcome
+  namespace: demo
+  uid: 723dd34a-4ac0-11e8-8a81-0a930dd884b0
+  labels:
+    app: welcome
+spec:
+  containers:
+  - name: test-container
+    image: busybox
+    volumeMounts:
+    - name: logs
+      mountPath: /var/log
+  volumes:
+  - name: logs
+    emptyDir: {}
+`
+
+const testConfig = `<source>
+  @type mounted-file
+  path /var/log/welcome.log
+  labels app=welcome
+</source>
+
+<match **>
+  @type null
+</match>
+`
+
+func makeTestConfig(t *testing.T) *config.Config {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "demo.conf"), []byte(testConfig), 0o644))
+
+	manifests := filepath.Join(t.TempDir(), "pods.yaml")
+	assert.Nil(t, os.WriteFile(manifests, []byte(testManifest), 0o644))
+
+	return &config.Config{
+		Command:         config.CommandRender,
+		RenderDir:       dir,
+		RenderManifests: []string{manifests},
+		TemplatesDir:    "../templates",
+		AdminNamespace:  "kube-system",
+		KubeletRoot:     "/var/lib/kubelet/",
+		ID:              "default",
+	}
+}
+
+func TestRenderPrintsFiles(t *testing.T) {
+	cfg := makeTestConfig(t)
+
+	out := &bytes.Buffer{}
+	err := Run(context.Background(), cfg, out)
+	assert.Nil(t, err)
+
+	assert.Contains(t, out.String(), "# ==> fluent.conf <==\n")
+	assert.Contains(t, out.String(), "@include ns-demo.conf")
+	assert.Contains(t, out.String(), "# ==> ns-demo.conf <==\n")
+	// the mounted-file is resolved against the pod of the manifest
+	assert.Contains(t, out.String(), "/var/lib/kubelet/pods/723dd34a-4ac0-11e8-8a81-0a930dd884b0/volumes/kubernetes.io~empty-dir/logs/welcome.log")
+	// generated files are the same as in the cluster, without the validation trailer
+	assert.NotContains(t, out.String(), "validation  trailer")
+}
+
+func TestRenderWritesFiles(t *testing.T) {
+	cfg := makeTestConfig(t)
+	cfg.RenderOutputDir = t.TempDir()
+
+	out := &bytes.Buffer{}
+	err := Run(context.Background(), cfg, out)
+	assert.Nil(t, err)
+	assert.Equal(t, "", out.String())
+
+	_, err = os.Stat(filepath.Join(cfg.RenderOutputDir, "ns-demo.conf"))
+	assert.Nil(t, err)
+}
+
+func TestRenderReportsInvalidNamespaces(t *testing.T) {
+	cfg := makeTestConfig(t)
+	assert.Nil(t, os.WriteFile(filepath.Join(cfg.RenderDir, "broken.conf"), []byte("<match s.**>\n</match>\n"), 0o644))
+
+	out := &bytes.Buffer{}
+	err := Run(context.Background(), cfg, out)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "namespace broken: line 1: bad tag for <match>: s.**")
+	// the valid namespaces are still rendered
+	assert.Contains(t, out.String(), "# ==> ns-demo.conf <==\n")
+}
+
+func TestRenderResolvesIncludes(t *testing.T) {
+	cfg := makeTestConfig(t)
+
+	conf := `<match **>
+  @type elasticsearch
+  @include es.conf
+</match>
+`
+	assert.Nil(t, os.WriteFile(filepath.Join(cfg.RenderDir, "demo.conf"), []byte(conf), 0o644))
+	assert.Nil(t, os.Mkdir(filepath.Join(cfg.RenderDir, "demo"), 0o755))
+	assert.Nil(t, os.WriteFile(filepath.Join(cfg.RenderDir, "demo", "es.conf"), []byte("host {{ .Values.Namespace }}.es.example.com\n"), 0o644))
+
+	out := &bytes.Buffer{}
+	err := Run(context.Background(), cfg, out)
+	assert.Nil(t, err)
+	assert.Contains(t, out.String(), "host demo.es.example.com")
+}
+
+func TestRenderResolvesLookups(t *testing.T) {
+	cfg := makeTestConfig(t)
+	manifest := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: outputs
+  namespace: demo
+data:
+  host: es.example.com
+`
+	assert.Nil(t, os.WriteFile(filepath.Join(filepath.Dir(cfg.RenderManifests[0]), "cm.yaml"), []byte(manifest), 0o644))
+	cfg.RenderManifests = []string{filepath.Dir(cfg.RenderManifests[0])}
+
+	conf := `<match **>
+  @type elasticsearch
+  host {{ (k8sLookup "ConfigMap.v1" "demo" "outputs").data.host }}
+</match>
+`
+	assert.Nil(t, os.WriteFile(filepath.Join(cfg.RenderDir, "demo.conf"), []byte(conf), 0o644))
+
+	out := &bytes.Buffer{}
+	err := Run(context.Background(), cfg, out)
+	assert.Nil(t, err)
+	assert.Contains(t, out.String(), "host es.example.com")
+}
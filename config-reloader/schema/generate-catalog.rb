@@ -0,0 +1,75 @@
+# Copyright © 2018 VMware, Inc. All Rights Reserved.
+# SPDX-License-Identifier: BSD-2-Clause
+
+# Prints the plugin schema of the plugins installed with fluentd, run it in the image:
+#   ruby generate-catalog.rb [plugin dir...] > catalog.json
+
+require 'json'
+require 'fluent/plugin'
+require 'fluent/version'
+
+PREFIXES = {
+  'out_' => 'output',
+  'filter_' => 'filter',
+  'in_' => 'input',
+  'buf_' => 'buffer',
+  'parser_' => 'parser',
+  'formatter_' => 'formatter',
+  'storage_' => 'storage',
+}.freeze
+
+TYPES = %w[string integer float size time bool enum array hash regexp].freeze
+
+# these plugins read params that they do not declare
+OPEN = %w[output/record_reformer output/rewrite_tag_filter].freeze
+
+def section(definition)
+  res = { 'params' => {}, 'sections' => {} }
+  definition.each do |name, opts|
+    name = name.to_s
+    if opts[:section]
+      sub = section(opts.reject { |k, _| %i[section required multi alias].include?(k) })
+      sub['required'] = true if opts[:required]
+      sub['multi'] = true if opts[:multi]
+      sub['alias'] = opts[:alias].to_s if opts[:alias]
+      res['sections'][name] = sub
+      next
+    end
+    # the argument of a section, like the chunk keys of <buffer>
+    next if opts[:argument] || name.start_with?('@')
+
+    type = opts[:type].to_s
+    param = { 'type' => TYPES.include?(type) ? type : 'string' }
+    param['required'] = true if opts[:required] && !opts.key?(:default)
+    param['list'] = opts[:list].map(&:to_s) if opts[:list]
+    param['alias'] = opts[:alias].to_s if opts[:alias]
+    res['params'][name] = param
+  end
+  res.delete('params') if res['params'].empty?
+  res.delete('sections') if res['sections'].empty?
+  res
+end
+
+ARGV.each { |dir| $LOAD_PATH.unshift(dir) }
+files = Gem.find_latest_files('fluent/plugin/*.rb') + ARGV.flat_map { |dir| Dir.glob(File.join(dir, '*.rb')) }
+
+catalog = { 'fluentd' => Fluent::VERSION, 'plugins' => Hash.new { |h, k| h[k] = {} } }
+files.map { |f| File.basename(f, '.rb') }.uniq.sort.each do |name|
+  prefix = PREFIXES.keys.find { |p| name.start_with?(p) }
+  next unless prefix
+
+  kind = PREFIXES[prefix]
+  type = name.delete_prefix(prefix)
+  begin
+    klass = Fluent::Plugin.const_get("#{kind.upcase}_REGISTRY").lookup(type)
+    next unless klass.respond_to?(:dump_config_definition)
+
+    plugin = section(klass.dump_config_definition)
+    plugin['open'] = true if OPEN.include?("#{kind}/#{type}")
+    catalog['plugins'][kind][type] = plugin
+  rescue StandardError, LoadError => e
+    warn "skipping #{kind}/#{type}: #{e.message}"
+  end
+end
+
+puts JSON.pretty_generate(catalog)
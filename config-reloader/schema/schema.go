@@ -0,0 +1,418 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+// Package schema knows the parameters of the fluentd plugins and checks the directives of
+// a config against them, so that a typo is reported instead of being ignored by fluentd.
+package schema
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/util"
+)
+
+// defaultCatalog covers the core plugins of the fluentd image. Regenerate it with
+// "make plugin-schema" to describe every plugin installed in the image.
+//
+//go:embed catalog.json
+var defaultCatalog []byte
+
+// Catalog describes the parameters of the fluentd plugins
+type Catalog struct {
+	// Fluentd is the version of fluentd the catalog describes
+	Fluentd string `json:"fluentd"`
+	// Plugins are indexed by kind (output, filter, input, buffer, parser, formatter, storage) and @type
+	Plugins map[string]map[string]*Section `json:"plugins"`
+	// Bases hold what many plugins have in common, like the <buffer> of the outputs
+	Bases map[string]*Section `json:"bases,omitempty"`
+}
+
+// Section describes a plugin or one of its sections
+type Section struct {
+	Params   map[string]*Param   `json:"params,omitempty"`
+	Sections map[string]*Section `json:"sections,omitempty"`
+	Required bool                `json:"required,omitempty"`
+	Multi    bool                `json:"multi,omitempty"`
+	Alias    string              `json:"alias,omitempty"`
+	// Base names the entry of Catalog.Bases the section extends
+	Base string `json:"base,omitempty"`
+	// Open sections take any param, like a plugin that reads its params as record fields
+	Open bool `json:"open,omitempty"`
+}
+
+// Param describes a config_param of a plugin
+type Param struct {
+	// Type is one of string, integer, float, size, time, bool, enum, array, hash or regexp
+	Type     string   `json:"type,omitempty"`
+	Required bool     `json:"required,omitempty"`
+	List     []string `json:"list,omitempty"`
+	Alias    string   `json:"alias,omitempty"`
+}
+
+// systemParams are understood by every plugin
+var systemParams = map[string]bool{
+	"@type":      true,
+	"type":       true,
+	"@id":        true,
+	"id":         true,
+	"@label":     true,
+	"@log_level": true,
+	"log_level":  true,
+}
+
+// pluginSections are the sections whose @type selects another plugin, with its kind
+var pluginSections = map[string]string{
+	"buffer":    "buffer",
+	"secondary": "output",
+	"store":     "output",
+	"parse":     "parser",
+	"format":    "formatter",
+	"storage":   "storage",
+}
+
+// defaultTypes are used by the plugin sections that do not set @type
+var defaultTypes = map[string]string{
+	"buffer": "memory",
+}
+
+var (
+	reInteger = regexp.MustCompile(`^[-+]?\d+$`)
+	reSize    = regexp.MustCompile(`(?i)^\d+(\.\d+)?\s*([kmgt]b?)?$`)
+	reTime    = regexp.MustCompile(`^\d+(\.\d+)?(ms|s|m|h|d)?$`)
+	bools     = map[string]bool{"": true, "true": true, "yes": true, "false": true, "no": true}
+)
+
+// Default returns the catalog that ships with the config-reloader
+func Default() *Catalog {
+	c, err := parse(defaultCatalog)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Load reads a catalog from a JSON file, the default catalog if file is empty
+func Load(file string) (*Catalog, error) {
+	if file == "" {
+		return Default(), nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse plugin schema %s: %w", file, err)
+	}
+	return c, nil
+}
+
+func parse(data []byte) (*Catalog, error) {
+	c := &Catalog{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+
+	for kind, plugins := range c.Plugins {
+		for name, plugin := range plugins {
+			if err := c.resolve(plugin); err != nil {
+				return nil, fmt.Errorf("%s/%s: %w", kind, name, err)
+			}
+		}
+	}
+	return c, nil
+}
+
+// resolve merges the base into a section and its subsections
+func (c *Catalog) resolve(s *Section) error {
+	if s.Base != "" {
+		base, ok := c.Bases[s.Base]
+		if !ok {
+			return fmt.Errorf("unknown base %s", s.Base)
+		}
+		if s.Params == nil {
+			s.Params = map[string]*Param{}
+		}
+		for name, p := range base.Params {
+			if _, ok := s.Params[name]; !ok {
+				s.Params[name] = p
+			}
+		}
+		if s.Sections == nil {
+			s.Sections = map[string]*Section{}
+		}
+		for name, sub := range base.Sections {
+			if _, ok := s.Sections[name]; !ok {
+				s.Sections[name] = sub
+			}
+		}
+		s.Base = ""
+	}
+
+	for _, sub := range s.Sections {
+		if err := c.resolve(sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Plugin returns the description of a plugin, nil when the catalog does not know it
+func (c *Catalog) Plugin(kind string, typ string) *Section {
+	if c == nil {
+		return nil
+	}
+	return c.Plugins[kind][typ]
+}
+
+// Check validates the params of a <match>, <filter> or <source> directive and of its
+// sections. Plugins missing from the catalog are not checked.
+func (c *Catalog) Check(d *fluentd.Directive) error {
+	var kind string
+	switch d.Name {
+	case "match":
+		kind = "output"
+	case "filter":
+		kind = "filter"
+	case "source":
+		kind = "input"
+	default:
+		return nil
+	}
+
+	typ := util.TrimTrailingComment(d.Type())
+	plugin := c.Plugin(kind, typ)
+	if plugin == nil {
+		return nil
+	}
+
+	return c.check(d, plugin, typ, "")
+}
+
+func (c *Catalog) check(d *fluentd.Directive, s *Section, plugin string, where string) error {
+	if s.Open {
+		return nil
+	}
+
+	for _, name := range sortedParams(d.Params) {
+		if systemParams[name] {
+			continue
+		}
+
+		param := d.Params[name]
+		def := s.param(name)
+		if def == nil {
+			msg := fmt.Sprintf("unknown parameter '%s'%s for %s", name, where, plugin)
+			if guess := suggest(name, s.names()); guess != "" {
+				msg += fmt.Sprintf(", did you mean '%s'?", guess)
+			}
+			return fluentd.ErrorAt(param.Pos, fmt.Errorf("%s", msg))
+		}
+
+		if err := def.validate(param.Value); err != nil {
+			return fluentd.ErrorAt(param.Pos, fmt.Errorf("invalid value of '%s'%s for %s: %w", name, where, plugin, err))
+		}
+	}
+
+	for _, name := range sortedDefs(s.Params) {
+		if def := s.Params[name]; def.Required && d.Params[name] == nil && (def.Alias == "" || d.Params[def.Alias] == nil) {
+			return fluentd.ErrorAt(d.Pos, fmt.Errorf("missing required parameter '%s'%s for %s", name, where, plugin))
+		}
+	}
+
+	for _, nested := range d.Nested {
+		sub := s.section(nested.Name)
+		if sub == nil {
+			// plugins may read sections that are not declared, like the <record> of record_transformer
+			continue
+		}
+
+		name := plugin
+		if kind, ok := pluginSections[nested.Name]; ok {
+			typ := util.TrimTrailingComment(nested.Type())
+			if typ == "" {
+				typ = defaultTypes[nested.Name]
+			}
+			selected := c.Plugin(kind, typ)
+			if selected == nil {
+				continue
+			}
+			sub = merge(sub, selected)
+			if kind == "output" {
+				// the <store> of copy and <secondary> are outputs of their own
+				name = typ
+			}
+		}
+
+		if err := c.check(nested, sub, name, fmt.Sprintf(" in <%s>", nested.Name)); err != nil {
+			return fluentd.ErrorAt(nested.Pos, err)
+		}
+	}
+
+	return nil
+}
+
+// merge combines a section with the plugin its @type selects
+func merge(s *Section, plugin *Section) *Section {
+	res := &Section{
+		Params:   map[string]*Param{},
+		Sections: map[string]*Section{},
+		Open:     s.Open || plugin.Open,
+	}
+	for _, from := range []*Section{s, plugin} {
+		for name, p := range from.Params {
+			res.Params[name] = p
+		}
+		for name, sub := range from.Sections {
+			res.Sections[name] = sub
+		}
+	}
+	return res
+}
+
+func (s *Section) param(name string) *Param {
+	if p, ok := s.Params[name]; ok {
+		return p
+	}
+	for _, p := range s.Params {
+		if p.Alias != "" && p.Alias == name {
+			return p
+		}
+	}
+	return nil
+}
+
+func (s *Section) section(name string) *Section {
+	if sub, ok := s.Sections[name]; ok {
+		return sub
+	}
+	for _, sub := range s.Sections {
+		if sub.Alias != "" && sub.Alias == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+func (s *Section) names() []string {
+	res := make([]string, 0, len(s.Params))
+	for name, p := range s.Params {
+		res = append(res, name)
+		if p.Alias != "" {
+			res = append(res, p.Alias)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// validate checks the literal values, those computed by fluentd are left alone
+func (p *Param) validate(value string) error {
+	if strings.Contains(value, "${") || strings.Contains(value, "#{") || strings.Contains(value, "$secret(") {
+		return nil
+	}
+	value = unquote(util.TrimTrailingComment(value))
+
+	var ok bool
+	switch p.Type {
+	case "integer":
+		ok = reInteger.MatchString(value)
+	case "float":
+		_, err := strconv.ParseFloat(value, 64)
+		ok = err == nil
+	case "size":
+		ok = reSize.MatchString(value)
+	case "time":
+		ok = reTime.MatchString(value)
+	case "bool":
+		ok = bools[value]
+	case "enum":
+		for _, v := range p.List {
+			if v == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("'%s' is not one of %s", value, strings.Join(p.List, ", "))
+	default:
+		return nil
+	}
+
+	if !ok {
+		return fmt.Errorf("'%s' is not a valid %s", value, p.Type)
+	}
+	return nil
+}
+
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// suggest returns the closest name for a likely typo
+func suggest(name string, names []string) string {
+	best := ""
+	bestDistance := len(name)/3 + 1
+	for _, candidate := range names {
+		if d := distance(name, candidate); d <= bestDistance && (best == "" || d < distance(name, best)) {
+			best = candidate
+		}
+	}
+	return best
+}
+
+// distance is the edit distance of two strings, swapping two adjacent letters is one edit
+func distance(a string, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return d[len(a)][len(b)]
+}
+
+func sortedParams(params fluentd.Params) []string {
+	res := make([]string, 0, len(params))
+	for name := range params {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func sortedDefs(params map[string]*Param) []string {
+	res := make([]string, 0, len(params))
+	for name := range params {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,130 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func checkString(t *testing.T, c *Catalog, s string) error {
+	fragment, err := fluentd.ParseString(s)
+	assert.Nil(t, err)
+
+	for _, d := range fragment {
+		if err := c.Check(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestCheckValidConfig(t *testing.T) {
+	s := `
+<match **>
+  @type elasticsearch
+  @id es
+  host "es.logging.svc" # the shared cluster
+  port 9200
+  scheme https
+  logstash_format true
+  request_timeout 15s
+  <buffer tag, time>
+    @type file
+    path /var/log/fluentd/es
+    timekey 1h
+    chunk_limit_size 8m
+    flush_interval ${flush}
+  </buffer>
+</match>
+
+<filter **>
+  @type parser
+  key_name log
+  <parse>
+    @type regexp
+    expression /^(?<level>\w+) (?<message>.*)$/
+  </parse>
+</filter>
+
+<filter **>
+  @type record_transformer
+  <record>
+    any_field any value
+  </record>
+</filter>
+
+<match **>
+  @type s3
+  not_in_the_catalog true
+</match>
+`
+	assert.Nil(t, checkString(t, Default(), s))
+}
+
+func TestCheckRejectsTypos(t *testing.T) {
+	c := Default()
+
+	err := checkString(t, c, "<match **>\n  @type elasticsearch\n  hots es.logging.svc\n</match>")
+	assert.Equal(t, "line 3: unknown parameter 'hots' for elasticsearch, did you mean 'host'?", err.Error())
+
+	err = checkString(t, c, "<match **>\n  @type elasticsearch\n  <buffer>\n    flush_intervall 5s\n  </buffer>\n</match>")
+	assert.Equal(t, "line 4: unknown parameter 'flush_intervall' in <buffer> for elasticsearch, did you mean 'flush_interval'?", err.Error())
+
+	err = checkString(t, c, "<match **>\n  @type null\n  completely_unrelated 1\n</match>")
+	assert.Equal(t, "line 3: unknown parameter 'completely_unrelated' for null", err.Error())
+
+	err = checkString(t, c, "<match **>\n  @type copy\n  <store>\n    @type forward\n    send_timout 5s\n  </store>\n</match>")
+	assert.Equal(t, "line 5: unknown parameter 'send_timout' in <store> for forward, did you mean 'send_timeout'?", err.Error())
+}
+
+func TestCheckValues(t *testing.T) {
+	c := Default()
+
+	err := checkString(t, c, "<match **>\n  @type elasticsearch\n  port nine\n</match>")
+	assert.Equal(t, "line 3: invalid value of 'port' for elasticsearch: 'nine' is not a valid integer", err.Error())
+
+	err = checkString(t, c, "<match **>\n  @type elasticsearch\n  scheme ftp\n</match>")
+	assert.Equal(t, "line 3: invalid value of 'scheme' for elasticsearch: 'ftp' is not one of http, https", err.Error())
+
+	err = checkString(t, c, "<match **>\n  @type null\n  <buffer>\n    flush_interval soon\n  </buffer>\n</match>")
+	assert.Equal(t, "line 4: invalid value of 'flush_interval' in <buffer> for null: 'soon' is not a valid time", err.Error())
+
+	err = checkString(t, c, "<match **>\n  @type null\n  <buffer>\n    @type file\n    chunk_limit_size lots\n  </buffer>\n</match>")
+	assert.Equal(t, "line 5: invalid value of 'chunk_limit_size' in <buffer> for null: 'lots' is not a valid size", err.Error())
+
+	err = checkString(t, c, "<filter **>\n  @type parser\n  reserve_data true\n</filter>")
+	assert.Equal(t, "line 1: missing required parameter 'key_name' for parser", err.Error())
+
+	// values fluentd computes are not checked
+	err = checkString(t, c, "<match **>\n  @type elasticsearch\n  port \"#{ENV['ES_PORT']}\"\n  password $secret(es, password)\n</match>")
+	assert.Nil(t, err)
+}
+
+func TestLoad(t *testing.T) {
+	c, err := Load("")
+	assert.Nil(t, err)
+	assert.NotNil(t, c.Plugin("output", "elasticsearch"))
+	// the base of the outputs is merged
+	assert.NotNil(t, c.Plugin("output", "elasticsearch").Sections["buffer"])
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "catalog.json")
+	assert.Nil(t, os.WriteFile(file, []byte(`{"plugins": {"output": {"custom": {"params": {"endpoint": {"type": "string", "required": true}}}}}}`), 0o644))
+
+	c, err = Load(file)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Plugin("output", "elasticsearch"))
+	err = checkString(t, c, "<match **>\n  @type custom\n</match>")
+	assert.Equal(t, "line 1: missing required parameter 'endpoint' for custom", err.Error())
+
+	assert.Nil(t, os.WriteFile(file, []byte(`{"plugins": {"output": {"custom": {"base": "output"}}}}`), 0o644))
+	_, err = Load(file)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,116 @@
+package template
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Backend reads the objects returned by k8sLookup
+type Backend interface {
+	// Get returns an object, the namespace is empty for cluster-scoped objects
+	Get(ctx context.Context, gvk schema.GroupVersionKind, namespace string, name string) (*unstructured.Unstructured, error)
+	// List returns the objects of a namespace, or of all namespaces if it is empty
+	List(ctx context.Context, gvk schema.GroupVersionKind, namespace string) ([]unstructured.Unstructured, error)
+	// Namespaced tells whether the objects of the kind belong to a namespace
+	Namespaced(ctx context.Context, gvk schema.GroupVersionKind) (bool, error)
+}
+
+// FakeBackend serves k8sLookup from objects held in memory, for tests and for the
+// datasources that run without a cluster
+type FakeBackend struct {
+	lock    sync.RWMutex
+	objects []*unstructured.Unstructured
+}
+
+var _ Backend = &FakeBackend{}
+
+// NewFakeBackend returns a FakeBackend holding the objects
+func NewFakeBackend(objects ...*unstructured.Unstructured) *FakeBackend {
+	b := &FakeBackend{}
+	for _, o := range objects {
+		b.Add(o)
+	}
+	return b
+}
+
+// Add stores a copy of the object, replacing the object with the same kind, namespace and name
+func (b *FakeBackend) Add(obj *unstructured.Unstructured) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	obj = obj.DeepCopy()
+	for i, o := range b.objects {
+		if o.GroupVersionKind().GroupKind() == obj.GroupVersionKind().GroupKind() &&
+			o.GetNamespace() == obj.GetNamespace() && o.GetName() == obj.GetName() {
+			b.objects[i] = obj
+			return
+		}
+	}
+	b.objects = append(b.objects, obj)
+}
+
+func (b *FakeBackend) Get(ctx context.Context, gvk schema.GroupVersionKind, namespace string, name string) (*unstructured.Unstructured, error) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	for _, o := range b.objects {
+		if matchesKind(o, gvk) && o.GetNamespace() == namespace && o.GetName() == name {
+			return o.DeepCopy(), nil
+		}
+	}
+
+	return nil, errors.NewNotFound(schema.GroupResource{
+		Group:    gvk.Group,
+		Resource: strings.ToLower(gvk.Kind),
+	}, name)
+}
+
+func (b *FakeBackend) List(ctx context.Context, gvk schema.GroupVersionKind, namespace string) ([]unstructured.Unstructured, error) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	res := []unstructured.Unstructured{}
+	for _, o := range b.objects {
+		if matchesKind(o, gvk) && (namespace == "" || o.GetNamespace() == namespace) {
+			res = append(res, *o.DeepCopy())
+		}
+	}
+
+	// the same order as the API server
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].GetNamespace() != res[j].GetNamespace() {
+			return res[i].GetNamespace() < res[j].GetNamespace()
+		}
+		return res[i].GetName() < res[j].GetName()
+	})
+
+	return res, nil
+}
+
+// Namespaced tells a kind apart as cluster-scoped when the backend holds an object of the
+// kind without namespace
+func (b *FakeBackend) Namespaced(ctx context.Context, gvk schema.GroupVersionKind) (bool, error) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	for _, o := range b.objects {
+		if matchesKind(o, gvk) && o.GetNamespace() == "" {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// matchesKind ignores the version if the lookup does not name one
+func matchesKind(o *unstructured.Unstructured, gvk schema.GroupVersionKind) bool {
+	ogvk := o.GroupVersionKind()
+	return ogvk.Group == gvk.Group && ogvk.Kind == gvk.Kind &&
+		(gvk.Version == "" || ogvk.Version == gvk.Version)
+}
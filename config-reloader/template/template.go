@@ -9,33 +9,51 @@ import (
 	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/Masterminds/sprig/v3"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	"sigs.k8s.io/controller-runtime/pkg/client"
-	konfig "sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/yaml"
 )
 
-var (
-	k8sClient  client.Reader
-	allowLabel string
-)
+// a lookup starts an informer the first time a kind is read, it must not block the run
+// forever if the kind cannot be listed
+const lookupTimeout = 10 * time.Second
+
+// Context restricts what k8sLookup can read while rendering the config of a namespace
+type Context struct {
+	// Backend is where the objects are read from, k8sLookup fails when it is nil
+	Backend Backend
+	// Namespace the config belongs to, its objects can always be read
+	Namespace string
+	// AllowLabel, when set, must be set to true on every object that is read
+	AllowLabel string
+	// Kinds, when not nil, are the only kinds k8sLookup can read, * allows any kind
+	Kinds []string
+	// AllowLookup tells whether objects of a kind can be read in another namespace,
+	// the namespace is empty for cluster-scoped objects and lists across namespaces
+	AllowLookup func(kind string, namespace string) bool
+}
 
-func SetAllowLabel(label string) {
-	allowLabel = label
+// AllowAll lets k8sLookup read any object, it is meant for the admin configs
+func AllowAll(kind string, namespace string) bool {
+	return true
 }
 
 // Render is a go template rendering function it includes all the sprig lib functions
 // as well as some extras like a k8sLookup function to get values from k8s objects
 // you can access environment variables from the template under .Env
-// The passed values will be available under .Values in the templates
-func Render(out io.Writer, tmpl string, values interface{}) error {
+// The passed values will be available under .Values in the templates.
+// k8sLookup is restricted by tctx, it cannot read anything if tctx is nil.
+func Render(out io.Writer, tmpl string, values interface{}, tctx *Context) error {
 	t, err := New("tmpl").Parse(tmpl)
 	if err != nil {
 		return err
 	}
+	t.Funcs(template.FuncMap{
+		"k8sLookup": tctx.k8sLookup,
+	})
 	return t.Execute(out, map[string]interface{}{
 		"Env":    envMap(),
 		"Values": values,
@@ -75,7 +93,8 @@ func New(name string) *template.Template {
 	}
 	funcMap["toYaml"] = toYaml
 	funcMap["fromYaml"] = fromYaml
-	funcMap["k8sLookup"] = k8sLookup
+	// replaced by Render with the lookup of the namespace
+	funcMap["k8sLookup"] = (*Context)(nil).k8sLookup
 	return tpl.Funcs(funcMap).Delims("{{", "}}")
 }
 
@@ -93,18 +112,11 @@ func fromYaml(str string) (map[string]interface{}, error) {
 	return m, err
 }
 
-func k8sLookup(kind, namespace, name string) (map[string]interface{}, error) {
-	if k8sClient == nil {
-		kfg, err := konfig.GetConfig()
-		if err != nil {
-			return nil, err
-		}
-		if c, err := client.New(kfg, client.Options{}); err != nil {
-			return nil, fmt.Errorf("failed to create client: %w", err)
-		} else {
-			k8sClient = c
-		}
+func (tctx *Context) k8sLookup(kind, namespace, name string) (map[string]interface{}, error) {
+	if tctx == nil || tctx.Backend == nil {
+		return nil, fmt.Errorf("k8sLookup is not available")
 	}
+
 	gvk, gk := schema.ParseKindArg(kind)
 	if gvk == nil {
 		// this looks strange but it should make sense if you read the ParseKindArg docs
@@ -113,48 +125,79 @@ func k8sLookup(kind, namespace, name string) (map[string]interface{}, error) {
 			Version: gk.Group,
 		}
 	}
+
+	if tctx.Kinds != nil && !containsKind(tctx.Kinds, gvk.Kind) {
+		return nil, fmt.Errorf("k8sLookup of %s is not allowed", gvk.Kind)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+
+	// the objects of a cluster-scoped kind are outside of every namespace, whatever
+	// namespace the template passes
+	namespaced, err := tctx.Backend.Namespaced(ctx, *gvk)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get: %w", err)
+	}
+	if !namespaced {
+		namespace = ""
+	}
+
+	if namespace != tctx.Namespace && (tctx.AllowLookup == nil || !tctx.AllowLookup(gvk.Kind, namespace)) {
+		if namespace == "" {
+			return nil, fmt.Errorf("k8sLookup of %s outside of namespace %s is not allowed", gvk.Kind, tctx.Namespace)
+		}
+		return nil, fmt.Errorf("k8sLookup of %s in namespace %s is not allowed", gvk.Kind, namespace)
+	}
+
 	if name != "" {
 		// fetching a single resource by name
-		u := unstructured.Unstructured{}
-		u.SetGroupVersionKind(schema.GroupVersionKind{
-			Group:   gvk.Group,
-			Kind:    gvk.Kind,
-			Version: gvk.Version,
-		})
-		if err := k8sClient.Get(context.Background(), client.ObjectKey{
-			Namespace: namespace,
-			Name:      name,
-		}, &u); err != nil {
+		u, err := tctx.Backend.Get(ctx, *gvk, namespace, name)
+		if err != nil {
 			return nil, fmt.Errorf("failed to get: %w", err)
 		}
-		if allowLabel != "" {
-			if allow, _ := strconv.ParseBool(u.GetLabels()[allowLabel]); !allow {
-				return nil, fmt.Errorf("object not allowed")
-			}
+		if !tctx.allowed(u) {
+			return nil, fmt.Errorf("object not allowed")
 		}
 		return u.UnstructuredContent(), nil
 	}
-	ul := &unstructured.UnstructuredList{}
+
+	items, err := tctx.Backend.List(ctx, *gvk, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list: %w", err)
+	}
+	ul := &unstructured.UnstructuredList{Items: items}
 	ul.SetGroupVersionKind(schema.GroupVersionKind{
 		Group:   gvk.Group,
 		Version: gvk.Version,
 		Kind:    gvk.Kind + "List", // TODO: is there a better way?
 	})
-	opts := &client.ListOptions{
-		Namespace: namespace,
-	}
-	if err := k8sClient.List(context.Background(), ul, opts); err != nil {
-		return nil, fmt.Errorf("failed to list: %w", err)
+	for i := range ul.Items {
+		if !tctx.allowed(&ul.Items[i]) {
+			return nil, fmt.Errorf("object not allowed")
+		}
 	}
-	if allowLabel != "" {
-		for _, o := range ul.Items {
-			if allow, _ := strconv.ParseBool(o.GetLabels()[allowLabel]); !allow {
-				return nil, fmt.Errorf("object not allowed")
-			}
 
+	return ul.UnstructuredContent(), nil
+}
+
+func containsKind(kinds []string, kind string) bool {
+	for _, k := range kinds {
+		if k == kind || k == "*" {
+			return true
 		}
 	}
-	return ul.UnstructuredContent(), nil
+	return false
+}
+
+// allowed checks the allow label of an object
+func (tctx *Context) allowed(u *unstructured.Unstructured) bool {
+	if tctx.AllowLabel == "" {
+		return true
+	}
+
+	allow, _ := strconv.ParseBool(u.GetLabels()[tctx.AllowLabel])
+	return allow
 }
 
 func envMap() map[string]string {
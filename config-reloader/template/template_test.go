@@ -6,7 +6,6 @@ import (
 	"testing"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
 func TestRender(t *testing.T) {
@@ -39,6 +38,11 @@ key1 is {{ $cm.data.key1 }}
 foobar key is {{ $cfg.foobar.key }}`,
 			expected: "key1 is val1\nfoobar key is val",
 		},
+		{
+			name:     "list ConfigMaps",
+			template: `{{ range (k8sLookup "ConfigMap.v1" "default" "").items }}{{ .metadata.name }}{{ end }}`,
+			expected: "my-config-map",
+		},
 		{
 			name: "to yaml",
 			data: struct {
@@ -83,9 +87,12 @@ foobar key is {{ $cfg.foobar.key }}`,
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			k8sClient = fake.NewClientBuilder().WithObjects(cm.(*unstructured.Unstructured)).Build()
+			tctx := &Context{
+				Backend:   NewFakeBackend(cm.(*unstructured.Unstructured)),
+				Namespace: "default",
+			}
 			buf := new(strings.Builder)
-			err := Render(buf, tt.template, tt.data)
+			err := Render(buf, tt.template, tt.data, tctx)
 			if err != nil {
 				t.Errorf("unexpected error: %v", err)
 			}
@@ -95,3 +102,146 @@ foobar key is {{ $cfg.foobar.key }}`,
 		})
 	}
 }
+
+func TestLookupRestrictions(t *testing.T) {
+	jcm := `{
+  "apiVersion": "v1",
+  "kind": "ConfigMap",
+  "metadata": {
+    "name": "shared",
+    "namespace": "infra",
+    "labels": {"logging-allowed": "true"}
+  },
+  "data": {"key1": "val1"}
+}`
+	cm, _, err := unstructured.UnstructuredJSONScheme.Decode([]byte(jcm), nil, nil)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	backend := NewFakeBackend(cm.(*unstructured.Unstructured))
+
+	tests := []struct {
+		name  string
+		tctx  *Context
+		error string
+	}{
+		{
+			name:  "no context",
+			error: "k8sLookup is not available",
+		},
+		{
+			name: "other namespace",
+			tctx: &Context{
+				Backend:   backend,
+				Namespace: "tenant",
+			},
+			error: "k8sLookup of ConfigMap in namespace infra is not allowed",
+		},
+		{
+			name: "allowed namespace",
+			tctx: &Context{
+				Backend:   backend,
+				Namespace: "tenant",
+				AllowLookup: func(kind string, namespace string) bool {
+					return kind == "ConfigMap" && namespace == "infra"
+				},
+			},
+		},
+		{
+			name: "missing allow label",
+			tctx: &Context{
+				Backend:     backend,
+				Namespace:   "infra",
+				AllowLabel:  "logging-exported",
+				AllowLookup: AllowAll,
+			},
+			error: "object not allowed",
+		},
+		{
+			name: "kind not allowed",
+			tctx: &Context{
+				Backend:   backend,
+				Namespace: "infra",
+				Kinds:     []string{"Service"},
+			},
+			error: "k8sLookup of ConfigMap is not allowed",
+		},
+		{
+			name: "allowed kind",
+			tctx: &Context{
+				Backend:   backend,
+				Namespace: "infra",
+				Kinds:     []string{"ConfigMap"},
+			},
+		},
+		{
+			name: "any kind",
+			tctx: &Context{
+				Backend:   backend,
+				Namespace: "infra",
+				Kinds:     []string{"*"},
+			},
+		},
+		{
+			name: "allow label",
+			tctx: &Context{
+				Backend:    backend,
+				Namespace:  "infra",
+				AllowLabel: "logging-allowed",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := new(strings.Builder)
+			err := Render(buf, `{{ (k8sLookup "ConfigMap.v1" "infra" "shared").data.key1 }}`, nil, tt.tctx)
+			if tt.error == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				if buf.String() != "val1" {
+					t.Errorf("unexpected result: %s", buf.String())
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("expected error %q, got %v", tt.error, err)
+			}
+		})
+	}
+}
+
+func TestLookupClusterScopedKind(t *testing.T) {
+	ns := &unstructured.Unstructured{}
+	ns.SetAPIVersion("v1")
+	ns.SetKind("Namespace")
+	ns.SetName("kube-system")
+	ns.SetLabels(map[string]string{"team": "platform"})
+	backend := NewFakeBackend(ns)
+
+	tmpl := `{{ (k8sLookup "Namespace.v1" "tenant" "kube-system").metadata.labels.team }}`
+
+	// the namespace passed to the lookup does not make a cluster-scoped object part of it
+	tctx := &Context{
+		Backend:   backend,
+		Namespace: "tenant",
+		Kinds:     []string{"Namespace"},
+	}
+	err := Render(new(strings.Builder), tmpl, nil, tctx)
+	if err == nil || !strings.Contains(err.Error(), "k8sLookup of Namespace outside of namespace tenant is not allowed") {
+		t.Errorf("expected the lookup to be refused, got %v", err)
+	}
+
+	// a policy must allow the cluster-scoped objects
+	tctx.AllowLookup = func(kind string, namespace string) bool {
+		return kind == "Namespace" && namespace == ""
+	}
+	buf := new(strings.Builder)
+	if err := Render(buf, tmpl, nil, tctx); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if buf.String() != "platform" {
+		t.Errorf("unexpected result: %s", buf.String())
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package util
+
+import (
+	"regexp"
+	"strings"
+)
+
+var reStatefulSetOrdinal = regexp.MustCompile(`-[0-9]+$`)
+
+// PodOwner recognizes the controller of a pod from the labels and the name the Deployments,
+// StatefulSets, DaemonSets and Jobs give to their pods. The records only carry the labels
+// and the name, RubyPodOwner applies the same rule to them so that the owner of a pod is the
+// same for mounted-file and for $labels.
+func PodOwner(labels map[string]string, podName string) (string, string) {
+	// the generated suffix of the pod
+	base := podName
+	if i := strings.LastIndex(podName, "-"); i >= 0 {
+		base = podName[:i]
+	}
+
+	hash := labels["pod-template-hash"]
+	jobName := labels["batch.kubernetes.io/job-name"]
+	if jobName == "" {
+		jobName = labels["job-name"]
+	}
+
+	switch {
+	case hash != "" && strings.HasSuffix(base, "-"+hash):
+		return "Deployment", strings.TrimSuffix(base, "-"+hash)
+	case hash != "":
+		return "ReplicaSet", base
+	case labels["statefulset.kubernetes.io/pod-name"] != "":
+		return "StatefulSet", reStatefulSetOrdinal.ReplaceAllString(podName, "")
+	case labels["pod-template-generation"] != "":
+		return "DaemonSet", base
+	case jobName != "":
+		return "Job", jobName
+	}
+
+	return "", ""
+}
+
+// RubyPodOwner sets kubernetes.owner_kind and kubernetes.owner_name of a record like PodOwner
+// does. kubernetes_metadata may replace the dots in the label names with underscores.
+const RubyPodOwner = `k = record['kubernetes']; ` +
+	`l = (k && k['labels']) || Hash.new; ` +
+	`n = k && k['pod_name'].to_s; ` +
+	`b = n && n.sub(/-[^-]*\z/, ''); ` +
+	`h = l['pod-template-hash']; ` +
+	`j = l['batch.kubernetes.io/job-name'] || l['batch_kubernetes_io/job-name'] || l['job-name']; ` +
+	`o = if h && b.end_with?('-' + h) then ['Deployment', b.chomp('-' + h)] ` +
+	`elsif h then ['ReplicaSet', b] ` +
+	`elsif l['statefulset.kubernetes.io/pod-name'] || l['statefulset_kubernetes_io/pod-name'] then ['StatefulSet', n.sub(/-[0-9]+\z/, '')] ` +
+	`elsif l['pod-template-generation'] then ['DaemonSet', b] ` +
+	`elsif j then ['Job', j] end; ` +
+	`k['owner_kind'], k['owner_name'] = o if k && o; nil`
@@ -0,0 +1,35 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPodOwner(t *testing.T) {
+	tests := []struct {
+		pod    string
+		labels map[string]string
+		kind   string
+		name   string
+	}{
+		{"web-6d4cf56db6-x2v9k", map[string]string{"pod-template-hash": "6d4cf56db6"}, "Deployment", "web"},
+		{"web-x2v9k", map[string]string{"pod-template-hash": "6d4cf56db6"}, "ReplicaSet", "web"},
+		{"db-10", map[string]string{"statefulset.kubernetes.io/pod-name": "db-10"}, "StatefulSet", "db"},
+		{"agent-4xk2p", map[string]string{"pod-template-generation": "3"}, "DaemonSet", "agent"},
+		{"backup-28312440-abcde", map[string]string{"job-name": "backup-28312440"}, "Job", "backup-28312440"},
+		{"backup-28312440-abcde", map[string]string{"batch.kubernetes.io/job-name": "backup-28312440"}, "Job", "backup-28312440"},
+		// the pods of other controllers have no owner, whatever their ownerReferences
+		{"rollout-7c9f8-x2v9k", map[string]string{"rollouts-pod-template-hash": "7c9f8"}, "", ""},
+		{"standalone", nil, "", ""},
+	}
+
+	for _, tt := range tests {
+		kind, name := PodOwner(tt.labels, tt.pod)
+		assert.Equal(t, tt.kind, kind, tt.pod)
+		assert.Equal(t, tt.name, name, tt.pod)
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package util
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The pseudo-labels a selector can use besides the labels of the pod
+const (
+	ImageLabel       = "_image"
+	OwnerKindLabel   = "_owner_kind"
+	OwnerNameLabel   = "_owner_name"
+	AnnotationPrefix = "_annotation."
+)
+
+// The operators of a Requirement
+const (
+	OpEquals       = "="
+	OpNotEquals    = "!="
+	OpIn           = "in"
+	OpNotIn        = "notin"
+	OpExists       = "exists"
+	OpDoesNotExist = "!"
+)
+
+// image references and annotation values are not restricted like label values
+var reValidFreeValue = regexp.MustCompile(`^[^\s,()=!]*$`)
+var reSetRequirement = regexp.MustCompile(`^(\S+)\s+(in|notin)\s*\((.*)\)$`)
+
+// Requirement is a term of a Selector, like app=web or tier in (front, back)
+type Requirement struct {
+	Key      string
+	Operator string
+	Values   []string
+}
+
+// Selector selects containers like a Kubernetes label selector does. Its keys are the labels
+// of the pod and the pseudo-labels _container, _image, _owner_kind, _owner_name and
+// _annotation.<annotation>. All requirements must match.
+type Selector []*Requirement
+
+// Target is a container as seen by a Selector
+type Target struct {
+	Labels      map[string]string
+	Annotations map[string]string
+	Container   string
+	Image       string
+	// OwnerKind is the kind of the controller of the pod: Deployment instead of its ReplicaSet
+	OwnerKind string
+	OwnerName string
+}
+
+// ParseTagToLabels parses the $labels(...) macro
+func ParseTagToLabels(tag string) (Selector, error) {
+	if !strings.HasPrefix(tag, MacroLabels+"(") &&
+		!strings.HasSuffix(tag, ")") {
+		return nil, fmt.Errorf("bad $labels macro use: %s", tag)
+	}
+
+	return ParseSelector(tag[len(MacroLabels)+1 : len(tag)-1])
+}
+
+// ParseSelector parses comma-separated requirements: key=value, key!=value,
+// key in (v1, v2), key notin (v1, v2), key (it exists) and !key (it does not)
+func ParseSelector(s string) (Selector, error) {
+	terms, err := splitTerms(s)
+	if err != nil {
+		return nil, err
+	}
+
+	result := Selector{}
+	for _, term := range terms {
+		term = Trim(term)
+		if term == "" {
+			// be generous
+			continue
+		}
+
+		req, err := parseRequirement(term)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, req)
+	}
+
+	if len(result) == 0 {
+		return nil, errors.New("at least one label must be given")
+	}
+
+	return result, nil
+}
+
+// splitTerms splits at the commas that are not within parentheses
+func splitTerms(s string) ([]string, error) {
+	var terms []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+			if depth > 1 {
+				return nil, fmt.Errorf("bad label definition: %s", s)
+			}
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("bad label definition: %s", s)
+			}
+		case ',':
+			if depth == 0 {
+				terms = append(terms, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("bad label definition: %s", s)
+	}
+
+	return append(terms, s[start:]), nil
+}
+
+func parseRequirement(term string) (*Requirement, error) {
+	req := &Requirement{}
+
+	if m := reSetRequirement.FindStringSubmatch(term); m != nil {
+		req.Key, req.Operator = m[1], m[2]
+		for _, v := range strings.Split(m[3], ",") {
+			req.Values = append(req.Values, Trim(v))
+		}
+	} else if strings.HasPrefix(term, "!") && !strings.Contains(term, "=") {
+		req.Key, req.Operator = Trim(term[1:]), OpDoesNotExist
+	} else if kv := strings.Split(term, "!="); len(kv) == 2 {
+		req.Key, req.Operator, req.Values = Trim(kv[0]), OpNotEquals, []string{Trim(kv[1])}
+	} else if strings.Contains(term, "=") {
+		kv := strings.Split(term, "=")
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("bad label definition: %s", kv)
+		}
+		req.Key, req.Operator, req.Values = Trim(kv[0]), OpEquals, []string{Trim(kv[1])}
+	} else {
+		req.Key, req.Operator = term, OpExists
+	}
+
+	if err := validateKey(req.Key); err != nil {
+		return nil, err
+	}
+
+	for _, v := range req.Values {
+		if err := validateValue(req.Key, v); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Key == ContainerLabel && req.Operator == OpEquals && req.Values[0] == "" {
+		return nil, fmt.Errorf("value for %s cannot be empty string", ContainerLabel)
+	}
+
+	return req, nil
+}
+
+func validateKey(k string) error {
+	switch k {
+	case ContainerLabel, ImageLabel, OwnerKindLabel, OwnerNameLabel:
+		return nil
+	}
+
+	if strings.HasPrefix(k, AnnotationPrefix) {
+		if !reValidLabelName.MatchString(k[len(AnnotationPrefix):]) {
+			return fmt.Errorf("bad annotation name: %s", k[len(AnnotationPrefix):])
+		}
+		return nil
+	}
+
+	if !reValidLabelName.MatchString(k) {
+		return fmt.Errorf("bad label name: %s", k)
+	}
+	return nil
+}
+
+func validateValue(k string, v string) error {
+	re := reValidLabelValue
+	if k == ImageLabel || strings.HasPrefix(k, AnnotationPrefix) {
+		re = reValidFreeValue
+	}
+
+	if !re.MatchString(v) {
+		return fmt.Errorf("bad label value: %s", v)
+	}
+	return nil
+}
+
+// Equalities returns the label=value pairs of a selector that only has such requirements,
+// on the labels of the pod or on _container. Those can be matched by the tag of a record.
+func (s Selector) Equalities() (map[string]string, bool) {
+	res := map[string]string{}
+	for _, req := range s {
+		if req.Operator != OpEquals || (strings.HasPrefix(req.Key, "_") && req.Key != ContainerLabel) {
+			return nil, false
+		}
+		res[req.Key] = req.Values[0]
+	}
+
+	return res, true
+}
+
+// Match tells if all the requirements of the selector hold for the container
+func Match(selector Selector, target *Target) bool {
+	for _, req := range selector {
+		if !req.matches(target) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Requirement) matches(t *Target) bool {
+	value, ok := r.lookup(t)
+
+	switch r.Operator {
+	case OpExists:
+		return ok
+	case OpDoesNotExist:
+		return !ok
+	case OpEquals:
+		// like before set-based selectors, a missing label equals the empty string
+		return r.hasValue(value)
+	case OpNotEquals:
+		return !ok || !r.hasValue(value)
+	case OpIn:
+		return ok && r.hasValue(value)
+	case OpNotIn:
+		return !ok || !r.hasValue(value)
+	}
+
+	return false
+}
+
+func (r *Requirement) lookup(t *Target) (string, bool) {
+	switch r.Key {
+	case ContainerLabel:
+		return t.Container, true
+	case ImageLabel:
+		return t.Image, t.Image != ""
+	case OwnerKindLabel:
+		return t.OwnerKind, t.OwnerKind != ""
+	case OwnerNameLabel:
+		return t.OwnerName, t.OwnerName != ""
+	}
+
+	if strings.HasPrefix(r.Key, AnnotationPrefix) {
+		v, ok := t.Annotations[r.Key[len(AnnotationPrefix):]]
+		return v, ok
+	}
+
+	v, ok := t.Labels[r.Key]
+	return v, ok
+}
+
+func (r *Requirement) hasValue(value string) bool {
+	for _, v := range r.Values {
+		if v == value || (r.Key == ImageLabel && imageMatches(v, value)) {
+			return true
+		}
+	}
+	return false
+}
+
+// imageMatches tells if a reference without digest, or without tag and digest, names the image
+func imageMatches(ref string, image string) bool {
+	if i := strings.IndexByte(image, '@'); i >= 0 {
+		image = image[:i]
+	}
+	if ref == image {
+		return true
+	}
+	if i := strings.LastIndexByte(image, ':'); i > strings.LastIndexByte(image, '/') {
+		image = image[:i]
+	}
+	return ref == image
+}
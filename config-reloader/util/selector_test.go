@@ -0,0 +1,90 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSetBasedSelector(t *testing.T) {
+	selector, err := ParseTagToLabels("$labels(tier in (front, back), env notin (dev), app, !canary, track!=beta)")
+	assert.Nil(t, err)
+	assert.Equal(t, Selector{
+		{Key: "tier", Operator: OpIn, Values: []string{"front", "back"}},
+		{Key: "env", Operator: OpNotIn, Values: []string{"dev"}},
+		{Key: "app", Operator: OpExists},
+		{Key: "canary", Operator: OpDoesNotExist},
+		{Key: "track", Operator: OpNotEquals, Values: []string{"beta"}},
+	}, selector)
+
+	_, ok := selector.Equalities()
+	assert.False(t, ok)
+
+	selector, err = ParseSelector("_image in (nginx, docker.io/library/redis:7), _annotation.example.com/team=payments, _owner_kind=Deployment")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(selector))
+
+	// the pseudo-labels other than _container are not in the tag of a record
+	_, ok = selector.Equalities()
+	assert.False(t, ok)
+
+	for _, s := range []string{
+		"tier in (front",
+		"tier in ((front))",
+		"tier) in (front",
+		"tier in (-front)",
+		"_annotation.-team=payments",
+		"_image=nginx latest",
+		"!",
+	} {
+		_, err := ParseSelector(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func TestMatchSetBasedSelector(t *testing.T) {
+	target := &Target{
+		Labels:      map[string]string{"app": "web", "tier": "front"},
+		Annotations: map[string]string{"example.com/team": "payments"},
+		Container:   "main",
+		Image:       "docker.io/library/nginx:1.25@sha256:0123",
+		OwnerKind:   "Deployment",
+		OwnerName:   "web",
+	}
+
+	matches := map[string]bool{
+		"tier in (front, back)":               true,
+		"tier in (back)":                      false,
+		"tier notin (back)":                   true,
+		"env notin (dev)":                     true,
+		"env in (dev)":                        false,
+		"app":                                 true,
+		"!app":                                false,
+		"!canary":                             true,
+		"app!=web":                            false,
+		"app!=api":                            true,
+		"_image=docker.io/library/nginx":      true,
+		"_image=docker.io/library/nginx:1.25": true,
+		"_image=docker.io/library/nginx:1.24": false,
+		"_image in (redis, docker.io/library/nginx)": true,
+		"_annotation.example.com/team=payments":      true,
+		"_annotation.example.com/team":               true,
+		"!_annotation.example.com/oncall":            true,
+		"_owner_kind=Deployment, _owner_name=web":    true,
+		"_owner_kind in (StatefulSet, Job)":          false,
+		"_container notin (sidecar), app=web":        true,
+	}
+
+	for s, expected := range matches {
+		selector, err := ParseSelector(s)
+		assert.Nil(t, err, s)
+		assert.Equal(t, expected, Match(selector, target), s)
+	}
+
+	// a pod without a controller
+	selector, _ := ParseSelector("!_owner_kind")
+	assert.True(t, Match(selector, &Target{Container: "main"}))
+}
@@ -24,12 +24,15 @@ import (
 const (
 	maskFile       = 0664
 	maskDirectory  = 0775
+	maskSecretFile = 0600
 	MacroLabels    = "$labels"
+	MacroSecret    = "$secret"
 	ContainerLabel = "_container"
 )
 
 var reValidLabelName = regexp.MustCompile(`^([A-Za-z0-9][-A-Za-z0-9\/_.]*)?[A-Za-z0-9]$`)
 var reValidLabelValue = regexp.MustCompile(`^(([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])?$`)
+var reSecretMacro = regexp.MustCompile(`^\$secret\(\s*([-a-z0-9.]+)\s*,\s*([-._a-zA-Z0-9]+)\s*\)$`)
 
 func Trim(s string) string {
 	return strings.TrimSpace(s)
@@ -88,12 +91,17 @@ func SortedKeys(m map[string]string) []string {
 	return keys
 }
 
+// ErrTimeout is wrapped by the error of ExecAndGetOutput when the command is killed
+var ErrTimeout = errors.New("timeout reached")
+
 // ExecAndGetOutput exec and returns output of the command if timeout then kills the process and returns error
 func ExecAndGetOutput(cmd string, timeout time.Duration, args ...string) (string, error) {
 	c := exec.Command(cmd, args...)
 	var b bytes.Buffer
 	c.Stdout = &b
 	c.Stderr = &b
+	// a child that keeps the output open must not block Wait after a kill
+	c.WaitDelay = time.Second
 	var err error
 	if err = c.Start(); err != nil {
 		out := b.Bytes()
@@ -109,10 +117,12 @@ func ExecAndGetOutput(cmd string, timeout time.Duration, args ...string) (string
 	select {
 	case <-time.After(timeout):
 		if err = c.Process.Kill(); err != nil {
-			err = fmt.Errorf("process killed as timeout reached after %s, but kill failed with err: %s", timeout, err.Error())
+			err = fmt.Errorf("process killed as %w after %s, but kill failed with err: %s", ErrTimeout, timeout, err.Error())
 		} else {
-			err = fmt.Errorf("process killed as timeout reached after %s", timeout)
+			err = fmt.Errorf("process killed as %w after %s", ErrTimeout, timeout)
 		}
+		// the output is still copied to b until Wait returns
+		<-done
 	case err = <-done:
 	}
 	out := b.Bytes()
@@ -124,75 +134,41 @@ func WriteStringToFile(filename string, data string) error {
 	return os.WriteFile(filename, []byte(data), maskFile)
 }
 
-func TrimTrailingComment(line string) string {
-	i := strings.IndexByte(line, '#')
-	if i > 0 {
-		line = Trim(line[0:i])
-	} else {
-		line = Trim(line)
+// WriteSecretFile writes a file only its owner can read
+func WriteSecretFile(filename string, data []byte) error {
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, data, maskSecretFile); err != nil {
+		return err
 	}
 
-	return line
+	return os.Rename(tmp, filename)
 }
 
-func ParseTagToLabels(tag string) (map[string]string, error) {
-	if !strings.HasPrefix(tag, MacroLabels+"(") &&
-		!strings.HasSuffix(tag, ")") {
-		return nil, fmt.Errorf("bad $labels macro use: %s", tag)
-	}
-
-	labelsOnly := tag[len(MacroLabels)+1 : len(tag)-1]
-
-	result := map[string]string{}
-
-	records := strings.Split(labelsOnly, ",")
-	for _, rec := range records {
-		if rec == "" {
-			// be generous
-			continue
-		}
-		kv := strings.Split(rec, "=")
-		if len(kv) != 2 {
-			return nil, fmt.Errorf("bad label definition: %s", kv)
-		}
-
-		k := Trim(kv[0])
-		if k != ContainerLabel {
-			if !reValidLabelName.MatchString(k) {
-				return nil, fmt.Errorf("bad label name: %s", k)
-			}
-		}
-
-		v := Trim(kv[1])
-		if !reValidLabelValue.MatchString(v) {
-			return nil, fmt.Errorf("bad label value: %s", v)
-		}
-		if k == ContainerLabel && v == "" {
-			return nil, fmt.Errorf("value for %s cannot be empty string", ContainerLabel)
-		}
-
-		result[k] = v
+// ParseSecretMacro returns the name and the key of the secret a $secret(name, key)
+// param value refers to. ok is false if the value does not use the macro.
+func ParseSecretMacro(value string) (name string, key string, ok bool, err error) {
+	value = TrimTrailingComment(value)
+	if !strings.HasPrefix(value, MacroSecret+"(") {
+		return "", "", false, nil
 	}
 
-	if len(result) == 0 {
-		return nil, errors.New("at least one label must be given")
+	m := reSecretMacro.FindStringSubmatch(value)
+	if m == nil {
+		return "", "", true, fmt.Errorf("bad $secret macro use: %s", value)
 	}
 
-	return result, nil
+	return m[1], m[2], true, nil
 }
 
-func Match(labels map[string]string, contLabels map[string]string, contName string) bool {
-	for k, v := range labels {
-		value := contLabels[k]
-		if k == "_container" {
-			value = contName
-		}
-
-		if v != value {
-			return false
-		}
+func TrimTrailingComment(line string) string {
+	i := strings.IndexByte(line, '#')
+	if i > 0 {
+		line = Trim(line[0:i])
+	} else {
+		line = Trim(line)
 	}
-	return true
+
+	return line
 }
 
 func EnsureDirExists(dir string) error {
@@ -4,8 +4,10 @@
 package util
 
 import (
+	"errors"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -57,7 +59,9 @@ func TestLabelsParseOk(t *testing.T) {
 	for tag, result := range inputs {
 		processed, err := ParseTagToLabels(tag)
 		assert.Nil(t, err, "Got an error instead: %+v", err)
-		assert.Equal(t, result, processed)
+		labels, ok := processed.Equalities()
+		assert.True(t, ok)
+		assert.Equal(t, result, labels)
 	}
 }
 
@@ -85,27 +89,50 @@ func TestLabelsParseNotOk(t *testing.T) {
 	}
 }
 
+func TestParseSecretMacro(t *testing.T) {
+	name, key, ok, err := ParseSecretMacro("$secret(es-credentials, password)")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "es-credentials", name)
+	assert.Equal(t, "password", key)
+
+	_, _, ok, err = ParseSecretMacro("$secret(es, .dockerconfigjson) # pull secret")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	_, _, ok, err = ParseSecretMacro("changeme")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	for _, value := range []string{"$secret(es)", "$secret(es, a, b)", "$secret(Es, key)", "$secret(es, key) trailer"} {
+		_, _, ok, err = ParseSecretMacro(value)
+		assert.True(t, ok)
+		assert.NotNil(t, err, value)
+	}
+}
+
 func TestMatch(t *testing.T) {
-	containerLabels := map[string]string{"key": "value"}
-	containerName := "container-name"
+	target := &Target{
+		Labels:    map[string]string{"key": "value"},
+		Container: "container-name",
+	}
 
-	var labels map[string]string = nil
-	assert.True(t, Match(labels, containerLabels, containerName))
+	assert.True(t, Match(nil, target))
 
-	labels = map[string]string{"_container": containerName}
-	assert.True(t, Match(labels, containerLabels, containerName))
+	selector, _ := ParseSelector("_container=container-name")
+	assert.True(t, Match(selector, target))
 
-	labels = map[string]string{"a": "a"}
-	assert.False(t, Match(labels, containerLabels, containerName))
+	selector, _ = ParseSelector("a=a")
+	assert.False(t, Match(selector, target))
 
-	labels = map[string]string{"key": "value"}
-	assert.True(t, Match(labels, containerLabels, containerName))
+	selector, _ = ParseSelector("key=value")
+	assert.True(t, Match(selector, target))
 
-	labels = map[string]string{"key": "value", "_container": "container-name"}
-	assert.True(t, Match(labels, containerLabels, containerName))
+	selector, _ = ParseSelector("key=value, _container=container-name")
+	assert.True(t, Match(selector, target))
 
-	labels = map[string]string{"a": "a", "key": "value", "_container": "container-name"}
-	assert.False(t, Match(labels, containerLabels, containerName))
+	selector, _ = ParseSelector("a=a, key=value, _container=container-name")
+	assert.False(t, Match(selector, target))
 }
 
 func TestEnsureDirExits(t *testing.T) {
@@ -135,3 +162,13 @@ func TestEnsureDirExits(t *testing.T) {
 		}
 	}
 }
+
+func TestExecAndGetOutputTimeout(t *testing.T) {
+	_, err := ExecAndGetOutput("sleep", 50*time.Millisecond, "5")
+	assert.True(t, errors.Is(err, ErrTimeout))
+	assert.Equal(t, "process killed as timeout reached after 50ms", err.Error())
+
+	out, err := ExecAndGetOutput("echo", time.Second, "hello")
+	assert.Nil(t, err)
+	assert.Equal(t, "hello\n", out)
+}
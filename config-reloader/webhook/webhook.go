@@ -0,0 +1,394 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/config"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/datasource"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource"
+	kfo "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/apis/logs.vdp.vmware.com/v1beta1"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/fluentd"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/processors"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/schema"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/template"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/util"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// ValidatePath is where the admission webhook is served
+	ValidatePath = "/validate"
+
+	configMapEntryName = "fluent.conf"
+
+	// admission requests are small, anything bigger is not a fluentd config
+	maxRequestBytes = 3 * 1024 * 1024
+)
+
+// ConfigSource returns the current fluentd config of a namespace. It is used to
+// lookup the plugins defined in the admin namespace.
+type ConfigSource interface {
+	GetFluentdConfig(ctx context.Context, namespace string) (string, error)
+}
+
+// Webhook validates ConfigMaps and FluentdConfigs before they are stored
+type Webhook struct {
+	cfg       *config.Config
+	validator fluentd.Validator
+	source    ConfigSource
+	schema    *schema.Catalog
+}
+
+// New creates a Webhook. source can be nil in which case the plugins defined in the
+// admin namespace are not known: configs using them are still processed but they
+// can't be validated with fluentd.
+func New(ctx context.Context, cfg *config.Config, source ConfigSource) *Webhook {
+	var validator fluentd.Validator
+
+	if cfg.WebhookValidate && cfg.FluentdValidateCommand != "" {
+		// the API server gives up on the webhook after its timeoutSeconds, 10s by default
+		validator = fluentd.NewValidator(ctx, cfg.FluentdValidateCommand, time.Second*time.Duration(cfg.WebhookTimeout))
+	}
+
+	var catalog *schema.Catalog
+	if cfg.PluginSchema {
+		var err error
+		catalog, err = schema.Load(cfg.PluginSchemaFile)
+		if err != nil {
+			logrus.Errorf("Plugin schema validation is disabled in the admission webhook: %+v", err)
+		}
+	}
+
+	return &Webhook{
+		cfg:       cfg,
+		validator: validator,
+		source:    source,
+		schema:    catalog,
+	}
+}
+
+// Start serves the webhook over TLS in the background
+func (w *Webhook) Start(ctx context.Context) error {
+	ln, err := net.Listen("tcp", ":"+strconv.Itoa(w.cfg.WebhookPort))
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(ValidatePath, w)
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	go func() {
+		if err := srv.ServeTLS(ln, w.cfg.WebhookCertFile, w.cfg.WebhookKeyFile); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.Errorf("Admission webhook stopped: %+v", err)
+		}
+	}()
+
+	logrus.Infof("Serving admission webhook on port %d", w.cfg.WebhookPort)
+	return nil
+}
+
+// ServeHTTP handles an AdmissionReview
+func (w *Webhook) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBytes))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	review := &admissionv1.AdmissionReview{}
+	if err := json.Unmarshal(body, review); err != nil || review.Request == nil {
+		http.Error(rw, fmt.Sprintf("bad AdmissionReview: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	review.Response = w.Review(r.Context(), review.Request)
+	review.Response.UID = review.Request.UID
+	review.Request = nil
+
+	res, err := json.Marshal(review)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if _, err := rw.Write(res); err != nil {
+		logrus.Infof("Cannot write admission response: %+v", err)
+	}
+}
+
+// Review decides whether the object in the request holds a valid fluentd config
+func (w *Webhook) Review(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	if req.Operation == admissionv1.Delete {
+		return allowed()
+	}
+
+	conf, includes, ok, err := w.extractConfig(ctx, req)
+	if err != nil {
+		return denied(err)
+	}
+	if !ok {
+		return allowed()
+	}
+
+	if err := w.ValidateConfig(ctx, req.Namespace, conf, includes); err != nil {
+		logrus.Infof("Rejecting %s %s/%s: %+v", req.Kind.Kind, req.Namespace, req.Name, err)
+		return denied(err)
+	}
+
+	return allowed()
+}
+
+// extractConfig returns the fluentd config held by the object and the files it can
+// @include, false if the object is not read by the config-reloader
+func (w *Webhook) extractConfig(ctx context.Context, req *admissionv1.AdmissionRequest) (string, map[string]string, bool, error) {
+	switch req.Kind.Kind {
+	case "ConfigMap":
+		cm := &core.ConfigMap{}
+		if err := json.Unmarshal(req.Object.Raw, cm); err != nil {
+			return "", nil, false, err
+		}
+		if !w.isFluentdConfigMap(ctx, req.Namespace, cm) {
+			return "", nil, false, nil
+		}
+		conf, ok := cm.Data[configMapEntryName]
+		includes := map[string]string{}
+		for k, v := range cm.Data {
+			if k != configMapEntryName {
+				includes[k] = v
+			}
+		}
+		return conf, includes, ok, nil
+	case "FluentdConfig":
+		fc := &kfo.FluentdConfig{}
+		if err := json.Unmarshal(req.Object.Raw, fc); err != nil {
+			return "", nil, false, err
+		}
+		conf, err := kubedatasource.RenderFluentdConfigSpec(&fc.Spec)
+		return conf, fc.Spec.Includes, err == nil, err
+	}
+
+	return "", nil, false, nil
+}
+
+// isFluentdConfigMap tells if the config-reloader reads the ConfigMap. Like ConfigMapDS,
+// the annotation on the namespace takes precedence over the default name. The webhook
+// configuration can select these ConfigMaps with an objectSelector.
+func (w *Webhook) isFluentdConfigMap(ctx context.Context, namespace string, cm *core.ConfigMap) bool {
+	if w.cfg.Datasource == "multimap" {
+		return w.cfg.ParsedLabelSelector.AsSelector().Matches(labels.Set(cm.Labels))
+	}
+
+	name := w.cfg.DefaultConfigmapName
+	if ns, ok := w.source.(datasource.NamespaceSource); ok {
+		annotations, err := ns.GetNamespaceAnnotations(ctx, namespace)
+		if err != nil {
+			logrus.Infof("Cannot get the annotations of namespace %s: %+v", namespace, err)
+		}
+		if annotated := annotations[w.cfg.AnnotConfigmapName]; annotated != "" {
+			name = annotated
+		}
+	}
+
+	return name != "" && cm.Name == name
+}
+
+// ValidateConfig runs the same steps as the generator on the config of a namespace
+// and returns the first error. includes are the files the config can @include.
+func (w *Webhook) ValidateConfig(ctx context.Context, namespace string, conf string, includes map[string]string) error {
+	var tctx *template.Context
+	if ts, ok := w.source.(datasource.TemplateContextSource); ok {
+		tctx = ts.TemplateContext(ctx, namespace)
+	}
+
+	render := func(s string) (string, error) {
+		buf := new(strings.Builder)
+		err := template.Render(buf, s, map[string]string{
+			"Namespace": namespace,
+		}, tctx)
+		return buf.String(), err
+	}
+
+	conf, err := render(conf)
+	if err != nil {
+		return err
+	}
+	renderedIncludes := make(map[string]string, len(includes))
+	for name, contents := range includes {
+		if renderedIncludes[name], err = render(contents); err != nil {
+			return err
+		}
+	}
+
+	fragment, err := fluentd.ParseWithIncludes("", conf, renderedIncludes)
+	if err != nil {
+		return err
+	}
+
+	if namespace == w.cfg.AdminNamespace {
+		// the admin namespace is copied as is
+		return nil
+	}
+
+	genCtx := &processors.GenerationContext{
+		ReferencedBridges: map[string]bool{},
+	}
+	knownPlugins := w.loadPlugins(ctx, genCtx)
+
+	procCtx := &processors.ProcessorContext{
+		Namespace:         namespace,
+		AllowFile:         w.cfg.AllowFile,
+		DeploymentID:      w.cfg.ID,
+		KubeletRoot:       w.cfg.KubeletRoot,
+		BufferMountFolder: w.cfg.BufferMountFolder,
+		GenerationContext: genCtx,
+		AllowTagExpansion: w.cfg.AllowTagExpansion,
+		AllowSecrets:      w.cfg.AllowSecrets,
+		AllowEmbeddedRuby: w.cfg.AllowEmbeddedRuby,
+		RecordAnnotations: w.cfg.RecordAnnotations,
+		Schema:            w.schema,
+	}
+	if ps, ok := w.source.(datasource.PolicySource); ok {
+		procCtx.Policies = ps.GetPolicies(ctx, namespace)
+	}
+	if ss, ok := w.source.(datasource.SecretSource); ok && w.cfg.AllowSecrets {
+		procCtx.Secrets = ss.GetSecrets(ctx, namespace, datasource.ReferencedSecrets(fragment))
+	}
+	if w.cfg.AllowSecrets {
+		// the files only live for the validation, their names do not need to be stable
+		if procCtx.SecretNameKey, err = processors.NewSecretNameKey(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := processors.Prepare(fragment.Clone(), procCtx, processors.DefaultProcessors()...); err != nil {
+		return err
+	}
+
+	trailer := processors.GetValidationTrailer(fragment.Clone(), procCtx, processors.DefaultProcessors()...)
+
+	validate := w.validator != nil && knownPlugins
+	if validate {
+		// fluentd reads the secrets when validating, they go to a dir of their own
+		secretsDir, err := os.MkdirTemp("", "secrets-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(secretsDir)
+		procCtx.SecretsDir = secretsDir
+	}
+
+	processed, err := processors.Process(fragment, procCtx, processors.DefaultProcessors()...)
+	if err != nil {
+		return err
+	}
+
+	if !validate {
+		return nil
+	}
+
+	for name, content := range procCtx.SecretFiles {
+		if err := util.WriteSecretFile(filepath.Join(procCtx.SecretsDir, name), content); err != nil {
+			return err
+		}
+	}
+
+	return w.validator.ValidateConfigExtremely(processed.String()+"\n# validation  trailer:\n"+trailer.String(), namespace)
+}
+
+// loadPlugins fills the plugins and the quotas defined in the admin namespace and the
+// ClusterFluentdConfigs, returns false if they cannot be known
+func (w *Webhook) loadPlugins(ctx context.Context, genCtx *processors.GenerationContext) bool {
+	if w.source == nil {
+		return false
+	}
+
+	conf, err := w.source.GetFluentdConfig(ctx, w.cfg.AdminNamespace)
+	if err != nil {
+		logrus.Infof("Cannot read config of admin namespace %s: %+v", w.cfg.AdminNamespace, err)
+		return false
+	}
+
+	var includes map[string]string
+	if is, ok := w.source.(datasource.IncludeSource); ok {
+		if includes, err = is.GetIncludeFiles(ctx, w.cfg.AdminNamespace); err != nil {
+			logrus.Infof("Cannot read the files of admin namespace %s: %+v", w.cfg.AdminNamespace, err)
+			return false
+		}
+	}
+
+	fragment, err := fluentd.ParseWithIncludes("", conf, includes)
+	if err != nil {
+		return false
+	}
+
+	if ccs, ok := w.source.(datasource.ClusterConfigSource); ok {
+		clusterConfigs, err := ccs.GetClusterConfigs(ctx)
+		if err != nil {
+			logrus.Infof("Cannot read clusterfluentdconfigs: %+v", err)
+			return false
+		}
+		for _, cc := range clusterConfigs {
+			// the broken configs are left out by the generator as well
+			if ccFragment, err := fluentd.ParseString(cc.FluentdConfig); err == nil {
+				fragment = append(fragment, ccFragment...)
+			}
+		}
+	}
+
+	fragment = processors.ExtractPlugins(genCtx, fragment)
+	processors.ExtractQuotas(genCtx, fragment)
+
+	return true
+}
+
+func allowed() *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{
+		Allowed: true,
+	}
+}
+
+func denied(err error) *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Reason:  metav1.StatusReasonInvalid,
+			Code:    http.StatusUnprocessableEntity,
+			Message: err.Error(),
+		},
+	}
+}
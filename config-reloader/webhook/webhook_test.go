@@ -0,0 +1,191 @@
+// Copyright © 2018 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vmware/kube-fluentd-operator/config-reloader/config"
+	kfo "github.com/vmware/kube-fluentd-operator/config-reloader/datasource/kubedatasource/fluentdconfig/apis/logs.vdp.vmware.com/v1beta1"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func makeTestWebhook() *Webhook {
+	cfg := &config.Config{
+		Datasource:           "default",
+		DefaultConfigmapName: "fluentd-config",
+		AdminNamespace:       "kube-system",
+		ID:                   "default",
+	}
+
+	return New(context.Background(), cfg, nil)
+}
+
+func makeRequest(t *testing.T, kind string, namespace string, obj interface{}) *admissionv1.AdmissionRequest {
+	raw, err := json.Marshal(obj)
+	assert.Nil(t, err)
+
+	return &admissionv1.AdmissionRequest{
+		UID:       types.UID("uid"),
+		Kind:      metav1.GroupVersionKind{Kind: kind},
+		Namespace: namespace,
+		Operation: admissionv1.Create,
+		Object:    runtime.RawExtension{Raw: raw},
+	}
+}
+
+func makeConfigMap(name string, conf string) *core.ConfigMap {
+	return &core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Data:       map[string]string{"fluent.conf": conf},
+	}
+}
+
+func TestReviewConfigMap(t *testing.T) {
+	w := makeTestWebhook()
+	ctx := context.Background()
+
+	valid := "<match **>\n  @type null\n</match>\n"
+	res := w.Review(ctx, makeRequest(t, "ConfigMap", "demo", makeConfigMap("fluentd-config", valid)))
+	assert.True(t, res.Allowed)
+
+	// the same error as reported by the generator
+	res = w.Review(ctx, makeRequest(t, "ConfigMap", "demo", makeConfigMap("fluentd-config", "<match **>\n  @type file\n</match>\n")))
+	assert.False(t, res.Allowed)
+	assert.Equal(t, "line 1: cannot use '@type file' in <match>", res.Result.Message)
+
+	res = w.Review(ctx, makeRequest(t, "ConfigMap", "demo", makeConfigMap("fluentd-config", "<match **>\n  @type null\n")))
+	assert.False(t, res.Allowed)
+
+	// not read by the config-reloader
+	res = w.Review(ctx, makeRequest(t, "ConfigMap", "demo", makeConfigMap("other", "<match **>\n  @type file\n</match>\n")))
+	assert.True(t, res.Allowed)
+
+	// the admin namespace is not processed
+	res = w.Review(ctx, makeRequest(t, "ConfigMap", "kube-system", makeConfigMap("fluentd-config", "<match **>\n  @type file\n</match>\n")))
+	assert.True(t, res.Allowed)
+}
+
+type annotatedSource struct {
+	annotations map[string]map[string]string
+}
+
+func (s *annotatedSource) GetFluentdConfig(ctx context.Context, namespace string) (string, error) {
+	return "", nil
+}
+
+func (s *annotatedSource) GetNamespaceAnnotations(ctx context.Context, namespace string) (map[string]string, error) {
+	return s.annotations[namespace], nil
+}
+
+func TestReviewAnnotatedConfigMap(t *testing.T) {
+	w := makeTestWebhook()
+	w.cfg.AnnotConfigmapName = "logging.csp.vmware.com/fluentd-configmap"
+	w.source = &annotatedSource{
+		annotations: map[string]map[string]string{
+			"demo": {"logging.csp.vmware.com/fluentd-configmap": "custom"},
+		},
+	}
+	ctx := context.Background()
+
+	invalid := "<match **>\n  @type file\n</match>\n"
+	res := w.Review(ctx, makeRequest(t, "ConfigMap", "demo", makeConfigMap("custom", invalid)))
+	assert.False(t, res.Allowed)
+
+	// the annotation takes precedence over the default name
+	res = w.Review(ctx, makeRequest(t, "ConfigMap", "demo", makeConfigMap("fluentd-config", invalid)))
+	assert.True(t, res.Allowed)
+
+	res = w.Review(ctx, makeRequest(t, "ConfigMap", "other", makeConfigMap("fluentd-config", invalid)))
+	assert.False(t, res.Allowed)
+}
+
+func TestReviewIncludes(t *testing.T) {
+	w := makeTestWebhook()
+	ctx := context.Background()
+
+	cm := makeConfigMap("fluentd-config", "<match **>\n  @include output.conf\n</match>\n")
+	cm.Data["output.conf"] = "@type file"
+	res := w.Review(ctx, makeRequest(t, "ConfigMap", "demo", cm))
+	assert.False(t, res.Allowed)
+	assert.Equal(t, "line 1: cannot use '@type file' in <match>", res.Result.Message)
+
+	cm.Data["output.conf"] = "@type null"
+	res = w.Review(ctx, makeRequest(t, "ConfigMap", "demo", cm))
+	assert.True(t, res.Allowed)
+
+	fc := &kfo.FluentdConfig{
+		Spec: kfo.FluentdConfigSpec{
+			FluentConf: "@include missing.conf",
+		},
+	}
+	res = w.Review(ctx, makeRequest(t, "FluentdConfig", "demo", fc))
+	assert.False(t, res.Allowed)
+	assert.Equal(t, "line 1: cannot @include missing.conf: no such file", res.Result.Message)
+
+	fc.Spec.Includes = map[string]string{
+		"missing.conf": "<match **>\n  @type null\n  host \"#{`hostname`}\"\n</match>",
+	}
+	res = w.Review(ctx, makeRequest(t, "FluentdConfig", "demo", fc))
+	assert.False(t, res.Allowed)
+	assert.Equal(t, "missing.conf, line 3: cannot use embedded Ruby code in host", res.Result.Message)
+}
+
+func TestReviewFluentdConfig(t *testing.T) {
+	w := makeTestWebhook()
+	ctx := context.Background()
+
+	fc := &kfo.FluentdConfig{
+		Spec: kfo.FluentdConfigSpec{
+			Outputs: []kfo.Plugin{{Type: "null", Tag: "other.**"}},
+		},
+	}
+	res := w.Review(ctx, makeRequest(t, "FluentdConfig", "demo", fc))
+	assert.False(t, res.Allowed)
+	assert.Contains(t, res.Result.Message, "Tag must start with **, $thisns or demo")
+
+	fc.Spec.Outputs[0].Tag = ""
+	res = w.Review(ctx, makeRequest(t, "FluentdConfig", "demo", fc))
+	assert.True(t, res.Allowed)
+
+	fc.Spec.Outputs[0].Params = map[string]string{"@type": "file"}
+	res = w.Review(ctx, makeRequest(t, "FluentdConfig", "demo", fc))
+	assert.False(t, res.Allowed)
+}
+
+func TestServeHTTP(t *testing.T) {
+	w := makeTestWebhook()
+
+	review := &admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request:  makeRequest(t, "ConfigMap", "demo", makeConfigMap("fluentd-config", "<source>\n  @type tail\n</source>\n")),
+	}
+	body, err := json.Marshal(review)
+	assert.Nil(t, err)
+
+	rec := httptest.NewRecorder()
+	w.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, ValidatePath, bytes.NewReader(body)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	res := &admissionv1.AdmissionReview{}
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), res))
+	assert.Equal(t, types.UID("uid"), res.Response.UID)
+	assert.False(t, res.Response.Allowed)
+	assert.Equal(t, "line 1: cannot use <source> directive", res.Response.Result.Message)
+
+	rec = httptest.NewRecorder()
+	w.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, ValidatePath, bytes.NewReader([]byte("{}"))))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}